@@ -0,0 +1,59 @@
+package app
+
+import (
+	"fmt"
+	"time"
+)
+
+type (
+	ActivityFlusherSessionsInter interface {
+		FlushActivity() error
+	}
+
+	ActivityFlusher struct {
+		inter ActivityFlusherSessionsInter
+		stop  chan struct{}
+		done  chan struct{}
+	}
+)
+
+func NewActivityFlusher(inter ActivityFlusherSessionsInter) *ActivityFlusher {
+	return &ActivityFlusher{inter: inter}
+}
+
+// Run periodically persists the session activities recorded by the authorization requests.
+func (f *ActivityFlusher) Run(freq time.Duration) {
+	f.stop = make(chan struct{})
+	f.done = make(chan struct{})
+
+	go f.run(freq)
+}
+
+func (f *ActivityFlusher) run(freq time.Duration) {
+	defer close(f.done)
+
+	ticker := time.NewTicker(freq)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := f.inter.FlushActivity(); err != nil {
+				fmt.Println("WARNING: errors occured while persisting the session activities")
+			}
+		case <-f.stop:
+			return
+		}
+	}
+}
+
+// Stop stops the periodic flushes and persists the remaining activities.
+func (f *ActivityFlusher) Stop() error {
+	if f.stop != nil {
+		close(f.stop)
+		<-f.done
+		f.stop = nil
+	}
+
+	return f.inter.FlushActivity()
+}
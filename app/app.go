@@ -1,18 +1,24 @@
 package app
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/boltdb/bolt"
 	"github.com/go-zoo/bone"
 	"github.com/solher/auth-nginx-proxy-companion/infrastructure"
+	"github.com/solher/auth-nginx-proxy-companion/interactors"
+	"github.com/solher/auth-nginx-proxy-companion/matchers"
 	"github.com/solher/auth-nginx-proxy-companion/middlewares"
 	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/solher/auth-nginx-proxy-companion/repositories"
 	"github.com/solher/auth-nginx-proxy-companion/utils"
 	"github.com/solher/zest"
 
-	_ "github.com/solher/auth-nginx-proxy-companion/interactors"
 	_ "github.com/solher/auth-nginx-proxy-companion/validators"
 )
 
@@ -36,11 +42,21 @@ func Run(overrideConst zest.SeqFunc) {
 		SetRoutes,
 		ConnectDatabase,
 		MigrateDatabase,
+		ConnectArchive,
+		MigrateArchive,
 		SeedDatabase,
+		BuildAuthIndex,
+		LoadRevocations,
 		LaunchGarbageCollector,
+		LaunchActivityFlusher,
+		LaunchAuditWriter,
 	}
 
 	appli.ExitSequence = []zest.SeqFunc{
+		StopAuditWriter,
+		StopActivityFlusher,
+		StopGarbageCollector,
+		CloseArchive,
 		CloseDatabase,
 	}
 
@@ -61,10 +77,12 @@ func Register(z *zest.Zest) error {
 		NewConstants(),
 		// The database
 		&bolt.DB{},
-		// The garbage collector, used to backup the expired sessions
+		// The garbage collector, used to archive the expired sessions
 		NewGarbageCollector,
 		// The config importer, used to import config files in DB
 		NewConfigImporter,
+		// The activity flusher, used to persist the session activities
+		NewActivityFlusher,
 	)
 
 	return nil
@@ -85,19 +103,104 @@ func PopulateConstants(z *zest.Zest) error {
 
 	d.Const.Auth.RedirectURL = z.Context.GlobalString("redirectUrl")
 	d.Const.Auth.GrantAll = z.Context.GlobalBool("grantAll")
+	d.Const.Auth.CombiningAlgorithm = z.Context.GlobalString("combiningAlgorithm")
+	d.Const.Auth.CacheTTL = z.Context.GlobalDuration("decisionCacheTTL")
+	d.Const.Auth.CacheSize = z.Context.GlobalInt("decisionCacheSize")
+
+	switch d.Const.Auth.CombiningAlgorithm {
+	case models.FirstApplicable, models.PermitOverrides, models.DenyOverrides, models.MostSpecificWins:
+	default:
+		return fmt.Errorf("invalid combining algorithm: '%s'", d.Const.Auth.CombiningAlgorithm)
+	}
+
+	if proxies := z.Context.GlobalString("trustedProxies"); proxies != "" {
+		networks, err := matchers.ParseNetworks(strings.Split(proxies, ","))
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxies: %s", err)
+		}
+
+		d.Const.Auth.TrustedProxies = networks
+	}
 
 	d.Const.GC.Location = z.Context.GlobalString("gcLocation")
 	d.Const.GC.Freq = z.Context.GlobalDuration("gcFreq")
+	d.Const.GC.Retention = z.Context.GlobalDuration("archiveRetention")
+	d.Const.GC.AuditRetention = z.Context.GlobalDuration("auditRetention")
 
 	d.Const.DB.Location = z.Context.GlobalString("dbLocation")
 	d.Const.DB.Timeout = z.Context.GlobalDuration("dbTimeout")
 
 	d.Const.Session.Validity = z.Context.GlobalDuration("sessionValidity")
 	d.Const.Session.TokenLength = z.Context.GlobalInt("sessionTokenLength")
+	d.Const.Session.IdleTimeout = z.Context.GlobalDuration("sessionIdleTimeout")
+	d.Const.Session.MaxLifetime = z.Context.GlobalDuration("sessionMaxLifetime")
+	d.Const.Session.ActivityFlushFreq = z.Context.GlobalDuration("activityFlushFreq")
+	d.Const.Session.RefreshValidity = z.Context.GlobalDuration("refreshTokenValidity")
+	d.Const.Session.TokenSecret = z.Context.GlobalString("tokenSecret")
+	d.Const.Session.RotateTokenSecret = z.Context.GlobalBool("rotateTokenSecret")
+	d.Const.Session.TokenFormat = z.Context.GlobalString("tokenFormat")
+
+	// The activities are persisted periodically, a flush frequency is required
+	if d.Const.Session.ActivityFlushFreq <= 0 {
+		return fmt.Errorf("the activity flush frequency must be positive")
+	}
+
+	// An active session would expire before its activity is persisted
+	if idle := d.Const.Session.IdleTimeout; idle > 0 && d.Const.Session.ActivityFlushFreq >= idle {
+		return fmt.Errorf("the activity flush frequency must be below the session idle timeout")
+	}
+
+	switch d.Const.Session.TokenFormat {
+	case models.TokenOpaque:
+	case models.TokenJWT:
+		keys, err := parseSigningKeys(z.Context.GlobalString("jwtKeys"))
+		if err != nil {
+			return err
+		}
+
+		// The expiry of a jwt token can't be extended
+		if d.Const.Session.IdleTimeout > 0 {
+			return fmt.Errorf("the session idle timeout is not supported with jwt tokens")
+		}
+
+		d.Const.Session.JWTKeys = keys
+	default:
+		return fmt.Errorf("invalid token format: '%s'", d.Const.Session.TokenFormat)
+	}
 
 	return nil
 }
 
+// parseSigningKeys parses the comma separated 'kid:secret' keys.
+func parseSigningKeys(raw string) ([]models.SigningKey, error) {
+	keys := []models.SigningKey{}
+	ids := map[string]bool{}
+
+	for _, entry := range strings.Split(raw, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid jwt key: expected 'kid:secret'")
+		}
+
+		if ids[parts[0]] {
+			return nil, fmt.Errorf("duplicate jwt key id: '%s'", parts[0])
+		}
+
+		ids[parts[0]] = true
+		keys = append(keys, models.SigningKey{ID: parts[0], Secret: []byte(parts[1])})
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one jwt key is required with jwt tokens")
+	}
+
+	return keys, nil
+}
+
 func ConnectDatabase(z *zest.Zest) error {
 	d := &struct {
 		DB    *bolt.DB
@@ -141,7 +244,11 @@ func InitServer(z *zest.Zest) error {
 }
 
 func MigrateDatabase(z *zest.Zest) error {
-	d := &struct{ DB *bolt.DB }{}
+	d := &struct {
+		DB     *bolt.DB
+		Const  *Constants
+		Hasher *interactors.TokenHasher
+	}{}
 
 	if err := z.Injector.Get(d); err != nil {
 		return err
@@ -161,13 +268,315 @@ func MigrateDatabase(z *zest.Zest) error {
 			return err
 		}
 
+		if _, err := tx.CreateBucketIfNotExists([]byte("sessionOwners")); err != nil {
+			return err
+		}
+
+		if _, err := tx.CreateBucketIfNotExists([]byte("sessionExpiries")); err != nil {
+			return err
+		}
+
+		if _, err := tx.CreateBucketIfNotExists([]byte("audit")); err != nil {
+			return err
+		}
+
+		if _, err := tx.CreateBucketIfNotExists([]byte("refreshTokens")); err != nil {
+			return err
+		}
+
+		if _, err := tx.CreateBucketIfNotExists([]byte("refreshFamilies")); err != nil {
+			return err
+		}
+
+		if _, err := tx.CreateBucketIfNotExists([]byte("revokedTokens")); err != nil {
+			return err
+		}
+
+		if _, err := tx.CreateBucketIfNotExists([]byte("meta")); err != nil {
+			return err
+		}
+
+		secret, err := tokenSecret(tx.Bucket([]byte("meta")), d.Const.Session.TokenSecret, d.Const.Session.RotateTokenSecret)
+		if err != nil {
+			return err
+		}
+
+		d.Hasher.SetSecret(secret)
+
+		// The sessions used to be keyed by their raw token. They are now keyed by its hash
+		if err := migrateTokenHashes(tx, d.Hasher.Hash); err != nil {
+			return err
+		}
+
+		// The sessions of an owner used to be found by scanning them all
+		if err := migrateOwnerIndex(tx); err != nil {
+			return err
+		}
+
+		// The live sessions used to be found by scanning them all
+		if err := migrateExpiryIndex(tx); err != nil {
+			return err
+		}
+
+		// The resources used to be keyed by host name. They are now keyed by name
+		return migrateResourceKeys(tx.Bucket([]byte("resources")))
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func migrateResourceKeys(b *bolt.Bucket) error {
+	resources := map[string][]byte{}
+	c := b.Cursor()
+
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		resource := models.Resource{}
+		if err := json.Unmarshal(v, &resource); err != nil {
+			return err
+		}
+
+		if resource.Name != nil && *resource.Name != string(k) {
+			resources[string(k)] = v
+		}
+	}
+
+	// The bucket can't be modified while iterated
+	for k, v := range resources {
+		resource := models.Resource{}
+		json.Unmarshal(v, &resource)
+
+		if err := b.Delete([]byte(k)); err != nil {
+			return err
+		}
+
+		if err := b.Put([]byte(*resource.Name), v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tokenSecret returns the configured token secret, checked against the fingerprint of the database.
+// The secret is required: a generated one would be stored next to the hashes it protects.
+func tokenSecret(meta *bolt.Bucket, configured string, rotate bool) ([]byte, error) {
+	if configured == "" {
+		return nil, fmt.Errorf("a token secret is required, it is the HMAC key of the stored token hashes")
+	}
+
+	secret := []byte(configured)
+
+	if err := checkTokenSecret(meta, secret, rotate); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+// checkTokenSecret compares the fingerprint of the secret with the one stored in the database, so a changed
+// secret is detected instead of silently invalidating every stored token. The fingerprint is stored on first
+// use, and replaced when the secret is rotated on purpose.
+func checkTokenSecret(meta *bolt.Bucket, secret []byte, rotate bool) error {
+	fingerprint := secretFingerprint(secret)
+
+	if stored := meta.Get([]byte("tokenSecretFingerprint")); stored != nil && !rotate {
+		if !hmac.Equal(stored, fingerprint) {
+			return fmt.Errorf("the token secret changed, the stored tokens can't be found anymore: " +
+				"restore the previous secret, or set rotateTokenSecret to invalidate them")
+		}
+
 		return nil
+	}
+
+	return meta.Put([]byte("tokenSecretFingerprint"), fingerprint)
+}
+
+// secretFingerprint returns a digest identifying the secret without revealing it.
+func secretFingerprint(secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("tokenSecretFingerprint"))
+
+	return []byte(hex.EncodeToString(mac.Sum(nil)))
+}
+
+// ConnectArchive opens the archive database, where the garbage collector moves the expired sessions.
+func ConnectArchive(z *zest.Zest) error {
+	d := &struct {
+		Archive *repositories.ArchiveRepository
+		Const   *Constants
+	}{}
+
+	if err := z.Injector.Get(d); err != nil {
+		return err
+	}
+
+	return d.Archive.Open(d.Const.GC.Location, d.Const.DB.Timeout)
+}
+
+// MigrateArchive lays out the archive database as the main one, so the archived sessions can be queried.
+// It runs after MigrateDatabase, which sets the token secret.
+func MigrateArchive(z *zest.Zest) error {
+	d := &struct {
+		Archive *repositories.ArchiveRepository
+		Hasher  *interactors.TokenHasher
+		Const   *Constants
+	}{}
+
+	if err := z.Injector.Get(d); err != nil {
+		return err
+	}
+
+	return d.Archive.UpdateArchive(func(tx *bolt.Tx) error {
+		for _, bucket := range []string{"sessions", "sessionOwners", "sessionExpiries", "refreshTokens", "meta"} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+				return err
+			}
+		}
+
+		// The archived tokens are hashed with the same secret
+		secret := []byte(d.Const.Session.TokenSecret)
+		if err := checkTokenSecret(tx.Bucket([]byte("meta")), secret, d.Const.Session.RotateTokenSecret); err != nil {
+			return err
+		}
+
+		// The archived sessions used to be keyed by their raw token too
+		if err := migrateTokenHashes(tx, d.Hasher.Hash); err != nil {
+			return err
+		}
+
+		// The archived sessions used to be stored without indexes
+		if err := migrateOwnerIndex(tx); err != nil {
+			return err
+		}
+
+		return migrateExpiryIndex(tx)
 	})
+}
 
+// migrateTokenHashes replaces the raw tokens of the sessions by their hashes.
+// It is run once per database, a missing bucket is skipped.
+func migrateTokenHashes(tx *bolt.Tx, hash func(token string) string) error {
+	meta, err := tx.CreateBucketIfNotExists([]byte("meta"))
 	if err != nil {
 		return err
 	}
 
+	if meta.Get([]byte("tokensHashed")) != nil {
+		return nil
+	}
+
+	if b := tx.Bucket([]byte("sessions")); b != nil {
+		err := rehashKeys(b, hash, func(v []byte) ([]byte, error) {
+			session := models.Session{}
+			if err := json.Unmarshal(v, &session); err != nil {
+				return nil, err
+			}
+
+			session.Token = nil
+
+			return json.Marshal(session)
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return meta.Put([]byte("tokensHashed"), []byte("true"))
+}
+
+// migrateOwnerIndex indexes the existing sessions by owner token then by validity. It is run once per database.
+func migrateOwnerIndex(tx *bolt.Tx) error {
+	meta := tx.Bucket([]byte("meta"))
+
+	if meta.Get([]byte("ownersIndexed")) != nil {
+		return nil
+	}
+
+	owners := tx.Bucket([]byte("sessionOwners"))
+	c := tx.Bucket([]byte("sessions")).Cursor()
+
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		session := models.Session{}
+		if err := json.Unmarshal(v, &session); err != nil {
+			return err
+		}
+
+		if session.OwnerToken == nil || *session.OwnerToken == "" || session.ValidTo == nil {
+			continue
+		}
+
+		b, err := owners.CreateBucketIfNotExists([]byte(*session.OwnerToken))
+		if err != nil {
+			return err
+		}
+
+		if err := b.Put(interactors.ExpiryKey(*session.ValidTo, string(k)), []byte{}); err != nil {
+			return err
+		}
+	}
+
+	return meta.Put([]byte("ownersIndexed"), []byte("true"))
+}
+
+// migrateExpiryIndex indexes the existing sessions by validity. It is run once per database.
+func migrateExpiryIndex(tx *bolt.Tx) error {
+	meta := tx.Bucket([]byte("meta"))
+
+	if meta.Get([]byte("expiriesIndexed")) != nil {
+		return nil
+	}
+
+	expiries := tx.Bucket([]byte("sessionExpiries"))
+	c := tx.Bucket([]byte("sessions")).Cursor()
+
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		session := models.Session{}
+		if err := json.Unmarshal(v, &session); err != nil {
+			return err
+		}
+
+		if session.ValidTo == nil {
+			continue
+		}
+
+		if err := expiries.Put(interactors.ExpiryKey(*session.ValidTo, string(k)), []byte{}); err != nil {
+			return err
+		}
+	}
+
+	return meta.Put([]byte("expiriesIndexed"), []byte("true"))
+}
+
+// rehashKeys stores the entries of the bucket under the hash of their key, transformed by the given function.
+func rehashKeys(b *bolt.Bucket, hash func(token string) string, transform func(v []byte) ([]byte, error)) error {
+	entries := map[string][]byte{}
+	c := b.Cursor()
+
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		entries[string(k)] = v
+	}
+
+	// The bucket can't be modified while iterated
+	for k, v := range entries {
+		raw, err := transform(v)
+		if err != nil {
+			return err
+		}
+
+		if err := b.Delete([]byte(k)); err != nil {
+			return err
+		}
+
+		if err := b.Put([]byte(hash(k)), raw); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -235,6 +644,26 @@ func SeedDatabase(z *zest.Zest) error {
 	return nil
 }
 
+func BuildAuthIndex(z *zest.Zest) error {
+	d := &struct{ Index *interactors.AuthIndex }{}
+
+	if err := z.Injector.Get(d); err != nil {
+		return err
+	}
+
+	return d.Index.Rebuild()
+}
+
+func LoadRevocations(z *zest.Zest) error {
+	d := &struct{ Inter *interactors.SessionsInter }{}
+
+	if err := z.Injector.Get(d); err != nil {
+		return err
+	}
+
+	return d.Inter.LoadRevocations()
+}
+
 func LaunchGarbageCollector(z *zest.Zest) error {
 	d := &struct {
 		GC    *GarbageCollector
@@ -245,7 +674,79 @@ func LaunchGarbageCollector(z *zest.Zest) error {
 		return err
 	}
 
-	return d.GC.Run(d.Const.GC.Location, d.Const.GC.Freq)
+	d.GC.Run(d.Const.GC.Freq)
+
+	return nil
+}
+
+// StopGarbageCollector interrupts the current garbage collection, whose moved batches are kept.
+func StopGarbageCollector(z *zest.Zest) error {
+	d := &struct{ GC *GarbageCollector }{}
+
+	if err := z.Injector.Get(d); err != nil {
+		return err
+	}
+
+	return d.GC.Stop()
+}
+
+func LaunchActivityFlusher(z *zest.Zest) error {
+	d := &struct {
+		Flusher *ActivityFlusher
+		Const   *Constants
+	}{}
+
+	if err := z.Injector.Get(d); err != nil {
+		return err
+	}
+
+	d.Flusher.Run(d.Const.Session.ActivityFlushFreq)
+
+	return nil
+}
+
+func StopActivityFlusher(z *zest.Zest) error {
+	d := &struct{ Flusher *ActivityFlusher }{}
+
+	if err := z.Injector.Get(d); err != nil {
+		return err
+	}
+
+	// The pending activities are flushed before the database is closed
+	return d.Flusher.Stop()
+}
+
+func LaunchAuditWriter(z *zest.Zest) error {
+	d := &struct{ Audit *interactors.AuditInter }{}
+
+	if err := z.Injector.Get(d); err != nil {
+		return err
+	}
+
+	d.Audit.Run()
+
+	return nil
+}
+
+func StopAuditWriter(z *zest.Zest) error {
+	d := &struct{ Audit *interactors.AuditInter }{}
+
+	if err := z.Injector.Get(d); err != nil {
+		return err
+	}
+
+	// The queued entries are written before the database is closed
+	return d.Audit.Stop()
+}
+
+func CloseArchive(z *zest.Zest) error {
+	d := &struct{ Archive *repositories.ArchiveRepository }{}
+
+	if err := z.Injector.Get(d); err != nil {
+		return err
+	}
+
+	return d.Archive.Close()
 }
 
 func CloseDatabase(z *zest.Zest) error {
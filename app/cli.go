@@ -58,15 +58,68 @@ func SetCli(appli *zest.Zest) {
 		cli.DurationFlag{
 			Name:   "gcFreq",
 			Value:  time.Hour,
-			Usage:  "garbage collection frequency",
+			Usage:  "garbage collection frequency (0 to only run on demand)",
 			EnvVar: "GC_FREQ",
 		},
+		cli.DurationFlag{
+			Name:   "archiveRetention",
+			Usage:  "how long the expired sessions are kept in the archive (0 to keep them forever)",
+			EnvVar: "ARCHIVE_RETENTION",
+		},
+		cli.DurationFlag{
+			Name:   "auditRetention",
+			Value:  30 * 24 * time.Hour,
+			Usage:  "how long the audit entries are kept (0 to keep them forever)",
+			EnvVar: "AUDIT_RETENTION",
+		},
 		cli.DurationFlag{
 			Name:   "sessionValidity",
 			Value:  24 * time.Hour,
 			Usage:  "the default duration of a created session",
 			EnvVar: "SESSION_VALIDITY",
 		},
+		cli.DurationFlag{
+			Name:   "sessionIdleTimeout",
+			Usage:  "the inactivity after which a session expires, extended by each granted request (0 to disable)",
+			EnvVar: "SESSION_IDLE_TIMEOUT",
+		},
+		cli.DurationFlag{
+			Name:   "sessionMaxLifetime",
+			Usage:  "the absolute lifetime up to which an active session is extended (the session validity if 0)",
+			EnvVar: "SESSION_MAX_LIFETIME",
+		},
+		cli.DurationFlag{
+			Name:   "activityFlushFreq",
+			Value:  5 * time.Second,
+			Usage:  "the frequency at which the session activities are persisted (must be well below the idle timeout)",
+			EnvVar: "ACTIVITY_FLUSH_FREQ",
+		},
+		cli.DurationFlag{
+			Name:   "refreshTokenValidity",
+			Usage:  "the validity of the refresh token issued with each session, renewed by each exchange (0 to disable)",
+			EnvVar: "REFRESH_TOKEN_VALIDITY",
+		},
+		cli.StringFlag{
+			Name:   "tokenSecret",
+			Usage:  "the HMAC key of the stored token hashes (required)",
+			EnvVar: "TOKEN_SECRET",
+		},
+		cli.BoolFlag{
+			Name:   "rotateTokenSecret",
+			Usage:  "accepts a changed token secret, which invalidates the stored sessions",
+			EnvVar: "ROTATE_TOKEN_SECRET",
+		},
+		cli.StringFlag{
+			Name:   "tokenFormat",
+			Value:  "opaque",
+			Usage:  "the format of the issued session tokens (opaque or jwt, verified without any database lookup)",
+			EnvVar: "TOKEN_FORMAT",
+		},
+		cli.StringFlag{
+			Name:   "jwtKeys",
+			Usage:  "the comma separated 'kid:secret' keys of the jwt tokens. The first one signs, all of them verify",
+			EnvVar: "JWT_KEYS",
+		},
 		cli.IntFlag{
 			Name:   "sessionTokenLength",
 			Value:  64,
@@ -79,6 +132,29 @@ func SetCli(appli *zest.Zest) {
 			Usage:  "the default redirection URL when access is denied",
 			EnvVar: "REDIRECT_URL",
 		},
+		cli.StringFlag{
+			Name:   "combiningAlgorithm",
+			Value:  "permit-overrides",
+			Usage:  "the default policy combining algorithm (first-applicable, permit-overrides, deny-overrides or most-specific-wins)",
+			EnvVar: "COMBINING_ALGORITHM",
+		},
+		cli.DurationFlag{
+			Name:   "decisionCacheTTL",
+			Value:  5 * time.Second,
+			Usage:  "the lifetime of a cached authorization decision (0 to disable the cache)",
+			EnvVar: "DECISION_CACHE_TTL",
+		},
+		cli.IntFlag{
+			Name:   "decisionCacheSize",
+			Value:  10000,
+			Usage:  "the maximum number of cached authorization decisions",
+			EnvVar: "DECISION_CACHE_SIZE",
+		},
+		cli.StringFlag{
+			Name:   "trustedProxies",
+			Usage:  "the comma separated IPs or CIDR ranges of the proxies whose forwarded client IP is trusted",
+			EnvVar: "TRUSTED_PROXIES",
+		},
 		cli.BoolFlag{
 			Name:   "grantAll",
 			Usage:  "disables the auth server when set to true",
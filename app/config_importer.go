@@ -80,7 +80,7 @@ func (ci *ConfigImporter) Import(path string) error {
 	}
 
 	if config.Policies != nil {
-		for _, policy := range config.Policies {
+		for _, policy := range ci.sortPolicies(config.Policies) {
 			if err := ci.pv.ValidateCreation(&policy); err != nil {
 				return err
 			}
@@ -94,6 +94,59 @@ func (ci *ConfigImporter) Import(path string) error {
 	return nil
 }
 
+// sortPolicies orders the policies so the extended ones are imported first.
+// The policies of an inheritance cycle are left in the given order, for the validator to reject them.
+func (ci *ConfigImporter) sortPolicies(policies []models.Policy) []models.Policy {
+	sorted := []models.Policy{}
+	imported := map[string]bool{}
+	remaining := policies
+
+	for len(remaining) > 0 {
+		next := []models.Policy{}
+
+		for _, policy := range remaining {
+			ready := true
+
+			for _, parent := range policy.Extends {
+				if !imported[parent] && ci.declares(remaining, parent) {
+					ready = false
+					break
+				}
+			}
+
+			if !ready {
+				next = append(next, policy)
+				continue
+			}
+
+			sorted = append(sorted, policy)
+
+			if policy.Name != nil {
+				imported[*policy.Name] = true
+			}
+		}
+
+		// No progress: the remaining policies are part of a cycle
+		if len(next) == len(remaining) {
+			return append(sorted, next...)
+		}
+
+		remaining = next
+	}
+
+	return sorted
+}
+
+func (ci *ConfigImporter) declares(policies []models.Policy, name string) bool {
+	for _, policy := range policies {
+		if policy.Name != nil && *policy.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (ci *ConfigImporter) fromJSON(conf []byte) (*Config, error) {
 	config := &Config{}
 
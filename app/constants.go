@@ -1,6 +1,11 @@
 package app
 
-import "time"
+import (
+	"net"
+	"time"
+
+	"github.com/solher/auth-nginx-proxy-companion/models"
+)
 
 type Constants struct {
 	Swagger struct {
@@ -14,13 +19,19 @@ type Constants struct {
 	}
 
 	Auth struct {
-		RedirectURL string
-		GrantAll    bool
+		RedirectURL        string
+		GrantAll           bool
+		CombiningAlgorithm string
+		CacheTTL           time.Duration
+		CacheSize          int
+		TrustedProxies     []*net.IPNet
 	}
 
 	GC struct {
-		Location string
-		Freq     time.Duration
+		Location       string
+		Freq           time.Duration
+		Retention      time.Duration
+		AuditRetention time.Duration
 	}
 
 	DB struct {
@@ -29,8 +40,16 @@ type Constants struct {
 	}
 
 	Session struct {
-		Validity    time.Duration
-		TokenLength int
+		Validity          time.Duration
+		TokenLength       int
+		IdleTimeout       time.Duration
+		MaxLifetime       time.Duration
+		ActivityFlushFreq time.Duration
+		RefreshValidity   time.Duration
+		TokenSecret       string
+		RotateTokenSecret bool
+		TokenFormat       string
+		JWTKeys           []models.SigningKey
 	}
 }
 
@@ -46,6 +65,22 @@ func (c *Constants) GetGrantAll() bool {
 	return c.Auth.GrantAll
 }
 
+func (c *Constants) GetTrustedProxies() []*net.IPNet {
+	return c.Auth.TrustedProxies
+}
+
+func (c *Constants) GetCombiningAlgorithm() string {
+	return c.Auth.CombiningAlgorithm
+}
+
+func (c *Constants) GetDecisionCacheTTL() time.Duration {
+	return c.Auth.CacheTTL
+}
+
+func (c *Constants) GetDecisionCacheSize() int {
+	return c.Auth.CacheSize
+}
+
 func (c *Constants) GetSessionValidity() time.Duration {
 	return c.Session.Validity
 }
@@ -53,3 +88,31 @@ func (c *Constants) GetSessionValidity() time.Duration {
 func (c *Constants) GetSessionTokenLength() int {
 	return c.Session.TokenLength
 }
+
+func (c *Constants) GetSessionIdleTimeout() time.Duration {
+	return c.Session.IdleTimeout
+}
+
+func (c *Constants) GetSessionMaxLifetime() time.Duration {
+	return c.Session.MaxLifetime
+}
+
+func (c *Constants) GetRefreshTokenValidity() time.Duration {
+	return c.Session.RefreshValidity
+}
+
+func (c *Constants) GetTokenFormat() string {
+	return c.Session.TokenFormat
+}
+
+func (c *Constants) GetJWTKeys() []models.SigningKey {
+	return c.Session.JWTKeys
+}
+
+func (c *Constants) GetArchiveRetention() time.Duration {
+	return c.GC.Retention
+}
+
+func (c *Constants) GetAuditRetention() time.Duration {
+	return c.GC.AuditRetention
+}
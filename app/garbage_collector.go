@@ -1,47 +1,112 @@
 package app
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/solher/auth-nginx-proxy-companion/interactors"
 	"github.com/solher/auth-nginx-proxy-companion/models"
 
 	"github.com/boltdb/bolt"
 )
 
+// The number of entries walked per transaction.
+const gcBatchSize = 1000
+
+// errGCStopped is returned when a run is interrupted by Stop. The batches already moved are kept.
+var errGCStopped = errors.New("garbage collection stopped")
+
 type (
 	GarbageCollectorSessionsRepo interface {
 		Update(func(tx *bolt.Tx) error) error
+		View(func(tx *bolt.Tx) error) error
+	}
+
+	GarbageCollectorArchiveRepo interface {
+		UpdateArchive(func(tx *bolt.Tx) error) error
+		ViewArchive(func(tx *bolt.Tx) error) error
+	}
+
+	GarbageCollectorOptionsGetter interface {
+		GetArchiveRetention() time.Duration
+		GetAuditRetention() time.Duration
 	}
 
+	// GarbageCollector moves the expired sessions and refresh tokens to the archive database, in batches
+	// so the writes to the main database are never blocked for long. The archived sessions are indexed
+	// as in the main database, and pruned once expired for longer than the retention, if any.
+	// The audit entries older than their own retention are deleted too.
+	// The runs are periodic, or triggered on demand.
 	GarbageCollector struct {
-		repo GarbageCollectorSessionsRepo
+		repo    GarbageCollectorSessionsRepo
+		archive GarbageCollectorArchiveRepo
+		g       GarbageCollectorOptionsGetter
+
+		trigger chan struct{}
+		stop    chan struct{}
+		done    chan struct{}
+
+		mu     sync.Mutex
+		status models.GCStatus
 	}
-)
 
-func NewGarbageCollector(repo GarbageCollectorSessionsRepo) *GarbageCollector {
-	return &GarbageCollector{repo: repo}
-}
+	// gcEntry is an entry read from the main database, moved to the archive if still unchanged.
+	gcEntry struct {
+		key, value []byte
+		expiry     []byte // The expiry index entry of a session
+	}
+)
 
-func (gc *GarbageCollector) Run(dbLocation string, freq time.Duration) error {
-	db, err := bolt.Open(dbLocation, 0600, &bolt.Options{Timeout: time.Second})
-	if err != nil {
-		return err
+func NewGarbageCollector(
+	repo GarbageCollectorSessionsRepo,
+	archive GarbageCollectorArchiveRepo,
+	g GarbageCollectorOptionsGetter,
+) *GarbageCollector {
+	return &GarbageCollector{
+		repo:    repo,
+		archive: archive,
+		g:       g,
+		trigger: make(chan struct{}, 1),
 	}
+}
 
-	go gc.run(db, freq)
+func (gc *GarbageCollector) Run(freq time.Duration) {
+	gc.stop = make(chan struct{})
+	gc.done = make(chan struct{})
 
-	return nil
+	go gc.run(freq)
 }
 
-func (gc *GarbageCollector) run(db *bolt.DB, freq time.Duration) {
-	tick := time.Tick(freq)
+func (gc *GarbageCollector) run(freq time.Duration) {
+	defer close(gc.done)
+
+	// A non-positive frequency disables the periodic runs, only the triggered ones are done
+	var tick <-chan time.Time
+	if freq > 0 {
+		ticker := time.NewTicker(freq)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-tick:
+		case <-gc.trigger:
+		case <-gc.stop:
+			return
+		}
 
-	for range tick {
 		fmt.Println("Running the garbage collection...")
 
-		if err := gc.collect(db); err != nil {
+		if err := gc.collect(); err != nil {
+			if err == errGCStopped {
+				return
+			}
+
 			fmt.Println("WARNING: errors occured during the garbage collection")
 			continue
 		}
@@ -50,41 +115,747 @@ func (gc *GarbageCollector) run(db *bolt.DB, freq time.Duration) {
 	}
 }
 
-func (gc *GarbageCollector) collect(db *bolt.DB) error {
-	return gc.repo.Update(func(tx *bolt.Tx) error {
-		c := tx.Bucket([]byte("sessions")).Cursor()
+// Trigger requests a run, which starts once the current one is done. The requests made meanwhile are merged.
+func (gc *GarbageCollector) Trigger() {
+	select {
+	case gc.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Status returns the report of the last runs.
+func (gc *GarbageCollector) Status() *models.GCStatus {
+	gc.mu.Lock()
+	status := gc.status
+	gc.mu.Unlock()
+
+	status.Pending = len(gc.trigger) > 0
+
+	return &status
+}
+
+// Stop interrupts the current run between two batches.
+func (gc *GarbageCollector) Stop() error {
+	if gc.stop == nil {
+		return nil
+	}
+
+	close(gc.stop)
+	<-gc.done
+	gc.stop = nil
+
+	return nil
+}
+
+// stopped returns true once Stop is called.
+func (gc *GarbageCollector) stopped() bool {
+	select {
+	case <-gc.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// collect runs a garbage collection and reports it in the status.
+func (gc *GarbageCollector) collect() error {
+	start := time.Now().UTC()
+	report := models.GCStatus{}
+
+	gc.mu.Lock()
+	gc.status.Running = true
+	gc.mu.Unlock()
+
+	err := gc.collectSessions(&report)
+
+	if err == nil {
+		err = gc.collectRefreshTokens(&report)
+	}
 
-		for k, v := c.First(); k != nil; k, v = c.Next() {
+	if err == nil {
+		err = gc.collectRevocations(&report)
+	}
+
+	if retention := gc.g.GetArchiveRetention(); err == nil && retention > 0 {
+		cutoff := time.Now().Add(-retention)
+
+		if err = gc.pruneSessions(cutoff, &report); err == nil {
+			err = gc.pruneRefreshTokens(cutoff, &report)
+		}
+	}
+
+	if retention := gc.g.GetAuditRetention(); err == nil && retention > 0 {
+		err = gc.pruneAudit(time.Now().Add(-retention), &report)
+	}
+
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	gc.status.Running = false
+
+	if err == errGCStopped {
+		return err
+	}
+
+	gc.status.Runs++
+	gc.status.LastRun = &start
+	gc.status.LastDuration = time.Since(start).String()
+	gc.status.ArchivedSessions = report.ArchivedSessions
+	gc.status.ArchivedRefreshTokens = report.ArchivedRefreshTokens
+	gc.status.DeletedRevocations = report.DeletedRevocations
+	gc.status.PrunedSessions = report.PrunedSessions
+	gc.status.PrunedRefreshTokens = report.PrunedRefreshTokens
+	gc.status.PrunedAuditEntries = report.PrunedAuditEntries
+
+	if err != nil {
+		now := time.Now().UTC()
+		gc.status.Errors++
+		gc.status.LastError = err.Error()
+		gc.status.LastErrorTime = &now
+	}
+
+	return err
+}
+
+// collectSessions moves the expired sessions, walking the expiry index only.
+func (gc *GarbageCollector) collectSessions(report *models.GCStatus) error {
+	var after []byte
+
+	for {
+		if gc.stopped() {
+			return errGCStopped
+		}
+
+		batch := []gcEntry{}
+		stale := [][]byte{}
+		var last []byte
+
+		err := gc.repo.View(func(tx *bolt.Tx) error {
+			sessions := tx.Bucket([]byte("sessions"))
+			refreshTokens := tx.Bucket([]byte("refreshTokens"))
+			end := interactors.ExpiryKey(time.Now(), "")
+			walked := 0
+
+			c := tx.Bucket([]byte("sessionExpiries")).Cursor()
+
+			for k, _ := seekAfter(c, after); k != nil && bytes.Compare(k, end) < 0 && walked < gcBatchSize; k, _ = c.Next() {
+				walked++
+				last = append([]byte{}, k...)
+				key := k[8:]
+
+				v := sessions.Get(key)
+				if v == nil {
+					stale = append(stale, last)
+					continue
+				}
+
+				session := models.Session{}
+				if err := json.Unmarshal(v, &session); err != nil {
+					return err
+				}
+
+				// The entry is stale if the validity of the session changed
+				if session.ValidTo == nil || !bytes.Equal(interactors.ExpiryKey(*session.ValidTo, string(key)), k) {
+					stale = append(stale, last)
+					continue
+				}
+
+				// The session is kept as long as it can be refreshed
+				if session.RefreshToken != nil {
+					live, err := refreshable(refreshTokens.Get([]byte(*session.RefreshToken)))
+					if err != nil {
+						return err
+					}
+
+					if live {
+						continue
+					}
+				}
+
+				batch = append(batch, gcEntry{
+					key:    append([]byte{}, key...),
+					value:  append([]byte{}, v...),
+					expiry: last,
+				})
+			}
+
+			if walked < gcBatchSize {
+				last = nil
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		moved, err := gc.move("sessions", batch, indexArchived, func(tx *bolt.Tx, entry gcEntry) error {
 			session := models.Session{}
+			if err := json.Unmarshal(entry.value, &session); err != nil {
+				return err
+			}
 
-			if err := json.Unmarshal(v, &session); err != nil {
+			if err := unindexOwner(tx, entry.key, &session); err != nil {
 				return err
 			}
 
-			if session.ValidTo.After(time.Now()) {
-				continue
+			return tx.Bucket([]byte("sessionExpiries")).Delete(entry.expiry)
+		})
+
+		if err != nil {
+			return err
+		}
+
+		report.ArchivedSessions += moved
+
+		if err := gc.unindexStale(stale); err != nil {
+			return err
+		}
+
+		if last == nil {
+			return nil
+		}
+
+		after = last
+	}
+}
+
+// unindexStale deletes the expiry index entries which don't match the validity of their session.
+func (gc *GarbageCollector) unindexStale(stale [][]byte) error {
+	if len(stale) == 0 {
+		return nil
+	}
+
+	return gc.repo.Update(func(tx *bolt.Tx) error {
+		sessions := tx.Bucket([]byte("sessions"))
+		expiries := tx.Bucket([]byte("sessionExpiries"))
+
+		for _, entry := range stale {
+			// The session may have been written since
+			key := entry[8:]
+			if v := sessions.Get(key); v != nil {
+				session := models.Session{}
+				if err := json.Unmarshal(v, &session); err != nil {
+					return err
+				}
+
+				if session.ValidTo != nil && bytes.Equal(interactors.ExpiryKey(*session.ValidTo, string(key)), entry) {
+					continue
+				}
+			}
+
+			if err := expiries.Delete(entry); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// collectRefreshTokens moves the expired refresh tokens.
+// The rotated ones are kept until they expire, to detect their reuse.
+func (gc *GarbageCollector) collectRefreshTokens(report *models.GCStatus) error {
+	var after []byte
+
+	for {
+		if gc.stopped() {
+			return errGCStopped
+		}
+
+		batch, last, err := gc.scan(gc.repo.View, "refreshTokens", after, func(v []byte) (bool, error) {
+			refresh := models.RefreshToken{}
+			if err := json.Unmarshal(v, &refresh); err != nil {
+				return false, err
+			}
+
+			return !refresh.ValidTo.After(time.Now()), nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		moved, err := gc.move("refreshTokens", batch, nil, unindexFamily)
+		if err != nil {
+			return err
+		}
+
+		report.ArchivedRefreshTokens += moved
+
+		if last == nil {
+			return nil
+		}
+
+		after = last
+	}
+}
+
+// collectRevocations deletes the revocations of the expired jwt tokens, which are not archived.
+func (gc *GarbageCollector) collectRevocations(report *models.GCStatus) error {
+	var after []byte
+
+	for {
+		if gc.stopped() {
+			return errGCStopped
+		}
+
+		batch, last, err := gc.scan(gc.repo.View, "revokedTokens", after, func(v []byte) (bool, error) {
+			expires := time.Time{}
+			if err := json.Unmarshal(v, &expires); err != nil {
+				return false, err
+			}
+
+			return !expires.After(time.Now()), nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		deleted, err := deleteUnchanged(gc.repo.Update, "revokedTokens", batch)
+		if err != nil {
+			return err
+		}
+
+		report.DeletedRevocations += deleted
+
+		if last == nil {
+			return nil
+		}
+
+		after = last
+	}
+}
+
+// pruneSessions deletes the archived sessions expired before the cutoff, walking the archive expiry index only.
+func (gc *GarbageCollector) pruneSessions(cutoff time.Time, report *models.GCStatus) error {
+	end := interactors.ExpiryKey(cutoff, "")
+
+	for {
+		if gc.stopped() {
+			return errGCStopped
+		}
+
+		pruned := 0
+		more := false
+
+		err := gc.archive.UpdateArchive(func(tx *bolt.Tx) error {
+			sessions := tx.Bucket([]byte("sessions"))
+			expiries := tx.Bucket([]byte("sessionExpiries"))
+			entries := [][]byte{}
+
+			c := expiries.Cursor()
+
+			for k, _ := c.First(); k != nil && bytes.Compare(k, end) < 0; k, _ = c.Next() {
+				if len(entries) == gcBatchSize {
+					more = true
+					break
+				}
+
+				entries = append(entries, append([]byte{}, k...))
+			}
+
+			// The buckets can't be modified while iterated
+			for _, entry := range entries {
+				key := entry[8:]
+
+				if v := sessions.Get(key); v != nil {
+					session := models.Session{}
+					if err := json.Unmarshal(v, &session); err != nil {
+						return err
+					}
+
+					// A stale entry is deleted alone
+					if session.ValidTo != nil && bytes.Equal(interactors.ExpiryKey(*session.ValidTo, string(key)), entry) {
+						if err := unindexOwner(tx, key, &session); err != nil {
+							return err
+						}
+
+						if err := sessions.Delete(key); err != nil {
+							return err
+						}
+
+						pruned++
+					}
+				}
+
+				if err := expiries.Delete(entry); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		report.PrunedSessions += pruned
+
+		if !more {
+			return nil
+		}
+	}
+}
+
+// pruneRefreshTokens deletes the archived refresh tokens expired before the cutoff.
+func (gc *GarbageCollector) pruneRefreshTokens(cutoff time.Time, report *models.GCStatus) error {
+	var after []byte
+
+	for {
+		if gc.stopped() {
+			return errGCStopped
+		}
+
+		batch, last, err := gc.scan(gc.archive.ViewArchive, "refreshTokens", after, func(v []byte) (bool, error) {
+			refresh := models.RefreshToken{}
+			if err := json.Unmarshal(v, &refresh); err != nil {
+				return false, err
+			}
+
+			return refresh.ValidTo.Before(cutoff), nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		pruned, err := deleteUnchanged(gc.archive.UpdateArchive, "refreshTokens", batch)
+		if err != nil {
+			return err
+		}
+
+		report.PrunedRefreshTokens += pruned
+
+		if last == nil {
+			return nil
+		}
+
+		after = last
+	}
+}
+
+// pruneAudit deletes the audit entries recorded before the cutoff. As the entries are sorted by time,
+// only the oldest ones are walked.
+func (gc *GarbageCollector) pruneAudit(cutoff time.Time, report *models.GCStatus) error {
+	for {
+		if gc.stopped() {
+			return errGCStopped
+		}
+
+		keys := [][]byte{}
+		more := false
+
+		err := gc.repo.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket([]byte("audit"))
+			c := b.Cursor()
+
+			for k, v := c.First(); k != nil; k, v = c.Next() {
+				if len(keys) == gcBatchSize {
+					more = true
+					break
+				}
+
+				entry := models.AuditEntry{}
+				if err := json.Unmarshal(v, &entry); err != nil {
+					return err
+				}
+
+				if entry.Time == nil || !entry.Time.Before(cutoff) {
+					break
+				}
+
+				keys = append(keys, append([]byte{}, k...))
 			}
 
-			err := db.Update(func(tx *bolt.Tx) error {
-				b, err := tx.CreateBucketIfNotExists([]byte("sessions"))
-				if err != nil {
+			// The bucket can't be modified while iterated
+			for _, k := range keys {
+				if err := b.Delete(k); err != nil {
 					return err
 				}
+			}
 
-				b.Put(k, v)
+			return nil
+		})
 
-				return nil
-			})
+		if err != nil {
+			return err
+		}
+
+		report.PrunedAuditEntries += len(keys)
+
+		if !more {
+			return nil
+		}
+	}
+}
 
+// scan walks a batch of the bucket entries after the given key, in a transaction of the given database,
+// and returns the expired ones. It also returns the last walked key, or nil once the whole bucket was walked.
+func (gc *GarbageCollector) scan(
+	view func(func(tx *bolt.Tx) error) error,
+	bucket string,
+	after []byte,
+	expired func(v []byte) (bool, error),
+) ([]gcEntry, []byte, error) {
+	batch := []gcEntry{}
+	var last []byte
+
+	err := view(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(bucket)).Cursor()
+		walked := 0
+
+		for k, v := seekAfter(c, after); k != nil && walked < gcBatchSize; k, v = c.Next() {
+			walked++
+			last = append([]byte{}, k...)
+
+			ok, err := expired(v)
 			if err != nil {
 				return err
 			}
 
-			if err := c.Delete(); err != nil {
+			if ok {
+				batch = append(batch, gcEntry{key: last, value: append([]byte{}, v...)})
+			}
+		}
+
+		if walked < gcBatchSize {
+			last = nil
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return batch, last, nil
+}
+
+// move archives the batch, then deletes the entries which are unchanged since they were read.
+// The archive is written first, so an entry is never lost. The given functions index each archived entry
+// and clean up the indexes of each deleted one. It returns the number of deleted entries.
+func (gc *GarbageCollector) move(bucket string, batch []gcEntry, index, cleanup func(tx *bolt.Tx, entry gcEntry) error) (int, error) {
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	err := gc.archive.UpdateArchive(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+
+		for _, entry := range batch {
+			if index != nil {
+				if err := index(tx, entry); err != nil {
+					return err
+				}
+			}
+
+			if err := b.Put(entry.key, entry.value); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+
+	err = gc.repo.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+
+		for _, entry := range batch {
+			if !bytes.Equal(b.Get(entry.key), entry.value) {
+				continue
+			}
+
+			if cleanup != nil {
+				if err := cleanup(tx, entry); err != nil {
+					return err
+				}
+			}
+
+			if err := b.Delete(entry.key); err != nil {
 				return err
 			}
+
+			moved++
 		}
 
 		return nil
 	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return moved, nil
+}
+
+// deleteUnchanged deletes the entries of the batch which are unchanged since they were read, in a transaction
+// of the given database. It returns the number of deleted entries.
+func deleteUnchanged(update func(func(tx *bolt.Tx) error) error, bucket string, batch []gcEntry) (int, error) {
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	deleted := 0
+
+	err := update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+
+		for _, entry := range batch {
+			if !bytes.Equal(b.Get(entry.key), entry.value) {
+				continue
+			}
+
+			if err := b.Delete(entry.key); err != nil {
+				return err
+			}
+
+			deleted++
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+// seekAfter positions the cursor on the first key after the given one, or on the first key if nil.
+func seekAfter(c *bolt.Cursor, after []byte) ([]byte, []byte) {
+	if after == nil {
+		return c.First()
+	}
+
+	k, v := c.Seek(after)
+	if bytes.Equal(k, after) {
+		return c.Next()
+	}
+
+	return k, v
+}
+
+// refreshable returns true if the raw refresh token can still be exchanged.
+func refreshable(raw []byte) (bool, error) {
+	if raw == nil {
+		return false, nil
+	}
+
+	refresh := models.RefreshToken{}
+
+	if err := json.Unmarshal(raw, &refresh); err != nil {
+		return false, err
+	}
+
+	return refresh.Rotated == nil && refresh.Revoked == nil && refresh.ValidTo.After(time.Now()), nil
+}
+
+// unindexOwner removes the deleted session from the index of its owner, and the index once empty.
+func unindexOwner(tx *bolt.Tx, k []byte, session *models.Session) error {
+	if session.OwnerToken == nil || *session.OwnerToken == "" || session.ValidTo == nil {
+		return nil
+	}
+
+	owners := tx.Bucket([]byte("sessionOwners"))
+
+	b := owners.Bucket([]byte(*session.OwnerToken))
+	if b == nil {
+		return nil
+	}
+
+	if err := b.Delete(interactors.ExpiryKey(*session.ValidTo, string(k))); err != nil {
+		return err
+	}
+
+	if k, _ := b.Cursor().First(); k != nil {
+		return nil
+	}
+
+	return owners.DeleteBucket([]byte(*session.OwnerToken))
+}
+
+// unindexFamily removes the deleted refresh token from the index of its family, and the index once empty.
+func unindexFamily(tx *bolt.Tx, entry gcEntry) error {
+	refresh := models.RefreshToken{}
+	if err := json.Unmarshal(entry.value, &refresh); err != nil {
+		return err
+	}
+
+	if refresh.Family == nil || *refresh.Family == "" {
+		return nil
+	}
+
+	families := tx.Bucket([]byte("refreshFamilies"))
+
+	b := families.Bucket([]byte(*refresh.Family))
+	if b == nil {
+		return nil
+	}
+
+	if err := b.Delete(entry.key); err != nil {
+		return err
+	}
+
+	if k, _ := b.Cursor().First(); k != nil {
+		return nil
+	}
+
+	return families.DeleteBucket([]byte(*refresh.Family))
+}
+
+// indexArchived indexes the session about to be archived by owner and by validity, in place of the session
+// archived under the same key before, if any.
+func indexArchived(tx *bolt.Tx, entry gcEntry) error {
+	expiries := tx.Bucket([]byte("sessionExpiries"))
+
+	if v := tx.Bucket([]byte("sessions")).Get(entry.key); v != nil {
+		previous := models.Session{}
+		if err := json.Unmarshal(v, &previous); err != nil {
+			return err
+		}
+
+		if err := unindexOwner(tx, entry.key, &previous); err != nil {
+			return err
+		}
+
+		if previous.ValidTo != nil {
+			if err := expiries.Delete(interactors.ExpiryKey(*previous.ValidTo, string(entry.key))); err != nil {
+				return err
+			}
+		}
+	}
+
+	session := models.Session{}
+	if err := json.Unmarshal(entry.value, &session); err != nil {
+		return err
+	}
+
+	if session.ValidTo == nil {
+		return nil
+	}
+
+	if session.OwnerToken != nil && *session.OwnerToken != "" {
+		b, err := tx.Bucket([]byte("sessionOwners")).CreateBucketIfNotExists([]byte(*session.OwnerToken))
+		if err != nil {
+			return err
+		}
+
+		if err := b.Put(interactors.ExpiryKey(*session.ValidTo, string(entry.key)), []byte{}); err != nil {
+			return err
+		}
+	}
+
+	return expiries.Put(interactors.ExpiryKey(*session.ValidTo, string(entry.key)), []byte{})
 }
@@ -13,6 +13,9 @@ func SetRoutes(z *zest.Zest) error {
 		SessionsCtrl  *controllers.SessionsCtrl
 		ResourcesCtrl *controllers.ResourcesCtrl
 		PoliciesCtrl  *controllers.PoliciesCtrl
+		AuditCtrl     *controllers.AuditCtrl
+		GCCtrl        *controllers.GCCtrl
+		ArchiveCtrl   *controllers.ArchiveCtrl
 	}{}
 
 	if err := z.Injector.Get(d); err != nil {
@@ -20,19 +23,25 @@ func SetRoutes(z *zest.Zest) error {
 	}
 
 	d.Router.GetFunc("/auth", d.AuthCtrl.AuthorizeToken)
+	d.Router.GetFunc("/auth/explain", d.AuthCtrl.Explain)
+	d.Router.GetFunc("/auth/cache", d.AuthCtrl.CacheStats)
+	d.Router.PostFunc("/auth/simulate", d.AuthCtrl.Simulate)
 	d.Router.GetFunc("/redirect", d.AuthCtrl.Redirect)
 
 	d.Router.GetFunc("/sessions", d.SessionsCtrl.Find)
 	d.Router.GetFunc("/sessions/:token", d.SessionsCtrl.FindByToken)
 	d.Router.PostFunc("/sessions", d.SessionsCtrl.Create)
+	d.Router.PostFunc("/sessions/refresh", d.SessionsCtrl.Refresh)
 	d.Router.DeleteFunc("/sessions", d.SessionsCtrl.DeleteByOwnerToken)
 	d.Router.DeleteFunc("/sessions/:token", d.SessionsCtrl.DeleteByToken)
+	d.Router.PatchFunc("/sessions", d.SessionsCtrl.UpdateByOwnerToken)
+	d.Router.PatchFunc("/sessions/:token", d.SessionsCtrl.UpdateByToken)
 
 	d.Router.GetFunc("/resources", d.ResourcesCtrl.Find)
-	d.Router.GetFunc("/resources/:hostname", d.ResourcesCtrl.FindByHostname)
+	d.Router.GetFunc("/resources/:name", d.ResourcesCtrl.FindByName)
 	d.Router.PostFunc("/resources", d.ResourcesCtrl.Create)
-	d.Router.DeleteFunc("/resources/:hostname", d.ResourcesCtrl.DeleteByHostname)
-	d.Router.PutFunc("/resources/:hostname", d.ResourcesCtrl.UpdateByHostname)
+	d.Router.DeleteFunc("/resources/:name", d.ResourcesCtrl.DeleteByName)
+	d.Router.PutFunc("/resources/:name", d.ResourcesCtrl.UpdateByName)
 
 	d.Router.GetFunc("/policies", d.PoliciesCtrl.Find)
 	d.Router.GetFunc("/policies/:name", d.PoliciesCtrl.FindByName)
@@ -40,5 +49,14 @@ func SetRoutes(z *zest.Zest) error {
 	d.Router.DeleteFunc("/policies/:name", d.PoliciesCtrl.DeleteByName)
 	d.Router.PutFunc("/policies/:name", d.PoliciesCtrl.UpdateByName)
 
+	d.Router.GetFunc("/audit", d.AuditCtrl.Find)
+	d.Router.GetFunc("/audit/stats", d.AuditCtrl.Stats)
+
+	d.Router.GetFunc("/gc", d.GCCtrl.Status)
+	d.Router.PostFunc("/gc", d.GCCtrl.Trigger)
+
+	d.Router.GetFunc("/archive/sessions", d.ArchiveCtrl.FindSessions)
+	d.Router.GetFunc("/archive/sessions/:token", d.ArchiveCtrl.FindSessionByToken)
+
 	return nil
 }
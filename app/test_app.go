@@ -138,6 +138,8 @@ func (a *TestApp) Launch() (string, error) {
 		d.Const.App.Port = appPort
 		d.Const.DB.Location = a.dbLocation
 		d.Const.GC.Location = a.gcLocation
+		d.Const.Session.TokenSecret = "s3cr3t"
+		d.Const.Session.RefreshValidity = time.Hour
 
 		return nil
 	}
@@ -199,13 +201,13 @@ func (a *TestApp) Seed() error {
 
 		raw, _ := json.Marshal(testResource)
 
-		if err := b.Put([]byte(*testResource.Hostname), raw); err != nil {
+		if err := b.Put([]byte(*testResource.Name), raw); err != nil {
 			return err
 		}
 
 		raw, _ = json.Marshal(testResource2)
 
-		if err := b.Put([]byte(*testResource2.Hostname), raw); err != nil {
+		if err := b.Put([]byte(*testResource2.Name), raw); err != nil {
 			return err
 		}
 
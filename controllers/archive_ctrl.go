@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/solher/auth-nginx-proxy-companion/errs"
+	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/solher/zest"
+)
+
+func init() {
+	zest.Injector.Register(NewArchiveCtrl)
+}
+
+type (
+	ArchiveCtrlArchiveInter interface {
+		FindSessions(filter *models.SessionFilter) (*models.SessionPage, error)
+		FindSessionByToken(token string) (*models.Session, error)
+	}
+
+	ArchiveCtrl struct {
+		i  ArchiveCtrlArchiveInter
+		r  JSONRenderer // Interface used to mock the JSON renderer
+		pg ParamsGetter // Interface used to mock request params
+	}
+)
+
+func NewArchiveCtrl(i ArchiveCtrlArchiveInter, r JSONRenderer, pg ParamsGetter) *ArchiveCtrl {
+	return &ArchiveCtrl{i: i, r: r, pg: pg}
+}
+
+// FindSessions swagger:route GET /archive/sessions Archive ArchiveFindSessions
+//
+// Find sessions
+//
+// Finds a page of the archived sessions matching the filters. The sessions are archived by the garbage
+// collector once expired, and kept for the configured retention.
+// The next page is requested with the returned "X-Next-Cursor" header as cursor.
+//
+// Responses:
+//  200: SessionsPageResponse
+//  400: BodyDecodingResponse
+//  422: ValidationResponse
+//  500: InternalResponse
+func (c *ArchiveCtrl) FindSessions(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseSessionFilter(r)
+	if err != nil {
+		c.r.JSONError(w, http.StatusBadRequest, errs.API.BodyDecoding, err)
+		return
+	}
+
+	page, err := c.i.FindSessions(filter)
+	if err != nil {
+		switch err.(type) {
+		case errs.ErrValidation:
+			c.r.JSONError(w, 422, errs.API.Validation, err)
+		default:
+			c.r.JSONError(w, http.StatusInternalServerError, errs.API.Internal, err)
+		}
+		return
+	}
+
+	setPageHeaders(w, page)
+
+	c.r.JSON(w, http.StatusOK, page.Sessions)
+}
+
+// FindSessionByToken swagger:route GET /archive/sessions/{token} Archive ArchiveFindSessionByToken
+//
+// Find session by token
+//
+// Finds an archived session by token.
+//
+// Responses:
+//  200: SessionResponse
+//  404: NotFoundResponse
+//  500: InternalResponse
+func (c *ArchiveCtrl) FindSessionByToken(w http.ResponseWriter, r *http.Request) {
+	session, err := c.i.FindSessionByToken(c.pg.GetURLParam(r, "token"))
+	if err != nil {
+		switch err.(type) {
+		case errs.ErrNotFound:
+			c.r.JSONError(w, http.StatusNotFound, errs.API.NotFound, err)
+		default:
+			c.r.JSONError(w, http.StatusInternalServerError, errs.API.Internal, err)
+		}
+		return
+	}
+
+	c.r.JSON(w, http.StatusOK, session)
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/solher/auth-nginx-proxy-companion/errs"
+	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/solher/auth-nginx-proxy-companion/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type archiveCtrlArchiveInter struct {
+	errDB, errNotFound bool
+	filter             *models.SessionFilter
+}
+
+func (i *archiveCtrlArchiveInter) FindSessions(filter *models.SessionFilter) (*models.SessionPage, error) {
+	i.filter = filter
+
+	if i.errDB {
+		return nil, errs.Internal.Database
+	}
+
+	return &models.SessionPage{
+		Sessions:   []models.Session{{}, {}},
+		Total:      utils.IntCpy(4),
+		NextCursor: "c2",
+	}, nil
+}
+
+func (i *archiveCtrlArchiveInter) FindSessionByToken(token string) (*models.Session, error) {
+	if i.errDB {
+		return nil, errs.Internal.Database
+	}
+
+	if i.errNotFound {
+		return nil, errs.Internal.NotFound
+	}
+
+	return &models.Session{Token: &token}, nil
+}
+
+// TestArchiveCtrlFindSessions runs tests on the ArchiveCtrl FindSessions method.
+func TestArchiveCtrlFindSessions(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	params := utils.NewFakeParamsGetter()
+	render := utils.NewFakeRender()
+	inter := &archiveCtrlArchiveInter{}
+	recorder := httptest.NewRecorder()
+	ctrl := NewArchiveCtrl(inter, render, params)
+	sessionsOut := []models.Session{}
+
+	// No error, filtered and paginated
+	ctrl.FindSessions(recorder, utils.FakeRequest("GET", "http://foo.bar/archive/sessions?ownerToken=owner1&createdSince=2016-01-02T15:04:05Z&limit=2", nil))
+	r.Equal(200, render.Status)
+	err := json.NewDecoder(recorder.Body).Decode(&sessionsOut)
+	r.NoError(err)
+	a.Len(sessionsOut, 2)
+	a.Equal("4", recorder.Header().Get("X-Total-Count"))
+	a.Equal("c2", recorder.Header().Get("X-Next-Cursor"))
+	a.Equal("owner1", inter.filter.OwnerToken)
+	r.NotNil(inter.filter.CreatedSince)
+	a.Equal(2016, inter.filter.CreatedSince.Year())
+	a.Equal(2, inter.filter.Limit)
+	utils.Clear(params, render, recorder)
+
+	// Invalid time bound
+	ctrl.FindSessions(recorder, utils.FakeRequest("GET", "http://foo.bar/archive/sessions?expiresSince=lastmonth", nil))
+	r.Equal(400, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.BodyDecoding, render.APIError)
+	utils.Clear(params, render, recorder)
+
+	// The interactor returns a database error
+	inter.errDB = true
+	ctrl.FindSessions(recorder, utils.FakeRequest("GET", "http://foo.bar/archive/sessions", nil))
+	r.Equal(500, render.Status)
+	r.NotEmpty(recorder.Body.Bytes())
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.Internal, render.APIError)
+	utils.Clear(params, render, recorder)
+}
+
+// TestArchiveCtrlFindSessionByToken runs tests on the ArchiveCtrl FindSessionByToken method.
+func TestArchiveCtrlFindSessionByToken(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	params := utils.NewFakeParamsGetter()
+	render := utils.NewFakeRender()
+	inter := &archiveCtrlArchiveInter{}
+	recorder := httptest.NewRecorder()
+	ctrl := NewArchiveCtrl(inter, render, params)
+	sessionOut := &models.Session{}
+
+	// No error, a session is returned
+	ctrl.FindSessionByToken(recorder, utils.FakeRequest("GET", "http://foo.bar/archive/sessions/jhHgchgV", nil))
+	r.Equal(200, render.Status)
+	err := json.NewDecoder(recorder.Body).Decode(sessionOut)
+	r.NoError(err)
+	a.NotNil(sessionOut)
+	utils.Clear(params, render, recorder)
+
+	// The interactor returns a database error
+	inter.errDB = true
+	ctrl.FindSessionByToken(recorder, utils.FakeRequest("GET", "http://foo.bar/archive/sessions/jhHgchgV", nil))
+	r.Equal(500, render.Status)
+	r.NotEmpty(recorder.Body.Bytes())
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.Internal, render.APIError)
+	utils.Clear(params, render, recorder)
+
+	// Session not found
+	inter.errDB = false
+	inter.errNotFound = true
+	ctrl.FindSessionByToken(recorder, utils.FakeRequest("GET", "http://foo.bar/archive/sessions/jhHgchgV", nil))
+	r.Equal(404, render.Status)
+	r.NotEmpty(recorder.Body.Bytes())
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.NotFound, render.APIError)
+	utils.Clear(params, render, recorder)
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/solher/auth-nginx-proxy-companion/errs"
+	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/solher/zest"
+)
+
+func init() {
+	zest.Injector.Register(NewAuditCtrl)
+}
+
+type (
+	AuditCtrlAuditInter interface {
+		Find(filter *models.AuditFilter) ([]models.AuditEntry, error)
+		Stats() *models.AuditStats
+	}
+
+	AuditCtrl struct {
+		i AuditCtrlAuditInter
+		r JSONRenderer // Interface used to mock the JSON renderer
+	}
+)
+
+func NewAuditCtrl(i AuditCtrlAuditInter, r JSONRenderer) *AuditCtrl {
+	return &AuditCtrl{i: i, r: r}
+}
+
+// Find swagger:route GET /audit Audit AuditFind
+//
+// Find
+//
+// Finds the denials which would have occured on the resources in report mode, the most recent first.
+//
+// Responses:
+//  200: AuditEntriesResponse
+//  400: BodyDecodingResponse
+//  500: InternalResponse
+func (c *AuditCtrl) Find(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := &models.AuditFilter{
+		Resource:   query.Get("resource"),
+		Hostname:   query.Get("hostname"),
+		OwnerToken: query.Get("ownerToken"),
+	}
+
+	for key, bound := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := query.Get(key); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.r.JSONError(w, http.StatusBadRequest, errs.API.BodyDecoding, err)
+				return
+			}
+
+			*bound = &t
+		}
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			c.r.JSONError(w, http.StatusBadRequest, errs.API.BodyDecoding, err)
+			return
+		}
+
+		filter.Limit = limit
+	}
+
+	entries, err := c.i.Find(filter)
+	if err != nil {
+		c.r.JSONError(w, http.StatusInternalServerError, errs.API.Internal, err)
+		return
+	}
+
+	c.r.JSON(w, http.StatusOK, entries)
+}
+
+// Stats swagger:route GET /audit/stats Audit AuditStats
+//
+// Stats
+//
+// Returns the counters of the audit log writes. The entries are dropped instead of delaying the authorization
+// requests when the writes are lagging behind.
+//
+// Responses:
+//  200: AuditStatsResponse
+func (c *AuditCtrl) Stats(w http.ResponseWriter, r *http.Request) {
+	c.r.JSON(w, http.StatusOK, c.i.Stats())
+}
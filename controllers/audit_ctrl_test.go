@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/solher/auth-nginx-proxy-companion/errs"
+	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/solher/auth-nginx-proxy-companion/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type auditCtrlAuditInter struct {
+	errDB  bool
+	filter *models.AuditFilter
+}
+
+func (i *auditCtrlAuditInter) Find(filter *models.AuditFilter) ([]models.AuditEntry, error) {
+	i.filter = filter
+
+	if i.errDB {
+		return nil, errs.Internal.Database
+	}
+
+	return []models.AuditEntry{{}, {}}, nil
+}
+
+func (i *auditCtrlAuditInter) Stats() *models.AuditStats {
+	return &models.AuditStats{Queued: 1, Dropped: 2}
+}
+
+// TestAuditCtrlFind runs tests on the AuditCtrl Find method.
+func TestAuditCtrlFind(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	render := utils.NewFakeRender()
+	inter := &auditCtrlAuditInter{}
+	recorder := httptest.NewRecorder()
+	ctrl := NewAuditCtrl(inter, render)
+	entries := []models.AuditEntry{}
+
+	// Success
+	ctrl.Find(recorder, utils.FakeRequest("GET", "http://foo.bar/audit", nil))
+	r.Equal(200, render.Status)
+	r.NoError(json.Unmarshal(recorder.Body.Bytes(), &entries))
+	a.Len(entries, 2)
+	a.Nil(inter.filter.Since)
+	utils.Clear(nil, render, recorder)
+
+	// Success: filtered
+	ctrl.Find(recorder, utils.FakeRequest("GET", "http://foo.bar/audit?resource=Foobar&ownerToken=owner1&since=2016-01-02T15:04:05Z&limit=10", nil))
+	r.Equal(200, render.Status)
+	a.Equal("Foobar", inter.filter.Resource)
+	a.Equal("owner1", inter.filter.OwnerToken)
+	r.NotNil(inter.filter.Since)
+	a.Equal(2016, inter.filter.Since.Year())
+	a.Nil(inter.filter.Until)
+	a.Equal(10, inter.filter.Limit)
+	utils.Clear(nil, render, recorder)
+
+	// Invalid time bound
+	ctrl.Find(recorder, utils.FakeRequest("GET", "http://foo.bar/audit?until=yesterday", nil))
+	r.Equal(400, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.BodyDecoding, render.APIError)
+	utils.Clear(nil, render, recorder)
+
+	// Invalid limit
+	ctrl.Find(recorder, utils.FakeRequest("GET", "http://foo.bar/audit?limit=ten", nil))
+	r.Equal(400, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.BodyDecoding, render.APIError)
+	utils.Clear(nil, render, recorder)
+
+	inter.errDB = true
+
+	// Database error
+	ctrl.Find(recorder, utils.FakeRequest("GET", "http://foo.bar/audit", nil))
+	r.Equal(500, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.Internal, render.APIError)
+	utils.Clear(nil, render, recorder)
+}
+
+// TestAuditCtrlStats runs tests on the AuditCtrl Stats method.
+func TestAuditCtrlStats(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	render := utils.NewFakeRender()
+	recorder := httptest.NewRecorder()
+	ctrl := NewAuditCtrl(&auditCtrlAuditInter{}, render)
+
+	// Success
+	ctrl.Stats(recorder, utils.FakeRequest("GET", "http://foo.bar/audit/stats", nil))
+	r.Equal(200, render.Status)
+	stats := &models.AuditStats{}
+	r.NoError(json.Unmarshal(recorder.Body.Bytes(), stats))
+	a.Equal(1, stats.Queued)
+	a.Equal(uint64(2), stats.Dropped)
+}
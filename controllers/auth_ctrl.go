@@ -4,10 +4,15 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/solher/auth-nginx-proxy-companion/errs"
+	"github.com/solher/auth-nginx-proxy-companion/matchers"
 	"github.com/solher/auth-nginx-proxy-companion/models"
 
 	"github.com/solher/zest"
@@ -19,24 +24,33 @@ func init() {
 
 type (
 	AuthCtrlAuthInter interface {
-		AuthorizeToken(hostname, path, token string) (bool, *models.Session, error)
-		GetRedirectURL(hostname string) (string, error)
+		AuthorizeToken(hostname, path, method, clientIP, token string) (bool, *models.Session, error)
+		Explain(hostname, path, method, clientIP, token string) (*models.Decision, error)
+		CacheStats() *models.CacheStats
+		GetRedirectURL(hostname, path string) (string, error)
+		Simulate(simulation *models.Simulation) (*models.SimulationResult, error)
+	}
+
+	AuthCtrlAuthValidator interface {
+		ValidateSimulation(simulation *models.Simulation) error
 	}
 
 	AuthOptionsGetter interface {
 		GetRedirectURL() string
 		GetGrantAll() bool
+		GetTrustedProxies() []*net.IPNet
 	}
 
 	AuthCtrl struct {
 		i AuthCtrlAuthInter
+		v AuthCtrlAuthValidator
 		r JSONRenderer // Interface used to mock the JSON renderer
 		g AuthOptionsGetter
 	}
 )
 
-func NewAuthCtrl(i AuthCtrlAuthInter, r JSONRenderer, g AuthOptionsGetter) *AuthCtrl {
-	return &AuthCtrl{i: i, r: r, g: g}
+func NewAuthCtrl(i AuthCtrlAuthInter, r JSONRenderer, g AuthOptionsGetter, v AuthCtrlAuthValidator) *AuthCtrl {
+	return &AuthCtrl{i: i, r: r, g: g, v: v}
 }
 
 // AuthorizeToken swagger:route GET /auth Auth AuthAuthorizeToken
@@ -45,14 +59,21 @@ func NewAuthCtrl(i AuthCtrlAuthInter, r JSONRenderer, g AuthOptionsGetter) *Auth
 //
 // Authenticates and authorizes a given token.
 // In the case of a granted access, the session payload is set in the response header 'Auth-Server-Payload'.
+// The original request method can be forwarded to apply method specific permissions.
+// The client IP is the caller one, or the one forwarded in the 'X-Forwarded-For' or 'X-Real-IP' headers
+// if the caller is a trusted proxy.
+// A granted request exceeding a rate limit is rejected with a 'Retry-After' header.
 //
 // Responses:
 //  204: nil
 //	401: UnauthorizedResponse
+//  429: RateLimitedResponse
 //  500: InternalResponse
 func (c *AuthCtrl) AuthorizeToken(w http.ResponseWriter, r *http.Request) {
 	token := c.accessToken(r)
 	requestURL := c.requestURL(r)
+	requestMethod := c.requestMethod(r)
+	clientIP := c.clientIP(r)
 
 	u, err := url.ParseRequestURI(requestURL)
 	if err != nil {
@@ -60,11 +81,18 @@ func (c *AuthCtrl) AuthorizeToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	authorized, session, err := c.i.AuthorizeToken(u.Host, u.Path, token)
+	authorized, session, err := c.i.AuthorizeToken(u.Host, u.Path, requestMethod, clientIP, token)
 	if err != nil {
-		switch err.(type) {
+		switch e := err.(type) {
 		case errs.ErrNotFound:
 			// continue
+		case errs.ErrRateLimited:
+			if !c.g.GetGrantAll() {
+				// The delay is rounded up to the next second
+				w.Header().Set("Retry-After", strconv.Itoa(int((e.RetryAfter+time.Second-1)/time.Second)))
+				c.r.JSONError(w, http.StatusTooManyRequests, errs.API.RateLimited, err)
+				return
+			}
 		default:
 			c.r.JSONError(w, http.StatusInternalServerError, errs.API.Internal, err)
 			return
@@ -97,6 +125,88 @@ func (c *AuthCtrl) AuthorizeToken(w http.ResponseWriter, r *http.Request) {
 	c.r.JSON(w, http.StatusNoContent, nil)
 }
 
+// Explain swagger:route GET /auth/explain Auth AuthExplain
+//
+// Explain
+//
+// Evaluates a token like the authorize method and explains the decision.
+// The response details the resolved resource and session, every evaluated policy and permission and the deciding rule.
+// The client IP can be set to explain a request coming from another client.
+//
+// Responses:
+//  200: DecisionResponse
+//  500: InternalResponse
+func (c *AuthCtrl) Explain(w http.ResponseWriter, r *http.Request) {
+	token := c.accessToken(r)
+	requestURL := c.requestURL(r)
+	requestMethod := c.requestMethod(r)
+
+	clientIP := r.URL.Query().Get("clientIp")
+	if clientIP == "" {
+		clientIP = c.clientIP(r)
+	}
+
+	u, err := url.ParseRequestURI(requestURL)
+	if err != nil {
+		c.r.JSONError(w, http.StatusInternalServerError, errs.API.Internal, err)
+		return
+	}
+
+	decision, err := c.i.Explain(u.Host, u.Path, requestMethod, clientIP, token)
+	if err != nil {
+		c.r.JSONError(w, http.StatusInternalServerError, errs.API.Internal, err)
+		return
+	}
+
+	c.r.JSON(w, http.StatusOK, decision)
+}
+
+// CacheStats swagger:route GET /auth/cache Auth AuthCacheStats
+//
+// Cache stats
+//
+// Returns the hit and miss counters of the authorization decision cache.
+//
+// Responses:
+//  200: CacheStatsResponse
+func (c *AuthCtrl) CacheStats(w http.ResponseWriter, r *http.Request) {
+	c.r.JSON(w, http.StatusOK, c.i.CacheStats())
+}
+
+// Simulate swagger:route POST /auth/simulate Auth AuthSimulate
+//
+// Simulate
+//
+// Evaluates some requests for every active session and for a guest, with a proposed policy or configuration.
+// The decisions which would change compared to the current state are reported. Nothing is persisted.
+//
+// Responses:
+//  200: SimulationResultResponse
+//  400: BodyDecodingResponse
+//  422: ValidationResponse
+//  500: InternalResponse
+func (c *AuthCtrl) Simulate(w http.ResponseWriter, r *http.Request) {
+	simulation := &models.Simulation{}
+
+	if err := json.NewDecoder(r.Body).Decode(simulation); err != nil {
+		c.r.JSONError(w, http.StatusBadRequest, errs.API.BodyDecoding, err)
+		return
+	}
+
+	if err := c.v.ValidateSimulation(simulation); err != nil {
+		c.r.JSONError(w, 422, errs.API.Validation, err)
+		return
+	}
+
+	result, err := c.i.Simulate(simulation)
+	if err != nil {
+		c.r.JSONError(w, http.StatusInternalServerError, errs.API.Internal, err)
+		return
+	}
+
+	c.r.JSON(w, http.StatusOK, result)
+}
+
 // Redirect swagger:route GET /redirect Auth AuthRedirect
 //
 // Redirect
@@ -115,7 +225,7 @@ func (c *AuthCtrl) Redirect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	redirectURL, err := c.i.GetRedirectURL(u.Host)
+	redirectURL, err := c.i.GetRedirectURL(u.Host, u.Path)
 	if err != nil {
 		switch err.(type) {
 		case errs.ErrNotFound:
@@ -164,7 +274,60 @@ func (c *AuthCtrl) requestURL(r *http.Request) string {
 	return requestURL
 }
 
-// swagger:parameters Auth AuthAuthorizeToken
+func (c *AuthCtrl) requestMethod(r *http.Request) string {
+	requestMethod := r.Header.Get("Request-Method")
+
+	if m := r.URL.Query().Get("requestMethod"); m != "" {
+		requestMethod = m
+	}
+
+	return strings.ToUpper(requestMethod)
+}
+
+// clientIP returns the IP of the original client, or an empty string if it is unknown.
+// The forwarding headers are only trusted if the caller is a trusted proxy. The forwarded addresses are then
+// walked from the closest one, and the first address which is not a trusted proxy is the client.
+func (c *AuthCtrl) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ""
+	}
+
+	trusted := c.g.GetTrustedProxies()
+	if !matchers.ContainsIP(trusted, ip) {
+		return ip.String()
+	}
+
+	forwarded := []string{}
+	if header := r.Header.Get("X-Forwarded-For"); header != "" {
+		forwarded = strings.Split(header, ",")
+	} else if header := r.Header.Get("X-Real-IP"); header != "" {
+		forwarded = []string{header}
+	}
+
+	for idx := len(forwarded) - 1; idx >= 0; idx-- {
+		// A malformed address can't be trusted, so the previous one is the client
+		next := net.ParseIP(strings.TrimSpace(forwarded[idx]))
+		if next == nil {
+			break
+		}
+
+		ip = next
+
+		if !matchers.ContainsIP(trusted, ip) {
+			break
+		}
+	}
+
+	return ip.String()
+}
+
+// swagger:parameters Auth AuthAuthorizeToken AuthExplain
 type tokenParam struct {
 	// Access token (can also be set via the 'Auth-Server-Token' header. Ex: 'Auth-Server-Token: jhPd6Gf3jIP2h')
 	//
@@ -172,10 +335,26 @@ type tokenParam struct {
 	AccessToken string `json:"accessToken"`
 }
 
-// swagger:parameters Auth AuthAuthorizeToken AuthRedirect
+// swagger:parameters Auth AuthAuthorizeToken AuthExplain AuthRedirect
 type requestURLParam struct {
 	// The URL requested for access (can also be set via the 'Request-Url' header. Ex: 'Request-Url: http://foo.com/bar')
 	//
 	// in: query
 	RequestURL string `json:"requestUrl"`
 }
+
+// swagger:parameters Auth AuthExplain
+type clientIPParam struct {
+	// The IP of the client. The caller IP, or the forwarded one if the caller is a trusted proxy, if not set.
+	//
+	// in: query
+	ClientIP string `json:"clientIp"`
+}
+
+// swagger:parameters Auth AuthAuthorizeToken AuthExplain
+type requestMethodParam struct {
+	// The method of the request (can also be set via the 'Request-Method' header. Ex: 'Request-Method: GET')
+	//
+	// in: query
+	RequestMethod string `json:"requestMethod"`
+}
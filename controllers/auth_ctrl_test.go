@@ -1,10 +1,13 @@
 package controllers
 
 import (
+	"encoding/json"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/solher/auth-nginx-proxy-companion/errs"
+	"github.com/solher/auth-nginx-proxy-companion/matchers"
 	"github.com/solher/auth-nginx-proxy-companion/models"
 	"github.com/solher/auth-nginx-proxy-companion/utils"
 	"github.com/stretchr/testify/assert"
@@ -15,10 +18,15 @@ type authCtrlAuthInter struct {
 	errDB, errNotFound bool
 	sessionNotFound    bool
 	denyAccess         bool
+	rateLimited        bool
 	noRedirectURL      bool
+	method, clientIP   string
 }
 
-func (i *authCtrlAuthInter) AuthorizeToken(hostname, path, token string) (bool, *models.Session, error) {
+func (i *authCtrlAuthInter) AuthorizeToken(hostname, path, method, clientIP, token string) (bool, *models.Session, error) {
+	i.method = method
+	i.clientIP = clientIP
+
 	if i.errDB {
 		return false, nil, errs.Internal.Database
 	}
@@ -27,6 +35,10 @@ func (i *authCtrlAuthInter) AuthorizeToken(hostname, path, token string) (bool,
 		return false, nil, errs.Internal.NotFound
 	}
 
+	if i.rateLimited {
+		return false, nil, errs.NewErrRateLimited("a rate limit is exceeded", 1500*time.Millisecond)
+	}
+
 	session := &models.Session{
 		Payload: utils.StrCpy("{}"),
 	}
@@ -38,7 +50,19 @@ func (i *authCtrlAuthInter) AuthorizeToken(hostname, path, token string) (bool,
 	return !i.denyAccess, session, nil
 }
 
-func (i *authCtrlAuthInter) GetRedirectURL(hostname string) (string, error) {
+func (i *authCtrlAuthInter) Explain(hostname, path, method, clientIP, token string) (*models.Decision, error) {
+	if i.errDB {
+		return nil, errs.Internal.Database
+	}
+
+	return &models.Decision{Granted: !i.denyAccess, Hostname: hostname, Path: path, Method: method, ClientIP: clientIP}, nil
+}
+
+func (i *authCtrlAuthInter) CacheStats() *models.CacheStats {
+	return &models.CacheStats{Hits: 2, Misses: 1}
+}
+
+func (i *authCtrlAuthInter) GetRedirectURL(hostname, path string) (string, error) {
 	if i.errDB {
 		return "", errs.Internal.Database
 	}
@@ -54,6 +78,26 @@ func (i *authCtrlAuthInter) GetRedirectURL(hostname string) (string, error) {
 	return "http://foo.bar", nil
 }
 
+func (i *authCtrlAuthInter) Simulate(simulation *models.Simulation) (*models.SimulationResult, error) {
+	if i.errDB {
+		return nil, errs.Internal.Database
+	}
+
+	return &models.SimulationResult{Sessions: 1, Probes: len(simulation.Probes), Flips: []models.DecisionFlip{}}, nil
+}
+
+type authCtrlAuthValid struct {
+	errValid bool
+}
+
+func (v *authCtrlAuthValid) ValidateSimulation(simulation *models.Simulation) error {
+	if v.errValid {
+		return errs.NewErrValidation("validation error")
+	}
+
+	return nil
+}
+
 // TestAuthCtrlAuthorizeToken runs tests on the AuthCtrl AuthorizeToken method.
 func TestAuthCtrlAuthorizeToken(t *testing.T) {
 	a := assert.New(t)
@@ -62,7 +106,7 @@ func TestAuthCtrlAuthorizeToken(t *testing.T) {
 	getter := utils.NewFakeModelsGetter()
 	inter := &authCtrlAuthInter{}
 	recorder := httptest.NewRecorder()
-	ctrl := NewAuthCtrl(inter, render, getter)
+	ctrl := NewAuthCtrl(inter, render, getter, &authCtrlAuthValid{})
 
 	getter.GrantAll = true
 
@@ -92,12 +136,57 @@ func TestAuthCtrlAuthorizeToken(t *testing.T) {
 	values := req.URL.Query()
 	values.Set("requestUrl", "http://foo/bar")
 	values.Set("accessToken", "kjgcjgh576cg4")
+	values.Set("requestMethod", "delete")
 	req.URL.RawQuery = values.Encode()
 	ctrl.AuthorizeToken(recorder, req)
 	r.Equal(204, render.Status)
 	a.NotNil(recorder.Header().Get("Auth-Server-Payload"))
+	a.Equal("DELETE", inter.method)
+	utils.Clear(nil, render, recorder)
+
+	// No error, request method via header
+	req = utils.FakeRequest("GET", "http://foo.bar/auth", nil)
+	req.Header.Set("Request-URL", "http://foo/bar")
+	req.Header.Set("Request-Method", "POST")
+	ctrl.AuthorizeToken(recorder, req)
+	r.Equal(204, render.Status)
+	a.Equal("POST", inter.method)
 	utils.Clear(nil, render, recorder)
 
+	// No error, the client IP is the caller one
+	req = utils.FakeRequest("GET", "http://foo.bar/auth", nil)
+	req.Header.Set("Request-URL", "http://foo/bar")
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	req.RemoteAddr = "198.51.100.1:4321"
+	ctrl.AuthorizeToken(recorder, req)
+	r.Equal(204, render.Status)
+	a.Equal("198.51.100.1", inter.clientIP)
+	utils.Clear(nil, render, recorder)
+
+	getter.TrustedProxies, _ = matchers.ParseNetworks([]string{"198.51.100.0/24", "10.0.0.1"})
+
+	// No error, the client IP is forwarded by trusted proxies
+	req = utils.FakeRequest("GET", "http://foo.bar/auth", nil)
+	req.Header.Set("Request-URL", "http://foo/bar")
+	req.Header.Set("X-Forwarded-For", "192.0.2.5, 203.0.113.7, 10.0.0.1")
+	req.RemoteAddr = "198.51.100.1:4321"
+	ctrl.AuthorizeToken(recorder, req)
+	r.Equal(204, render.Status)
+	a.Equal("203.0.113.7", inter.clientIP)
+	utils.Clear(nil, render, recorder)
+
+	// No error, the client IP is forwarded by the real IP header
+	req = utils.FakeRequest("GET", "http://foo.bar/auth", nil)
+	req.Header.Set("Request-URL", "http://foo/bar")
+	req.Header.Set("X-Real-IP", "203.0.113.8")
+	req.RemoteAddr = "198.51.100.1:4321"
+	ctrl.AuthorizeToken(recorder, req)
+	r.Equal(204, render.Status)
+	a.Equal("203.0.113.8", inter.clientIP)
+	utils.Clear(nil, render, recorder)
+
+	getter.TrustedProxies = nil
+
 	// Error, no request URL
 	ctrl.AuthorizeToken(recorder, utils.FakeRequest("GET", "http://foo.bar/auth", nil))
 	r.Equal(500, render.Status)
@@ -142,6 +231,19 @@ func TestAuthCtrlAuthorizeToken(t *testing.T) {
 	utils.Clear(nil, render, recorder)
 
 	inter.errNotFound = false
+	inter.rateLimited = true
+
+	// Too many requests: a rate limit is exceeded
+	req = utils.FakeRequest("GET", "http://foo.bar/auth", nil)
+	req.Header.Set("Request-URL", "http://foo/bar")
+	ctrl.AuthorizeToken(recorder, req)
+	r.Equal(429, render.Status)
+	a.Equal("2", recorder.Header().Get("Retry-After"))
+	r.NotNil(render.APIError)
+	a.Equal(errs.API.RateLimited, render.APIError)
+	utils.Clear(nil, render, recorder)
+
+	inter.rateLimited = false
 	inter.errDB = true
 
 	// The interactor returns a database error
@@ -156,6 +258,126 @@ func TestAuthCtrlAuthorizeToken(t *testing.T) {
 	utils.Clear(nil, render, recorder)
 }
 
+// TestAuthCtrlExplain runs tests on the AuthCtrl Explain method.
+func TestAuthCtrlExplain(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	render := utils.NewFakeRender()
+	getter := utils.NewFakeModelsGetter()
+	inter := &authCtrlAuthInter{}
+	recorder := httptest.NewRecorder()
+	ctrl := NewAuthCtrl(inter, render, getter, &authCtrlAuthValid{})
+	decision := &models.Decision{}
+
+	inter.denyAccess = true
+
+	// Success: the decision is explained
+	req := utils.FakeRequest("GET", "http://foo.bar/auth/explain", nil)
+	req.Header.Set("Request-URL", "http://foo/bar")
+	req.Header.Set("Request-Method", "put")
+	ctrl.Explain(recorder, req)
+	r.Equal(200, render.Status)
+	err := json.Unmarshal(recorder.Body.Bytes(), decision)
+	r.NoError(err)
+	a.False(decision.Granted)
+	a.Equal("foo", decision.Hostname)
+	a.Equal("/bar", decision.Path)
+	a.Equal("PUT", decision.Method)
+	utils.Clear(nil, render, recorder)
+
+	// Success: the client IP is explicitly set
+	req = utils.FakeRequest("GET", "http://foo.bar/auth/explain?clientIp=10.1.2.3", nil)
+	req.Header.Set("Request-URL", "http://foo/bar")
+	ctrl.Explain(recorder, req)
+	r.Equal(200, render.Status)
+	err = json.Unmarshal(recorder.Body.Bytes(), decision)
+	r.NoError(err)
+	a.Equal("10.1.2.3", decision.ClientIP)
+	utils.Clear(nil, render, recorder)
+
+	// Error, no request URL
+	ctrl.Explain(recorder, utils.FakeRequest("GET", "http://foo.bar/auth/explain", nil))
+	r.Equal(500, render.Status)
+	utils.Clear(nil, render, recorder)
+
+	inter.errDB = true
+
+	// The interactor returns a database error
+	req = utils.FakeRequest("GET", "http://foo.bar/auth/explain", nil)
+	req.Header.Set("Request-URL", "http://foo/bar")
+	ctrl.Explain(recorder, req)
+	r.Equal(500, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.Internal, render.APIError)
+	utils.Clear(nil, render, recorder)
+}
+
+// TestAuthCtrlCacheStats runs tests on the AuthCtrl CacheStats method.
+func TestAuthCtrlCacheStats(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	render := utils.NewFakeRender()
+	recorder := httptest.NewRecorder()
+	ctrl := NewAuthCtrl(&authCtrlAuthInter{}, render, utils.NewFakeModelsGetter(), &authCtrlAuthValid{})
+
+	// Success
+	ctrl.CacheStats(recorder, utils.FakeRequest("GET", "http://foo.bar/auth/cache", nil))
+	r.Equal(200, render.Status)
+	stats := &models.CacheStats{}
+	r.NoError(json.Unmarshal(recorder.Body.Bytes(), stats))
+	a.Equal(uint64(2), stats.Hits)
+	a.Equal(uint64(1), stats.Misses)
+}
+
+// TestAuthCtrlSimulate runs tests on the AuthCtrl Simulate method.
+func TestAuthCtrlSimulate(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	render := utils.NewFakeRender()
+	inter := &authCtrlAuthInter{}
+	valid := &authCtrlAuthValid{}
+	recorder := httptest.NewRecorder()
+	ctrl := NewAuthCtrl(inter, render, utils.NewFakeModelsGetter(), valid)
+	simulation := &models.Simulation{
+		Policy: &models.Policy{Name: utils.StrCpy("foobar")},
+		Probes: []models.Probe{{Hostname: "foo.bar.com", Path: "/foo"}},
+	}
+
+	// Success
+	ctrl.Simulate(recorder, utils.FakeRequest("POST", "http://foo.bar/auth/simulate", simulation))
+	r.Equal(200, render.Status)
+	result := &models.SimulationResult{}
+	r.NoError(json.Unmarshal(recorder.Body.Bytes(), result))
+	a.Equal(1, result.Probes)
+	utils.Clear(nil, render, recorder)
+
+	// Body decoding error
+	ctrl.Simulate(recorder, utils.FakeRequestRaw("POST", "http://foo.bar/auth/simulate", []byte{'{'}))
+	r.Equal(400, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.BodyDecoding, render.APIError)
+	utils.Clear(nil, render, recorder)
+
+	valid.errValid = true
+
+	// Validation error
+	ctrl.Simulate(recorder, utils.FakeRequest("POST", "http://foo.bar/auth/simulate", simulation))
+	r.Equal(422, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.Validation, render.APIError)
+	utils.Clear(nil, render, recorder)
+
+	valid.errValid = false
+	inter.errDB = true
+
+	// Database error
+	ctrl.Simulate(recorder, utils.FakeRequest("POST", "http://foo.bar/auth/simulate", simulation))
+	r.Equal(500, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.Internal, render.APIError)
+	utils.Clear(nil, render, recorder)
+}
+
 // TestAuthCtrlRedirect runs tests on the AuthCtrl Redirect method.
 func TestAuthCtrlRedirect(t *testing.T) {
 	a := assert.New(t)
@@ -165,7 +387,7 @@ func TestAuthCtrlRedirect(t *testing.T) {
 	getter.RedirectURL = "http://default.com"
 	inter := &authCtrlAuthInter{}
 	recorder := httptest.NewRecorder()
-	ctrl := NewAuthCtrl(inter, render, getter)
+	ctrl := NewAuthCtrl(inter, render, getter, &authCtrlAuthValid{})
 
 	// Success: a resource is found and a redirect URL is set
 	req := utils.FakeRequest("GET", "http://foo.bar/redirect", nil)
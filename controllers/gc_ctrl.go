@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/solher/zest"
+)
+
+func init() {
+	zest.Injector.Register(NewGCCtrl)
+}
+
+type (
+	GCCtrlGarbageCollector interface {
+		Trigger()
+		Status() *models.GCStatus
+	}
+
+	GCCtrl struct {
+		gc GCCtrlGarbageCollector
+		r  JSONRenderer // Interface used to mock the JSON renderer
+	}
+)
+
+func NewGCCtrl(gc GCCtrlGarbageCollector, r JSONRenderer) *GCCtrl {
+	return &GCCtrl{gc: gc, r: r}
+}
+
+// Status swagger:route GET /gc GC GCStatus
+//
+// Status
+//
+// Returns the report of the last garbage collections.
+//
+// Responses:
+//  200: GCStatusResponse
+func (c *GCCtrl) Status(w http.ResponseWriter, r *http.Request) {
+	c.r.JSON(w, http.StatusOK, c.gc.Status())
+}
+
+// Trigger swagger:route POST /gc GC GCTrigger
+//
+// Trigger
+//
+// Requests a garbage collection, which starts once the current one is done.
+// The collection runs in the background, its report is returned by GET /gc.
+//
+// Responses:
+//  202: GCStatusResponse
+func (c *GCCtrl) Trigger(w http.ResponseWriter, r *http.Request) {
+	c.gc.Trigger()
+
+	c.r.JSON(w, http.StatusAccepted, c.gc.Status())
+}
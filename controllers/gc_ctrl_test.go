@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/solher/auth-nginx-proxy-companion/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type gcCtrlGarbageCollector struct {
+	triggered int
+}
+
+func (gc *gcCtrlGarbageCollector) Trigger() {
+	gc.triggered++
+}
+
+func (gc *gcCtrlGarbageCollector) Status() *models.GCStatus {
+	return &models.GCStatus{Runs: 3, Pending: gc.triggered > 0}
+}
+
+// TestGCCtrlStatus runs tests on the GCCtrl Status method.
+func TestGCCtrlStatus(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	render := utils.NewFakeRender()
+	gc := &gcCtrlGarbageCollector{}
+	recorder := httptest.NewRecorder()
+	ctrl := NewGCCtrl(gc, render)
+	status := &models.GCStatus{}
+
+	// Success
+	ctrl.Status(recorder, utils.FakeRequest("GET", "http://foo.bar/gc", nil))
+	r.Equal(200, render.Status)
+	r.NoError(json.Unmarshal(recorder.Body.Bytes(), status))
+	a.Equal(3, status.Runs)
+	a.Equal(0, gc.triggered)
+}
+
+// TestGCCtrlTrigger runs tests on the GCCtrl Trigger method.
+func TestGCCtrlTrigger(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	render := utils.NewFakeRender()
+	gc := &gcCtrlGarbageCollector{}
+	recorder := httptest.NewRecorder()
+	ctrl := NewGCCtrl(gc, render)
+	status := &models.GCStatus{}
+
+	// Success: the run is pending
+	ctrl.Trigger(recorder, utils.FakeRequest("POST", "http://foo.bar/gc", nil))
+	r.Equal(202, render.Status)
+	r.NoError(json.Unmarshal(recorder.Body.Bytes(), status))
+	a.True(status.Pending)
+	a.Equal(1, gc.triggered)
+}
@@ -167,6 +167,10 @@ func (c *PoliciesCtrl) UpdateByName(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The name is set from the URL so the validator can check the policy inheritance
+	name := c.pg.GetURLParam(r, "name")
+	policy.Name = &name
+
 	if err := c.v.ValidateUpdate(policy); err != nil {
 		c.r.JSONError(w, 422, errs.API.Validation, err)
 		return
@@ -174,7 +178,7 @@ func (c *PoliciesCtrl) UpdateByName(w http.ResponseWriter, r *http.Request) {
 
 	policy.Name = nil
 
-	policy, err := c.i.UpdateByName(c.pg.GetURLParam(r, "name"), policy)
+	policy, err := c.i.UpdateByName(name, policy)
 	if err != nil {
 		switch err.(type) {
 		case errs.ErrNotFound:
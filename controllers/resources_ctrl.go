@@ -16,10 +16,10 @@ func init() {
 type (
 	ResourcesCtrlResourcesInter interface {
 		Find() ([]models.Resource, error)
-		FindByHostname(hostname string) (*models.Resource, error)
+		FindByName(name string) (*models.Resource, error)
 		Create(resource *models.Resource) (*models.Resource, error)
-		DeleteByHostname(hostname string) (*models.Resource, error)
-		UpdateByHostname(hostname string, resource *models.Resource) (*models.Resource, error)
+		DeleteByName(name string) (*models.Resource, error)
+		UpdateByName(name string, resource *models.Resource) (*models.Resource, error)
 	}
 
 	ResourcesCtrlResourcesValidator interface {
@@ -62,18 +62,18 @@ func (c *ResourcesCtrl) Find(w http.ResponseWriter, r *http.Request) {
 	c.r.JSON(w, http.StatusOK, resources)
 }
 
-// FindByHostname swagger:route GET /resources/{hostname} Resources ResourcesFindByHostname
+// FindByName swagger:route GET /resources/{name} Resources ResourcesFindByName
 //
-// Find by hostname
+// Find by name
 //
-// Finds a resource by hostname from the data source.
+// Finds a resource by name from the data source.
 //
 // Responses:
 //  200: ResourceResponse
 //  404: NotFoundResponse
 //  500: InternalResponse
-func (c *ResourcesCtrl) FindByHostname(w http.ResponseWriter, r *http.Request) {
-	resource, err := c.i.FindByHostname(c.pg.GetURLParam(r, "hostname"))
+func (c *ResourcesCtrl) FindByName(w http.ResponseWriter, r *http.Request) {
+	resource, err := c.i.FindByName(c.pg.GetURLParam(r, "name"))
 	if err != nil {
 		switch err.(type) {
 		case errs.ErrNotFound:
@@ -120,18 +120,18 @@ func (c *ResourcesCtrl) Create(w http.ResponseWriter, r *http.Request) {
 	c.r.JSON(w, http.StatusCreated, resource)
 }
 
-// DeleteByHostname swagger:route DELETE /resources/{hostname} Resources ResourcesDeleteByHostname
+// DeleteByName swagger:route DELETE /resources/{name} Resources ResourcesDeleteByName
 //
-// Delete by hostname
+// Delete by name
 //
-// Deletes a resource by hostname from the data source.
+// Deletes a resource by name from the data source.
 //
 // Responses:
 //  200: ResourceResponse
 //  404: NotFoundResponse
 //  500: InternalResponse
-func (c *ResourcesCtrl) DeleteByHostname(w http.ResponseWriter, r *http.Request) {
-	resource, err := c.i.DeleteByHostname(c.pg.GetURLParam(r, "hostname"))
+func (c *ResourcesCtrl) DeleteByName(w http.ResponseWriter, r *http.Request) {
+	resource, err := c.i.DeleteByName(c.pg.GetURLParam(r, "name"))
 	if err != nil {
 		switch err.(type) {
 		case errs.ErrNotFound:
@@ -145,11 +145,11 @@ func (c *ResourcesCtrl) DeleteByHostname(w http.ResponseWriter, r *http.Request)
 	c.r.JSON(w, http.StatusOK, resource)
 }
 
-// UpdateByHostname swagger:route PUT /resources/{hostname} Resources ResourcesUpdateByHostname
+// UpdateByName swagger:route PUT /resources/{name} Resources ResourcesUpdateByName
 //
-// Update by hostname
+// Update by name
 //
-// Updates a resource by hostname from the data source.
+// Updates a resource by name from the data source.
 //
 // Responses:
 //  200: ResourceResponse
@@ -157,7 +157,7 @@ func (c *ResourcesCtrl) DeleteByHostname(w http.ResponseWriter, r *http.Request)
 //  422: ValidationResponse
 //  404: NotFoundResponse
 //  500: InternalResponse
-func (c *ResourcesCtrl) UpdateByHostname(w http.ResponseWriter, r *http.Request) {
+func (c *ResourcesCtrl) UpdateByName(w http.ResponseWriter, r *http.Request) {
 	resource := &models.Resource{}
 
 	if err := json.NewDecoder(r.Body).Decode(resource); err != nil {
@@ -165,14 +165,19 @@ func (c *ResourcesCtrl) UpdateByHostname(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	name := c.pg.GetURLParam(r, "name")
+
+	// The name is needed to validate the host names against the other resources
+	resource.Name = &name
+
 	if err := c.v.ValidateUpdate(resource); err != nil {
 		c.r.JSONError(w, 422, errs.API.Validation, err)
 		return
 	}
 
-	resource.Hostname = nil
+	resource.Name = nil
 
-	resource, err := c.i.UpdateByHostname(c.pg.GetURLParam(r, "hostname"), resource)
+	resource, err := c.i.UpdateByName(name, resource)
 	if err != nil {
 		switch err.(type) {
 		case errs.ErrNotFound:
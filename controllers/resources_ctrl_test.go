@@ -26,7 +26,7 @@ func (i *resourcesCtrlResourcesInter) Find() ([]models.Resource, error) {
 	return resources, nil
 }
 
-func (i *resourcesCtrlResourcesInter) FindByHostname(hostname string) (*models.Resource, error) {
+func (i *resourcesCtrlResourcesInter) FindByName(name string) (*models.Resource, error) {
 	if i.errDB {
 		return nil, errs.Internal.Database
 	}
@@ -48,7 +48,7 @@ func (i *resourcesCtrlResourcesInter) Create(resource *models.Resource) (*models
 	return resource, nil
 }
 
-func (i *resourcesCtrlResourcesInter) DeleteByHostname(hostname string) (*models.Resource, error) {
+func (i *resourcesCtrlResourcesInter) DeleteByName(name string) (*models.Resource, error) {
 	if i.errDB {
 		return nil, errs.Internal.Database
 	}
@@ -62,7 +62,7 @@ func (i *resourcesCtrlResourcesInter) DeleteByHostname(hostname string) (*models
 	return resource, nil
 }
 
-func (i *resourcesCtrlResourcesInter) UpdateByHostname(hostname string, resource *models.Resource) (*models.Resource, error) {
+func (i *resourcesCtrlResourcesInter) UpdateByName(name string, resource *models.Resource) (*models.Resource, error) {
 	if i.errDB {
 		return nil, errs.Internal.Database
 	}
@@ -123,8 +123,8 @@ func TestResourcesCtrlFind(t *testing.T) {
 	utils.Clear(params, render, recorder)
 }
 
-// TestResourcesCtrlFindByHostname runs tests on the ResourcesCtrl FindByHostname method.
-func TestResourcesCtrlFindByHostname(t *testing.T) {
+// TestResourcesCtrlFindByName runs tests on the ResourcesCtrl FindByName method.
+func TestResourcesCtrlFindByName(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
 	params := utils.NewFakeParamsGetter()
@@ -135,7 +135,7 @@ func TestResourcesCtrlFindByHostname(t *testing.T) {
 	resourceOut := &models.Resource{}
 
 	// No error, a resource is returned
-	ctrl.FindByHostname(recorder, utils.FakeRequest("GET", "http://foo.bar/resources/host.com", nil))
+	ctrl.FindByName(recorder, utils.FakeRequest("GET", "http://foo.bar/resources/Foobar", nil))
 	r.Equal(200, render.Status)
 	err := json.NewDecoder(recorder.Body).Decode(resourceOut)
 	r.NoError(err)
@@ -144,7 +144,7 @@ func TestResourcesCtrlFindByHostname(t *testing.T) {
 
 	// The interactor returns a database error
 	inter.errDB = true
-	ctrl.FindByHostname(recorder, utils.FakeRequest("GET", "http://foo.bar/resources/host.com", nil))
+	ctrl.FindByName(recorder, utils.FakeRequest("GET", "http://foo.bar/resources/Foobar", nil))
 	r.Equal(500, render.Status)
 	r.NotEmpty(recorder.Body.Bytes())
 	r.NotNil(render.APIError)
@@ -154,7 +154,7 @@ func TestResourcesCtrlFindByHostname(t *testing.T) {
 	// Resource not found
 	inter.errDB = false
 	inter.errNotFound = true
-	ctrl.FindByHostname(recorder, utils.FakeRequest("GET", "http://foo.bar/resources/host.com", nil))
+	ctrl.FindByName(recorder, utils.FakeRequest("GET", "http://foo.bar/resources/Foobar", nil))
 	r.Equal(404, render.Status)
 	r.NotEmpty(recorder.Body.Bytes())
 	r.NotNil(render.APIError)
@@ -221,8 +221,8 @@ func TestResourcesCtrlCreate(t *testing.T) {
 	utils.Clear(params, render, recorder)
 }
 
-// TestResourcesCtrlDeleteByHostname runs tests on the ResourcesCtrl DeleteByHostname method.
-func TestResourcesCtrlDeleteByHostname(t *testing.T) {
+// TestResourcesCtrlDeleteByName runs tests on the ResourcesCtrl DeleteByName method.
+func TestResourcesCtrlDeleteByName(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
 	params := utils.NewFakeParamsGetter()
@@ -233,7 +233,7 @@ func TestResourcesCtrlDeleteByHostname(t *testing.T) {
 	resourceOut := &models.Resource{}
 
 	// No error, a resource is returned
-	ctrl.DeleteByHostname(recorder, utils.FakeRequest("DELETE", "http://foo.bar/resources/host.com", nil))
+	ctrl.DeleteByName(recorder, utils.FakeRequest("DELETE", "http://foo.bar/resources/Foobar", nil))
 	r.Equal(200, render.Status)
 	err := json.NewDecoder(recorder.Body).Decode(resourceOut)
 	r.NoError(err)
@@ -242,7 +242,7 @@ func TestResourcesCtrlDeleteByHostname(t *testing.T) {
 
 	// The interactor returns a database error
 	inter.errDB = true
-	ctrl.DeleteByHostname(recorder, utils.FakeRequest("DELETE", "http://foo.bar/resources/host.com", nil))
+	ctrl.DeleteByName(recorder, utils.FakeRequest("DELETE", "http://foo.bar/resources/Foobar", nil))
 	r.Equal(500, render.Status)
 	r.NotEmpty(recorder.Body.Bytes())
 	r.NotNil(render.APIError)
@@ -252,7 +252,7 @@ func TestResourcesCtrlDeleteByHostname(t *testing.T) {
 	// Resource not found
 	inter.errDB = false
 	inter.errNotFound = true
-	ctrl.DeleteByHostname(recorder, utils.FakeRequest("DELETE", "http://foo.bar/resources/host.com", nil))
+	ctrl.DeleteByName(recorder, utils.FakeRequest("DELETE", "http://foo.bar/resources/Foobar", nil))
 	r.Equal(404, render.Status)
 	r.NotEmpty(recorder.Body.Bytes())
 	r.NotNil(render.APIError)
@@ -260,8 +260,8 @@ func TestResourcesCtrlDeleteByHostname(t *testing.T) {
 	utils.Clear(params, render, recorder)
 }
 
-// TestResourcesCtrlUpdateByHostname runs tests on the ResourcesCtrl UpdateByHostname method.
-func TestResourcesCtrlUpdateByHostname(t *testing.T) {
+// TestResourcesCtrlUpdateByName runs tests on the ResourcesCtrl UpdateByName method.
+func TestResourcesCtrlUpdateByName(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
 	params := utils.NewFakeParamsGetter()
@@ -278,7 +278,7 @@ func TestResourcesCtrlUpdateByHostname(t *testing.T) {
 	valid.errValid = true
 
 	// Validation error
-	ctrl.UpdateByHostname(recorder, utils.FakeRequest("PUT", "http://foo.bar/resources/1", resourceIn))
+	ctrl.UpdateByName(recorder, utils.FakeRequest("PUT", "http://foo.bar/resources/1", resourceIn))
 	r.Equal(422, render.Status)
 	r.NotEmpty(recorder.Body.Bytes())
 	r.NotNil(render.APIError)
@@ -288,16 +288,17 @@ func TestResourcesCtrlUpdateByHostname(t *testing.T) {
 	valid.errValid = false
 
 	// No error, a resource is returned
-	ctrl.UpdateByHostname(recorder, utils.FakeRequest("PUT", "http://foo.bar/resources/1", resourceIn))
+	ctrl.UpdateByName(recorder, utils.FakeRequest("PUT", "http://foo.bar/resources/1", resourceIn))
 	r.Equal(200, render.Status)
 	err := json.NewDecoder(recorder.Body).Decode(resourceOut)
 	r.NoError(err)
 	a.NotNil(resourceOut)
-	a.Nil(resourceOut.Hostname)
+	a.Nil(resourceOut.Name)
+	a.Equal("foo.bar.com", *resourceOut.Hostname)
 	utils.Clear(params, render, recorder)
 
 	// Null body decoding error
-	ctrl.UpdateByHostname(recorder, utils.FakeRequestRaw("PUT", "http://foo.bar/resources/1", nil))
+	ctrl.UpdateByName(recorder, utils.FakeRequestRaw("PUT", "http://foo.bar/resources/1", nil))
 	r.Equal(400, render.Status)
 	r.NotEmpty(recorder.Body.Bytes())
 	r.NotNil(render.APIError)
@@ -306,7 +307,7 @@ func TestResourcesCtrlUpdateByHostname(t *testing.T) {
 
 	// The interactor returns a database error
 	inter.errDB = true
-	ctrl.UpdateByHostname(recorder, utils.FakeRequest("PUT", "http://foo.bar/resources/1", resourceIn))
+	ctrl.UpdateByName(recorder, utils.FakeRequest("PUT", "http://foo.bar/resources/1", resourceIn))
 	r.Equal(500, render.Status)
 	r.NotEmpty(recorder.Body.Bytes())
 	r.NotNil(render.APIError)
@@ -316,7 +317,7 @@ func TestResourcesCtrlUpdateByHostname(t *testing.T) {
 	// Resource not found
 	inter.errDB = false
 	inter.errNotFound = true
-	ctrl.UpdateByHostname(recorder, utils.FakeRequest("PUT", "http://foo.bar/resources/1", resourceIn))
+	ctrl.UpdateByName(recorder, utils.FakeRequest("PUT", "http://foo.bar/resources/1", resourceIn))
 	r.Equal(404, render.Status)
 	r.NotEmpty(recorder.Body.Bytes())
 	r.NotNil(render.APIError)
@@ -3,6 +3,8 @@ package controllers
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/solher/auth-nginx-proxy-companion/errs"
 	"github.com/solher/auth-nginx-proxy-companion/models"
@@ -15,15 +17,20 @@ func init() {
 
 type (
 	SessionsCtrlSessionsInter interface {
-		Find() ([]models.Session, error)
+		FindByFilter(filter *models.SessionFilter) (*models.SessionPage, error)
 		FindByToken(token string) (*models.Session, error)
 		Create(session *models.Session) (*models.Session, error)
+		Refresh(token string) (*models.Session, error)
 		DeleteByToken(token string) (*models.Session, error)
 		DeleteByOwnerTokens(ownerToken []string) ([]models.Session, error)
+		UpdateByToken(token string, update *models.SessionUpdate) (*models.Session, error)
+		UpdateByOwnerTokens(ownerTokens []string, update *models.SessionUpdate) ([]models.Session, error)
 	}
 
 	SessionsCtrlSessionsValidator interface {
 		ValidateCreation(session *models.Session) error
+		ValidateRefresh(refresh *models.Refresh) error
+		ValidateUpdate(update *models.SessionUpdate) error
 	}
 
 	SessionsCtrl struct {
@@ -46,19 +53,88 @@ func NewSessionsCtrl(
 //
 // Find
 //
-// Finds all the sessions from the data source.
+// Finds a page of the live sessions matching the filters from the data source.
+// The next page is requested with the returned "X-Next-Cursor" header as cursor.
 //
 // Responses:
-//  200: SessionsResponse
+//  200: SessionsPageResponse
+//  400: BodyDecodingResponse
+//  422: ValidationResponse
 //  500: InternalResponse
 func (c *SessionsCtrl) Find(w http.ResponseWriter, r *http.Request) {
-	sessions, err := c.i.Find()
+	filter, err := parseSessionFilter(r)
 	if err != nil {
-		c.r.JSONError(w, http.StatusInternalServerError, errs.API.Internal, err)
+		c.r.JSONError(w, http.StatusBadRequest, errs.API.BodyDecoding, err)
 		return
 	}
 
-	c.r.JSON(w, http.StatusOK, sessions)
+	page, err := c.i.FindByFilter(filter)
+	if err != nil {
+		switch err.(type) {
+		case errs.ErrValidation:
+			c.r.JSONError(w, 422, errs.API.Validation, err)
+		default:
+			c.r.JSONError(w, http.StatusInternalServerError, errs.API.Internal, err)
+		}
+		return
+	}
+
+	setPageHeaders(w, page)
+
+	c.r.JSON(w, http.StatusOK, page.Sessions)
+}
+
+// parseSessionFilter parses the session filter from the query of the request.
+// The time bounds are RFC3339 formatted.
+func parseSessionFilter(r *http.Request) (*models.SessionFilter, error) {
+	query := r.URL.Query()
+
+	filter := &models.SessionFilter{
+		OwnerToken: query.Get("ownerToken"),
+		Policy:     query.Get("policy"),
+		Agent:      query.Get("agent"),
+		Cursor:     query.Get("cursor"),
+	}
+
+	bounds := map[string]**time.Time{
+		"createdSince": &filter.CreatedSince,
+		"createdUntil": &filter.CreatedUntil,
+		"expiresSince": &filter.ExpiresSince,
+		"expiresUntil": &filter.ExpiresUntil,
+	}
+
+	for key, bound := range bounds {
+		if value := query.Get(key); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, err
+			}
+
+			*bound = &t
+		}
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, err
+		}
+
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// setPageHeaders reports the total count of the matching sessions on the first page, and the cursor of the
+// next page if any.
+func setPageHeaders(w http.ResponseWriter, page *models.SessionPage) {
+	if page.Total != nil {
+		w.Header().Set("X-Total-Count", strconv.Itoa(*page.Total))
+	}
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
 }
 
 // FindByToken swagger:route GET /sessions/{token} Sessions SessionsFindByToken
@@ -119,6 +195,47 @@ func (c *SessionsCtrl) Create(w http.ResponseWriter, r *http.Request) {
 	c.r.JSON(w, http.StatusCreated, session)
 }
 
+// Refresh swagger:route POST /sessions/refresh Sessions SessionsRefresh
+//
+// Refresh
+//
+// Exchanges a refresh token for a new session and a new refresh token.
+// The previous session expires. Exchanging a refresh token twice revokes all the sessions issued from it.
+//
+// Responses:
+//  201: SessionResponse
+//  400: BodyDecodingResponse
+//  401: UnauthorizedResponse
+//  422: ValidationResponse
+//  500: InternalResponse
+func (c *SessionsCtrl) Refresh(w http.ResponseWriter, r *http.Request) {
+	refresh := &models.Refresh{}
+
+	if err := json.NewDecoder(r.Body).Decode(refresh); err != nil {
+		c.r.JSONError(w, http.StatusBadRequest, errs.API.BodyDecoding, err)
+		return
+	}
+
+	if err := c.v.ValidateRefresh(refresh); err != nil {
+		c.r.JSONError(w, 422, errs.API.Validation, err)
+		return
+	}
+
+	session, err := c.i.Refresh(*refresh.RefreshToken)
+	if err != nil {
+		switch err.(type) {
+		case errs.ErrNotFound:
+			// The refresh token is unknown, expired, revoked or reused
+			c.r.JSONError(w, http.StatusUnauthorized, errs.API.Unauthorized, err)
+		default:
+			c.r.JSONError(w, http.StatusInternalServerError, errs.API.Internal, err)
+		}
+		return
+	}
+
+	c.r.JSON(w, http.StatusCreated, session)
+}
+
 // DeleteByToken swagger:route DELETE /sessions/{token} Sessions SessionsDeleteByToken
 //
 // Delete by token
@@ -169,3 +286,89 @@ func (c *SessionsCtrl) DeleteByOwnerToken(w http.ResponseWriter, r *http.Request
 
 	c.r.JSON(w, http.StatusOK, sessions)
 }
+
+// UpdateByToken swagger:route PATCH /sessions/{token} Sessions SessionsUpdateByToken
+//
+// Update by token
+//
+// Updates the policies, the payload or the validity of a session by token.
+//
+// Responses:
+//  200: SessionResponse
+//  400: BodyDecodingResponse
+//  404: NotFoundResponse
+//  422: ValidationResponse
+//  500: InternalResponse
+func (c *SessionsCtrl) UpdateByToken(w http.ResponseWriter, r *http.Request) {
+	update := &models.SessionUpdate{}
+
+	if err := json.NewDecoder(r.Body).Decode(update); err != nil {
+		c.r.JSONError(w, http.StatusBadRequest, errs.API.BodyDecoding, err)
+		return
+	}
+
+	if err := c.v.ValidateUpdate(update); err != nil {
+		c.r.JSONError(w, 422, errs.API.Validation, err)
+		return
+	}
+
+	session, err := c.i.UpdateByToken(c.pg.GetURLParam(r, "token"), update)
+	if err != nil {
+		switch err.(type) {
+		case errs.ErrNotFound:
+			c.r.JSONError(w, http.StatusNotFound, errs.API.NotFound, err)
+		case errs.ErrValidation:
+			c.r.JSONError(w, 422, errs.API.Validation, err)
+		default:
+			c.r.JSONError(w, http.StatusInternalServerError, errs.API.Internal, err)
+		}
+		return
+	}
+
+	c.r.JSON(w, http.StatusOK, session)
+}
+
+// UpdateByOwnerToken swagger:route PATCH /sessions Sessions SessionsUpdateByOwnerToken
+//
+// Update by owner token
+//
+// Updates the policies, the payload or the validity of the sessions by owner token.
+//
+// Responses:
+//  200: SessionsResponse
+//  400: BodyDecodingResponse
+//  422: ValidationResponse
+//  500: InternalResponse
+func (c *SessionsCtrl) UpdateByOwnerToken(w http.ResponseWriter, r *http.Request) {
+	ownerTokens := []string{}
+
+	if err := json.Unmarshal([]byte(r.URL.Query().Get("ownerTokens")), &ownerTokens); err != nil {
+		c.r.JSONError(w, http.StatusBadRequest, errs.API.BodyDecoding, err)
+		return
+	}
+
+	update := &models.SessionUpdate{}
+
+	if err := json.NewDecoder(r.Body).Decode(update); err != nil {
+		c.r.JSONError(w, http.StatusBadRequest, errs.API.BodyDecoding, err)
+		return
+	}
+
+	if err := c.v.ValidateUpdate(update); err != nil {
+		c.r.JSONError(w, 422, errs.API.Validation, err)
+		return
+	}
+
+	sessions, err := c.i.UpdateByOwnerTokens(ownerTokens, update)
+	if err != nil {
+		switch err.(type) {
+		case errs.ErrValidation:
+			c.r.JSONError(w, 422, errs.API.Validation, err)
+		default:
+			c.r.JSONError(w, http.StatusInternalServerError, errs.API.Internal, err)
+		}
+		return
+	}
+
+	c.r.JSON(w, http.StatusOK, sessions)
+}
@@ -13,17 +13,34 @@ import (
 )
 
 type sessionsCtrlSessionsInter struct {
-	errDB, errNotFound bool
+	errDB, errNotFound, errJWT bool
+	filter                     *models.SessionFilter
 }
 
-func (i *sessionsCtrlSessionsInter) Find() ([]models.Session, error) {
+func (i *sessionsCtrlSessionsInter) FindByFilter(filter *models.SessionFilter) (*models.SessionPage, error) {
+	i.filter = filter
+
 	if i.errDB {
 		return nil, errs.Internal.Database
 	}
 
-	sessions := []models.Session{{}, {}, {}}
+	if filter.Cursor == "bad" {
+		return nil, errs.NewErrValidation("invalid cursor")
+	}
 
-	return sessions, nil
+	page := &models.SessionPage{
+		Sessions: []models.Session{{}, {}, {}},
+	}
+
+	if filter.Cursor == "" {
+		page.Total = utils.IntCpy(5)
+	}
+
+	if filter.Limit == 3 {
+		page.NextCursor = "c3"
+	}
+
+	return page, nil
 }
 
 func (i *sessionsCtrlSessionsInter) FindByToken(token string) (*models.Session, error) {
@@ -48,6 +65,20 @@ func (i *sessionsCtrlSessionsInter) Create(session *models.Session) (*models.Ses
 	return session, nil
 }
 
+func (i *sessionsCtrlSessionsInter) Refresh(token string) (*models.Session, error) {
+	if i.errDB {
+		return nil, errs.Internal.Database
+	}
+
+	if i.errNotFound {
+		return nil, errs.Internal.NotFound
+	}
+
+	session := &models.Session{}
+
+	return session, nil
+}
+
 func (i *sessionsCtrlSessionsInter) DeleteByToken(token string) (*models.Session, error) {
 	if i.errDB {
 		return nil, errs.Internal.Database
@@ -72,6 +103,38 @@ func (i *sessionsCtrlSessionsInter) DeleteByOwnerTokens(ownerToken []string) ([]
 	return sessions, nil
 }
 
+func (i *sessionsCtrlSessionsInter) UpdateByToken(token string, update *models.SessionUpdate) (*models.Session, error) {
+	if i.errDB {
+		return nil, errs.Internal.Database
+	}
+
+	if i.errNotFound {
+		return nil, errs.Internal.NotFound
+	}
+
+	if i.errJWT {
+		return nil, errs.NewErrValidation("jwt session")
+	}
+
+	session := &models.Session{Policies: update.Policies}
+
+	return session, nil
+}
+
+func (i *sessionsCtrlSessionsInter) UpdateByOwnerTokens(ownerTokens []string, update *models.SessionUpdate) ([]models.Session, error) {
+	if i.errDB {
+		return nil, errs.Internal.Database
+	}
+
+	if i.errJWT {
+		return nil, errs.NewErrValidation("jwt session")
+	}
+
+	sessions := []models.Session{{Policies: update.Policies}, {Policies: update.Policies}}
+
+	return sessions, nil
+}
+
 type sessionsCtrlSessionsValid struct {
 	errValid bool
 }
@@ -84,6 +147,22 @@ func (v *sessionsCtrlSessionsValid) ValidateCreation(session *models.Session) er
 	return nil
 }
 
+func (v *sessionsCtrlSessionsValid) ValidateRefresh(refresh *models.Refresh) error {
+	if v.errValid {
+		return errs.NewErrValidation("validation error")
+	}
+
+	return nil
+}
+
+func (v *sessionsCtrlSessionsValid) ValidateUpdate(update *models.SessionUpdate) error {
+	if v.errValid {
+		return errs.NewErrValidation("validation error")
+	}
+
+	return nil
+}
+
 // TestSessionsCtrlFind runs tests on the SessionsCtrl Find method.
 func TestSessionsCtrlFind(t *testing.T) {
 	a := assert.New(t)
@@ -101,6 +180,46 @@ func TestSessionsCtrlFind(t *testing.T) {
 	err := json.NewDecoder(recorder.Body).Decode(&sessionsOut)
 	r.NoError(err)
 	a.Len(sessionsOut, 3)
+	a.Equal("5", recorder.Header().Get("X-Total-Count"))
+	a.Empty(recorder.Header().Get("X-Next-Cursor"))
+	a.Nil(inter.filter.CreatedSince)
+	a.Equal(0, inter.filter.Limit)
+	utils.Clear(params, render, recorder)
+
+	// No error, filtered and paginated
+	ctrl.Find(recorder, utils.FakeRequest("GET", "http://foo.bar/sessions?ownerToken=owner1&policy=admin&agent=firefox&expiresUntil=2016-01-02T15:04:05Z&cursor=c0&limit=3", nil))
+	r.Equal(200, render.Status)
+	a.Equal("owner1", inter.filter.OwnerToken)
+	a.Equal("admin", inter.filter.Policy)
+	a.Equal("firefox", inter.filter.Agent)
+	r.NotNil(inter.filter.ExpiresUntil)
+	a.Equal(2016, inter.filter.ExpiresUntil.Year())
+	a.Nil(inter.filter.ExpiresSince)
+	a.Equal("c0", inter.filter.Cursor)
+	a.Equal(3, inter.filter.Limit)
+	a.Empty(recorder.Header().Get("X-Total-Count"))
+	a.Equal("c3", recorder.Header().Get("X-Next-Cursor"))
+	utils.Clear(params, render, recorder)
+
+	// Invalid cursor
+	ctrl.Find(recorder, utils.FakeRequest("GET", "http://foo.bar/sessions?cursor=bad", nil))
+	r.Equal(422, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.Validation, render.APIError)
+	utils.Clear(params, render, recorder)
+
+	// Invalid time bound
+	ctrl.Find(recorder, utils.FakeRequest("GET", "http://foo.bar/sessions?createdSince=yesterday", nil))
+	r.Equal(400, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.BodyDecoding, render.APIError)
+	utils.Clear(params, render, recorder)
+
+	// Invalid limit
+	ctrl.Find(recorder, utils.FakeRequest("GET", "http://foo.bar/sessions?limit=ten", nil))
+	r.Equal(400, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.BodyDecoding, render.APIError)
 	utils.Clear(params, render, recorder)
 
 	// The interactor returns a database error
@@ -212,6 +331,64 @@ func TestSessionsCtrlCreate(t *testing.T) {
 	utils.Clear(params, render, recorder)
 }
 
+// TestSessionsCtrlRefresh runs tests on the SessionsCtrl Refresh method.
+func TestSessionsCtrlRefresh(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	params := utils.NewFakeParamsGetter()
+	render := utils.NewFakeRender()
+	inter := &sessionsCtrlSessionsInter{}
+	valid := &sessionsCtrlSessionsValid{}
+	recorder := httptest.NewRecorder()
+	ctrl := NewSessionsCtrl(inter, render, params, valid)
+	refreshIn := &models.Refresh{RefreshToken: utils.StrCpy("R3fr3sh")}
+	sessionOut := &models.Session{}
+
+	valid.errValid = true
+
+	// Validation error
+	ctrl.Refresh(recorder, utils.FakeRequest("POST", "http://foo.bar/sessions/refresh", refreshIn))
+	r.Equal(422, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.Validation, render.APIError)
+	utils.Clear(params, render, recorder)
+
+	valid.errValid = false
+
+	// No error, a new session is issued
+	ctrl.Refresh(recorder, utils.FakeRequest("POST", "http://foo.bar/sessions/refresh", refreshIn))
+	r.Equal(201, render.Status)
+	err := json.NewDecoder(recorder.Body).Decode(sessionOut)
+	r.NoError(err)
+	utils.Clear(params, render, recorder)
+
+	// Body decoding error
+	ctrl.Refresh(recorder, utils.FakeRequestRaw("POST", "http://foo.bar/sessions/refresh", []byte{'{'}))
+	r.Equal(400, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.BodyDecoding, render.APIError)
+	utils.Clear(params, render, recorder)
+
+	inter.errNotFound = true
+
+	// The refresh token is invalid
+	ctrl.Refresh(recorder, utils.FakeRequest("POST", "http://foo.bar/sessions/refresh", refreshIn))
+	r.Equal(401, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.Unauthorized, render.APIError)
+	utils.Clear(params, render, recorder)
+
+	inter.errNotFound = false
+	inter.errDB = true
+
+	// The interactor returns a database error
+	ctrl.Refresh(recorder, utils.FakeRequest("POST", "http://foo.bar/sessions/refresh", refreshIn))
+	r.Equal(500, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.Internal, render.APIError)
+	utils.Clear(params, render, recorder)
+}
+
 // TestSessionsCtrlDeleteByToken runs tests on the SessionsCtrl DeleteByToken method.
 func TestSessionsCtrlDeleteByToken(t *testing.T) {
 	a := assert.New(t)
@@ -292,3 +469,137 @@ func TestSessionsCtrlDeleteByOwnerToken(t *testing.T) {
 	a.IsType(errs.API.Internal, render.APIError)
 	utils.Clear(params, render, recorder)
 }
+
+// TestSessionsCtrlUpdateByToken runs tests on the SessionsCtrl UpdateByToken method.
+func TestSessionsCtrlUpdateByToken(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	params := utils.NewFakeParamsGetter()
+	render := utils.NewFakeRender()
+	inter := &sessionsCtrlSessionsInter{}
+	valid := &sessionsCtrlSessionsValid{}
+	recorder := httptest.NewRecorder()
+	ctrl := NewSessionsCtrl(inter, render, params, valid)
+	updateIn := &models.SessionUpdate{Policies: []string{"admin"}}
+	sessionOut := &models.Session{}
+
+	valid.errValid = true
+
+	// Validation error
+	ctrl.UpdateByToken(recorder, utils.FakeRequest("PATCH", "http://foo.bar/sessions/jhHgchgV", updateIn))
+	r.Equal(422, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.Validation, render.APIError)
+	utils.Clear(params, render, recorder)
+
+	valid.errValid = false
+
+	// No error, the updated session is returned
+	ctrl.UpdateByToken(recorder, utils.FakeRequest("PATCH", "http://foo.bar/sessions/jhHgchgV", updateIn))
+	r.Equal(200, render.Status)
+	err := json.NewDecoder(recorder.Body).Decode(sessionOut)
+	r.NoError(err)
+	a.Equal([]string{"admin"}, sessionOut.Policies)
+	utils.Clear(params, render, recorder)
+
+	// Body decoding error
+	ctrl.UpdateByToken(recorder, utils.FakeRequestRaw("PATCH", "http://foo.bar/sessions/jhHgchgV", []byte{'{'}))
+	r.Equal(400, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.BodyDecoding, render.APIError)
+	utils.Clear(params, render, recorder)
+
+	inter.errJWT = true
+
+	// The session cannot be updated
+	ctrl.UpdateByToken(recorder, utils.FakeRequest("PATCH", "http://foo.bar/sessions/jhHgchgV", updateIn))
+	r.Equal(422, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.Validation, render.APIError)
+	utils.Clear(params, render, recorder)
+
+	inter.errJWT = false
+	inter.errNotFound = true
+
+	// Session not found
+	ctrl.UpdateByToken(recorder, utils.FakeRequest("PATCH", "http://foo.bar/sessions/jhHgchgV", updateIn))
+	r.Equal(404, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.NotFound, render.APIError)
+	utils.Clear(params, render, recorder)
+
+	inter.errNotFound = false
+	inter.errDB = true
+
+	// The interactor returns a database error
+	ctrl.UpdateByToken(recorder, utils.FakeRequest("PATCH", "http://foo.bar/sessions/jhHgchgV", updateIn))
+	r.Equal(500, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.Internal, render.APIError)
+	utils.Clear(params, render, recorder)
+}
+
+// TestSessionsCtrlUpdateByOwnerToken runs tests on the SessionsCtrl UpdateByOwnerToken method.
+func TestSessionsCtrlUpdateByOwnerToken(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	params := utils.NewFakeParamsGetter()
+	render := utils.NewFakeRender()
+	inter := &sessionsCtrlSessionsInter{}
+	valid := &sessionsCtrlSessionsValid{}
+	recorder := httptest.NewRecorder()
+	ctrl := NewSessionsCtrl(inter, render, params, valid)
+	updateIn := &models.SessionUpdate{Policies: []string{"admin"}}
+	sessionsOut := []models.Session{}
+
+	// Error: invalid query params
+	ctrl.UpdateByOwnerToken(recorder, utils.FakeRequest("PATCH", `http://foo.bar/sessions?ownerTokens=toto`, updateIn))
+	r.Equal(400, render.Status)
+	utils.Clear(params, render, recorder)
+
+	// Body decoding error
+	ctrl.UpdateByOwnerToken(recorder, utils.FakeRequestRaw("PATCH", `http://foo.bar/sessions?ownerTokens=["foobar"]`, []byte{'{'}))
+	r.Equal(400, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.BodyDecoding, render.APIError)
+	utils.Clear(params, render, recorder)
+
+	valid.errValid = true
+
+	// Validation error
+	ctrl.UpdateByOwnerToken(recorder, utils.FakeRequest("PATCH", `http://foo.bar/sessions?ownerTokens=["foobar"]`, updateIn))
+	r.Equal(422, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.Validation, render.APIError)
+	utils.Clear(params, render, recorder)
+
+	valid.errValid = false
+
+	// No error, the updated sessions are returned
+	ctrl.UpdateByOwnerToken(recorder, utils.FakeRequest("PATCH", `http://foo.bar/sessions?ownerTokens=["foobar"]`, updateIn))
+	r.Equal(200, render.Status)
+	err := json.NewDecoder(recorder.Body).Decode(&sessionsOut)
+	r.NoError(err)
+	r.Len(sessionsOut, 2)
+	a.Equal([]string{"admin"}, sessionsOut[0].Policies)
+	utils.Clear(params, render, recorder)
+
+	inter.errJWT = true
+
+	// One of the sessions cannot be updated
+	ctrl.UpdateByOwnerToken(recorder, utils.FakeRequest("PATCH", `http://foo.bar/sessions?ownerTokens=["foobar"]`, updateIn))
+	r.Equal(422, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.Validation, render.APIError)
+	utils.Clear(params, render, recorder)
+
+	inter.errJWT = false
+	inter.errDB = true
+
+	// The interactor returns a database error
+	ctrl.UpdateByOwnerToken(recorder, utils.FakeRequest("PATCH", `http://foo.bar/sessions?ownerTokens=["foobar"]`, updateIn))
+	r.Equal(500, render.Status)
+	r.NotNil(render.APIError)
+	a.IsType(errs.API.Internal, render.APIError)
+	utils.Clear(params, render, recorder)
+}
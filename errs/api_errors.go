@@ -11,6 +11,7 @@ type apiErrors struct {
 	Unauthorized *zest.APIError
 	BodyDecoding *zest.APIError
 	Validation   *zest.APIError
+	RateLimited  *zest.APIError
 }
 
 func init() {
@@ -21,6 +22,7 @@ func init() {
 		Unauthorized: &zest.APIError{Description: "Authorization Required.", ErrorCode: "AUTHORIZATION_REQUIRED"},
 		BodyDecoding: &zest.APIError{Description: "Could not decode the JSON request.", ErrorCode: "BODY_DECODING_ERROR"},
 		Validation:   &zest.APIError{Description: "The model validation failed.", ErrorCode: "VALIDATION_ERROR"},
+		RateLimited:  &zest.APIError{Description: "Too many requests. Please retry later.", ErrorCode: "RATE_LIMITED"},
 	}
 }
 
@@ -65,3 +67,14 @@ type validationResponse struct {
 	// in: body
 	Body zest.APIError
 }
+
+// Too many requests. Please retry later.
+// swagger:response RateLimitedResponse
+type rateLimitedResponse struct {
+	// The number of seconds after which the request would be accepted.
+	//
+	// in: header
+	RetryAfter int `json:"Retry-After"`
+	// in: body
+	Body zest.APIError
+}
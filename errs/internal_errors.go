@@ -1,5 +1,7 @@
 package errs
 
+import "time"
+
 var Internal *internalErrors
 
 type internalError struct {
@@ -14,6 +16,11 @@ type (
 	ErrDatabase   struct{ internalError }
 	ErrNotFound   struct{ internalError }
 	ErrValidation struct{ internalError }
+	// ErrRateLimited is returned when a rate limit is exceeded.
+	ErrRateLimited struct {
+		internalError
+		RetryAfter time.Duration // The time after which the request would be accepted
+	}
 )
 
 type internalErrors struct {
@@ -35,3 +42,9 @@ func NewErrValidation(description string) ErrValidation {
 	err.Description = description
 	return err
 }
+
+func NewErrRateLimited(description string, retryAfter time.Duration) ErrRateLimited {
+	err := ErrRateLimited{RetryAfter: retryAfter}
+	err.Description = description
+	return err
+}
@@ -0,0 +1,87 @@
+package interactors
+
+import (
+	"encoding/json"
+
+	"github.com/solher/auth-nginx-proxy-companion/errs"
+	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/boltdb/bolt"
+	"github.com/solher/zest"
+)
+
+func init() {
+	zest.Injector.Register(NewArchiveInter)
+}
+
+type (
+	ArchiveInterArchiveRepo interface {
+		ViewArchive(func(tx *bolt.Tx) error) error
+	}
+
+	ArchiveInterTokenHasher interface {
+		Hash(token string) string
+	}
+
+	// ArchiveInter reads the sessions archived by the garbage collector. The archive is laid out as the
+	// main database, with the "sessionOwners" and "sessionExpiries" indexes, and is never written here.
+	ArchiveInter struct {
+		r ArchiveInterArchiveRepo
+		h ArchiveInterTokenHasher
+	}
+)
+
+func NewArchiveInter(r ArchiveInterArchiveRepo, h ArchiveInterTokenHasher) *ArchiveInter {
+	return &ArchiveInter{r: r, h: h}
+}
+
+// FindSessions returns a page of the archived sessions matching the filter, in the order of their validity.
+func (i *ArchiveInter) FindSessions(filter *models.SessionFilter) (*models.SessionPage, error) {
+	after, err := decodeCursor(filter.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.SessionPage{Sessions: []models.Session{}}
+
+	err = i.r.ViewArchive(func(tx *bolt.Tx) error {
+		var err error
+		page, err = findPage(tx, filter, after, nil)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// FindSessionByToken returns the archived session of the token.
+func (i *ArchiveInter) FindSessionByToken(token string) (*models.Session, error) {
+	var raw []byte
+
+	err := i.r.ViewArchive(func(tx *bolt.Tx) error {
+		raw = tx.Bucket([]byte("sessions")).Get([]byte(i.h.Hash(token)))
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if raw == nil {
+		return nil, errs.Internal.NotFound
+	}
+
+	session := &models.Session{}
+
+	if err := json.Unmarshal(raw, session); err != nil {
+		return nil, err
+	}
+
+	session.Token = &token
+	session.RefreshToken = nil
+
+	return session, nil
+}
@@ -0,0 +1,73 @@
+package interactors
+
+import (
+	"testing"
+
+	"github.com/solher/auth-nginx-proxy-companion/errs"
+	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type archiveInterArchiveRepo struct {
+	err bool
+}
+
+func (r *archiveInterArchiveRepo) ViewArchive(t func(tx *bolt.Tx) error) error {
+	if r.err {
+		return errs.Internal.Database
+	}
+
+	return nil
+}
+
+// TestArchiveInterFindSessions runs tests on the ArchiveInter FindSessions method.
+func TestArchiveInterFindSessions(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	repo := &archiveInterArchiveRepo{}
+	inter := NewArchiveInter(repo, NewTokenHasher())
+
+	// Success
+	result, err := inter.FindSessions(&models.SessionFilter{OwnerToken: "owner1"})
+	r.NoError(err)
+	a.Len(result.Sessions, 0)
+	a.Empty(result.NextCursor)
+
+	// Invalid cursor
+	result, err = inter.FindSessions(&models.SessionFilter{Cursor: "c0"})
+	r.Error(err)
+	a.IsType(errs.ErrValidation{}, err)
+	a.Nil(result)
+
+	repo.err = true
+
+	// Database error
+	result, err = inter.FindSessions(&models.SessionFilter{})
+	r.Error(err)
+	a.IsType(errs.Internal.Database, err)
+	a.Nil(result)
+}
+
+// TestArchiveInterFindSessionByToken runs tests on the ArchiveInter FindSessionByToken method.
+func TestArchiveInterFindSessionByToken(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	repo := &archiveInterArchiveRepo{}
+	inter := NewArchiveInter(repo, NewTokenHasher())
+
+	// Not found
+	result, err := inter.FindSessionByToken("F00bAr")
+	r.Error(err)
+	a.IsType(errs.Internal.NotFound, err)
+	a.Nil(result)
+
+	repo.err = true
+
+	// Database error
+	result, err = inter.FindSessionByToken("F00bAr")
+	r.Error(err)
+	a.IsType(errs.Internal.Database, err)
+	a.Nil(result)
+}
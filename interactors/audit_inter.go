@@ -0,0 +1,208 @@
+package interactors
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+
+	"github.com/boltdb/bolt"
+	"github.com/solher/auth-nginx-proxy-companion/matchers"
+	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/solher/zest"
+)
+
+func init() {
+	zest.Injector.Register(NewAuditInter)
+}
+
+// The number of audit entries returned when no limit is given, and the maximum one.
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// The number of audit entries waiting to be written, beyond which the new ones are dropped.
+const auditQueueSize = 1000
+
+// errAuditQueueFull is returned when an entry is dropped because the writes are lagging behind.
+var errAuditQueueFull = errors.New("the audit queue is full")
+
+type (
+	AuditInterAuditRepo interface {
+		View(func(tx *bolt.Tx) error) error
+		Update(func(tx *bolt.Tx) error) error
+	}
+
+	// AuditInter records the audit entries in a bounded queue, written to the database in the background
+	// so the authorization requests never wait for a write. The entries are dropped and counted once the
+	// queue is full.
+	AuditInter struct {
+		r     AuditInterAuditRepo
+		queue chan *models.AuditEntry
+		stop  chan struct{}
+		done  chan struct{}
+
+		dropped, failed uint64
+	}
+)
+
+func NewAuditInter(r AuditInterAuditRepo) *AuditInter {
+	return &AuditInter{r: r, queue: make(chan *models.AuditEntry, auditQueueSize)}
+}
+
+// Record queues an entry for the audit log. It returns an error if the entry is dropped.
+func (i *AuditInter) Record(entry *models.AuditEntry) error {
+	select {
+	case i.queue <- entry:
+		return nil
+	default:
+		atomic.AddUint64(&i.dropped, 1)
+		return errAuditQueueFull
+	}
+}
+
+// Run writes the queued entries in the background.
+func (i *AuditInter) Run() {
+	i.stop = make(chan struct{})
+	i.done = make(chan struct{})
+
+	go i.run()
+}
+
+func (i *AuditInter) run() {
+	defer close(i.done)
+
+	for {
+		select {
+		case entry := <-i.queue:
+			i.write(entry)
+		case <-i.stop:
+			return
+		}
+	}
+}
+
+// Stop stops the background writes and writes the remaining entries.
+func (i *AuditInter) Stop() error {
+	if i.stop != nil {
+		close(i.stop)
+		<-i.done
+		i.stop = nil
+	}
+
+	if len(i.queue) == 0 {
+		return nil
+	}
+
+	return i.write(<-i.queue)
+}
+
+// Stats returns the counters of the audit queue.
+func (i *AuditInter) Stats() *models.AuditStats {
+	return &models.AuditStats{
+		Queued:    len(i.queue),
+		QueueSize: cap(i.queue),
+		Dropped:   atomic.LoadUint64(&i.dropped),
+		Failed:    atomic.LoadUint64(&i.failed),
+	}
+}
+
+// write appends the entry to the audit log, in the same transaction as the other queued entries.
+// The entries of a failed transaction are counted as lost.
+func (i *AuditInter) write(first *models.AuditEntry) error {
+	entries := []*models.AuditEntry{first}
+
+	// The queue is only read here, so the queued entries can't be taken meanwhile
+	for n := len(i.queue); n > 0; n-- {
+		entries = append(entries, <-i.queue)
+	}
+
+	err := i.r.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("audit"))
+
+		for _, entry := range entries {
+			id, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+
+			entry.ID = id
+
+			raw, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+
+			if err := b.Put(auditKey(id), raw); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		atomic.AddUint64(&i.failed, uint64(len(entries)))
+		return err
+	}
+
+	return nil
+}
+
+// Find returns the audit entries matching the filter, the most recent first.
+func (i *AuditInter) Find(filter *models.AuditFilter) ([]models.AuditEntry, error) {
+	entries := []models.AuditEntry{}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	if limit > maxAuditLimit {
+		limit = maxAuditLimit
+	}
+
+	hostname := matchers.NormalizeHost(filter.Hostname)
+
+	err := i.r.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("audit")).Cursor()
+
+		for k, v := c.Last(); k != nil && len(entries) < limit; k, v = c.Prev() {
+			entry := models.AuditEntry{}
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+
+			// The entries are sorted by time, so the older ones can't match either
+			if filter.Since != nil && entry.Time.Before(*filter.Since) {
+				break
+			}
+
+			switch {
+			case filter.Until != nil && !entry.Time.Before(*filter.Until),
+				filter.Resource != "" && (entry.Resource == nil || *entry.Resource != filter.Resource),
+				hostname != "" && matchers.NormalizeHost(entry.Hostname) != hostname,
+				filter.OwnerToken != "" && (entry.OwnerToken == nil || *entry.OwnerToken != filter.OwnerToken):
+				continue
+			}
+
+			entries = append(entries, entry)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// auditKey returns the big endian representation of the ID, so the keys are sorted like the IDs.
+func auditKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+
+	return key
+}
@@ -0,0 +1,112 @@
+package interactors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/solher/auth-nginx-proxy-companion/errs"
+	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/solher/auth-nginx-proxy-companion/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type auditInterAuditRepo struct {
+	err bool
+}
+
+func (r *auditInterAuditRepo) View(t func(tx *bolt.Tx) error) error {
+	if r.err {
+		return errs.Internal.Database
+	}
+
+	return nil
+}
+
+func (r *auditInterAuditRepo) Update(t func(tx *bolt.Tx) error) error {
+	if r.err {
+		return errs.Internal.Database
+	}
+
+	return nil
+}
+
+// TestAuditInterRecord runs tests on the AuditInter Record method.
+func TestAuditInterRecord(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	repo := &auditInterAuditRepo{}
+	inter := NewAuditInter(repo)
+	entry := &models.AuditEntry{
+		Time:     utils.TimeCpy(time.Now().UTC()),
+		Resource: utils.StrCpy("Foobar"),
+		Hostname: "foo.bar.com",
+		Path:     "/foo",
+	}
+
+	// Success: the entry is queued
+	err := inter.Record(entry)
+	r.NoError(err)
+	a.Equal(1, inter.Stats().Queued)
+
+	for idx := 1; idx < auditQueueSize; idx++ {
+		r.NoError(inter.Record(entry))
+	}
+
+	// Queue full: the entry is dropped
+	err = inter.Record(entry)
+	r.Error(err)
+	a.Equal(errAuditQueueFull, err)
+	a.Equal(auditQueueSize, inter.Stats().Queued)
+	a.Equal(uint64(1), inter.Stats().Dropped)
+}
+
+// TestAuditInterWrite runs tests on the AuditInter background writes.
+func TestAuditInterWrite(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	repo := &auditInterAuditRepo{}
+	inter := NewAuditInter(repo)
+	entry := &models.AuditEntry{Time: utils.TimeCpy(time.Now().UTC()), Hostname: "foo.bar.com"}
+
+	// Success: the remaining entries are written on stop
+	inter.Run()
+	r.NoError(inter.Record(entry))
+	r.NoError(inter.Record(entry))
+	r.NoError(inter.Stop())
+	a.Equal(0, inter.Stats().Queued)
+	a.Equal(uint64(0), inter.Stats().Failed)
+
+	repo.err = true
+
+	// Database error: the entries are lost
+	r.NoError(inter.Record(entry))
+	r.NoError(inter.Record(entry))
+	err := inter.Stop()
+	r.Error(err)
+	a.IsType(errs.Internal.Database, err)
+	a.Equal(0, inter.Stats().Queued)
+	a.Equal(uint64(2), inter.Stats().Failed)
+}
+
+// TestAuditInterFind runs tests on the AuditInter Find method.
+func TestAuditInterFind(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	repo := &auditInterAuditRepo{}
+	inter := NewAuditInter(repo)
+
+	// Success
+	result, err := inter.Find(&models.AuditFilter{Hostname: "foo.bar.com"})
+	r.NoError(err)
+	a.Equal(0, len(result))
+
+	repo.err = true
+
+	// Database error
+	result, err = inter.Find(&models.AuditFilter{})
+	r.Error(err)
+	a.IsType(errs.Internal.Database, err)
+	a.Nil(result)
+}
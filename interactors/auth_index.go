@@ -0,0 +1,604 @@
+package interactors
+
+import (
+	"encoding/json"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/solher/auth-nginx-proxy-companion/errs"
+	"github.com/solher/auth-nginx-proxy-companion/matchers"
+	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/solher/auth-nginx-proxy-companion/utils"
+	"github.com/solher/zest"
+)
+
+func init() {
+	zest.Injector.Register(NewAuthIndex)
+}
+
+type (
+	AuthIndexRepo interface {
+		View(func(tx *bolt.Tx) error) error
+	}
+
+	// AuthIndex keeps a compiled copy of the resources and policies used by the authorization hot path.
+	// Readers never lock: they load an immutable snapshot which is atomically replaced on each rebuild.
+	AuthIndex struct {
+		r          AuthIndexRepo
+		mu         sync.Mutex // Serializes the rebuilds
+		generation uint64
+		snapshot   atomic.Value
+	}
+
+	// AuthSnapshot is an immutable compiled view of the resources and policies.
+	AuthSnapshot struct {
+		generation uint64                      // Incremented on each rebuild
+		resources  map[string][]hostedResource // By normalized host name and alias, longest prefix first
+		policies   map[string]*compiledPolicy  // By name
+		networks   map[string]*networks        // The client IP restrictions by resource name
+		// The compiled resources and policies, kept to build proposals upon.
+		sourceResources []models.Resource
+		sourcePolicies  []models.Policy
+	}
+
+	hostedResource struct {
+		resource *models.Resource
+		prefix   []string
+	}
+
+	compiledPolicy struct {
+		name    string
+		enabled bool
+		window  *window // nil if the policy is always valid
+		// Indicates if the policy or any of its permissions has a window, so its result depends on the time.
+		windowed bool
+		// The rate limits of the sessions having the policy.
+		rateLimits []models.RateLimit
+		// The policy permissions followed by the inherited ones, in evaluation order.
+		permissions []*compiledPermission
+		// The enabled permissions by resource name, indexed by the literal prefix of their path.
+		tries map[string]*permissionNode
+	}
+
+	compiledPermission struct {
+		position      int
+		resource      string
+		inheritedFrom string
+		index         int
+		path          string
+		pattern       *matchers.Path // nil if the path is malformed
+		methods       []string
+		conditions    []*matchers.Condition // nil if a condition is malformed
+		rawConditions []string
+		windows       []*window // The permission window and the ones of the policies it is inherited from
+		networks      *networks // nil if the permission applies from any client IP
+		allowCIDRs    []string
+		denyCIDRs     []string
+		deny          bool
+		enabled       bool
+		malformed     bool // A denial with a malformed path, condition or range, which matches any request
+		rank          rank
+	}
+
+	// window is a compiled validity window.
+	window struct {
+		from, to  *time.Time
+		schedules []*matchers.Schedule
+		invalid   bool // A malformed schedule never opens the window
+	}
+
+	// networks are compiled client IP restrictions.
+	networks struct {
+		allow, deny []*net.IPNet
+		invalid     bool // A malformed range never permits anything
+	}
+
+	permissionNode struct {
+		children    map[string]*permissionNode
+		permissions []*compiledPermission
+	}
+)
+
+func NewAuthIndex(r AuthIndexRepo) *AuthIndex {
+	idx := &AuthIndex{r: r}
+	idx.snapshot.Store(compileSnapshot(nil, nil))
+
+	return idx
+}
+
+// Rebuild reloads the resources and policies from the database and replaces the current snapshot.
+func (idx *AuthIndex) Rebuild() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	resources := []models.Resource{}
+	policies := []models.Policy{}
+
+	// Everything is read in the same transaction so the snapshot is consistent
+	err := idx.r.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("resources")).Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			resource := models.Resource{}
+			if err := json.Unmarshal(v, &resource); err != nil {
+				return err
+			}
+			resources = append(resources, resource)
+		}
+
+		c = tx.Bucket([]byte("policies")).Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			policy := models.Policy{}
+			if err := json.Unmarshal(v, &policy); err != nil {
+				return err
+			}
+			policies = append(policies, policy)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	idx.store(compileSnapshot(resources, policies))
+
+	return nil
+}
+
+// Load replaces the current snapshot with the given resources and policies.
+func (idx *AuthIndex) Load(resources []models.Resource, policies []models.Policy) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.store(compileSnapshot(resources, policies))
+}
+
+// store must be called with the lock held.
+func (idx *AuthIndex) store(s *AuthSnapshot) {
+	idx.generation++
+	s.generation = idx.generation
+
+	idx.snapshot.Store(s)
+}
+
+// Snapshot returns the current snapshot.
+func (idx *AuthIndex) Snapshot() *AuthSnapshot {
+	return idx.snapshot.Load().(*AuthSnapshot)
+}
+
+// Generation returns the generation of the snapshot.
+// Anything computed from a snapshot is outdated once the generation changes.
+func (s *AuthSnapshot) Generation() uint64 {
+	return s.generation
+}
+
+// Resource returns the resource serving the given host name and path.
+// An exact host name match takes precedence over a wildcard one,
+// then the resource with the longest matching path prefix is selected.
+func (s *AuthSnapshot) Resource(hostname, path string) (*models.Resource, error) {
+	host := matchers.NormalizeHost(hostname)
+	reqPath := matchers.SplitPath(path)
+
+	for _, key := range [2]string{host, matchers.WildcardHost(host)} {
+		for _, hosted := range s.resources[key] {
+			if matchers.HasPrefix(reqPath, hosted.prefix) {
+				return hosted.resource, nil
+			}
+		}
+	}
+
+	return nil, errs.Internal.NotFound
+}
+
+// Propose compiles a detached snapshot from the given proposal, without replacing the current one.
+// A proposed config replaces all the resources and policies, then a proposed policy replaces the one of the same name.
+func (s *AuthSnapshot) Propose(config *models.SimulationConfig, policy *models.Policy) *AuthSnapshot {
+	resources := s.sourceResources
+	policies := s.sourcePolicies
+
+	if config != nil {
+		resources = config.Resources
+		policies = config.Policies
+
+		// Like with an imported config file, the guest policy always exists
+		if !hasPolicy(policies, "guest") {
+			policies = append(policies[:len(policies):len(policies)], models.Policy{Name: utils.StrCpy("guest"), Permissions: []models.Permission{}})
+		}
+	}
+
+	if policy != nil {
+		proposed := make([]models.Policy, 0, len(policies)+1)
+
+		for _, p := range policies {
+			if *p.Name != *policy.Name {
+				proposed = append(proposed, p)
+			}
+		}
+
+		policies = append(proposed, *policy)
+	}
+
+	return compileSnapshot(resources, policies)
+}
+
+func hasPolicy(policies []models.Policy, name string) bool {
+	for _, policy := range policies {
+		if *policy.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *AuthSnapshot) policy(name string) (*compiledPolicy, error) {
+	policy, ok := s.policies[name]
+	if !ok {
+		return nil, errs.Internal.NotFound
+	}
+
+	return policy, nil
+}
+
+func compileSnapshot(resources []models.Resource, policies []models.Policy) *AuthSnapshot {
+	s := &AuthSnapshot{
+		resources: make(map[string][]hostedResource, len(resources)),
+		policies:  make(map[string]*compiledPolicy, len(policies)),
+		networks:  make(map[string]*networks, len(resources)),
+
+		sourceResources: resources,
+		sourcePolicies:  policies,
+	}
+
+	for idx := range resources {
+		resource := resources[idx]
+		hosted := hostedResource{resource: &resource}
+
+		if resource.PathPrefix != nil {
+			hosted.prefix = matchers.PrefixSegments(*resource.PathPrefix)
+		}
+
+		if n := compileNetworks(resource.AllowCIDRs, resource.DenyCIDRs); n != nil {
+			s.networks[*resource.Name] = n
+		}
+
+		for _, host := range append([]string{*resource.Hostname}, resource.Aliases...) {
+			host = matchers.NormalizeHost(host)
+			s.resources[host] = append(s.resources[host], hosted)
+		}
+	}
+
+	for _, hosted := range s.resources {
+		sort.Sort(byPrefixLength(hosted))
+	}
+
+	byName := make(map[string]*models.Policy, len(policies))
+	for idx := range policies {
+		byName[*policies[idx].Name] = &policies[idx]
+	}
+
+	for name, policy := range byName {
+		s.policies[name] = compilePolicy(policy, byName)
+	}
+
+	return s
+}
+
+// compilePolicy flattens the permissions of a policy with the ones of the policies it extends, recursively.
+// Disabled or missing extended policies are ignored, as well as the inheritance cycles.
+func compilePolicy(policy *models.Policy, policies map[string]*models.Policy) *compiledPolicy {
+	p := &compiledPolicy{
+		name:    *policy.Name,
+		enabled: policy.Enabled == nil || *policy.Enabled,
+		window:  compileWindow(policy.Window),
+		tries:   map[string]*permissionNode{},
+
+		rateLimits: policy.RateLimits,
+	}
+
+	p.windowed = p.window != nil
+
+	// The ancestors on the current path break the cycles. A policy reached through several paths, as in a diamond,
+	// is flattened once per chain of windows, so the permissions inherited without window are never lost.
+	ancestors := map[string]bool{p.name: true}
+	flattened := map[[2]string]bool{}
+
+	// The inherited permissions are restricted to the windows of the extended policies, named by the chain
+	var flatten func(policy *models.Policy, inheritedFrom string, windows []*window, chain string)
+	flatten = func(policy *models.Policy, inheritedFrom string, windows []*window, chain string) {
+		for idx, permission := range policy.Permissions {
+			p.addPermission(permission, inheritedFrom, idx, windows)
+		}
+
+		for _, name := range policy.Extends {
+			if ancestors[name] || flattened[[2]string{name, chain}] {
+				continue
+			}
+			flattened[[2]string{name, chain}] = true
+
+			parent, ok := policies[name]
+			if !ok || (parent.Enabled != nil && *parent.Enabled == false) {
+				continue
+			}
+
+			inherited, inheritedChain := windows, chain
+			if w := compileWindow(parent.Window); w != nil {
+				inherited = append(windows[:len(windows):len(windows)], w)
+				inheritedChain = chain + "/" + name
+			}
+
+			ancestors[name] = true
+			flatten(parent, name, inherited, inheritedChain)
+			delete(ancestors, name)
+		}
+	}
+
+	flatten(policy, "", nil, "")
+
+	return p
+}
+
+func (p *compiledPolicy) addPermission(permission models.Permission, inheritedFrom string, index int, windows []*window) {
+	// nil paths is considered as a wildcard
+	paths := permission.Paths
+	if paths == nil {
+		paths = []string{"*"}
+	}
+
+	// The conditions are shared by all the paths of the permission
+	conditions := make([]*matchers.Condition, 0, len(permission.Conditions))
+	for _, raw := range permission.Conditions {
+		condition, err := matchers.CompileCondition(raw)
+		if err != nil {
+			conditions = nil
+			break
+		}
+		conditions = append(conditions, condition)
+	}
+
+	if w := compileWindow(permission.Window); w != nil {
+		windows = append(windows[:len(windows):len(windows)], w)
+	}
+
+	networks := compileNetworks(permission.AllowCIDRs, permission.DenyCIDRs)
+
+	if len(windows) != 0 {
+		p.windowed = true
+	}
+
+	for _, path := range paths {
+		c := &compiledPermission{
+			position:      len(p.permissions),
+			resource:      *permission.Resource,
+			inheritedFrom: inheritedFrom,
+			index:         index,
+			path:          path,
+			methods:       permission.Methods,
+			conditions:    conditions,
+			rawConditions: permission.Conditions,
+			windows:       windows,
+			networks:      networks,
+			allowCIDRs:    permission.AllowCIDRs,
+			denyCIDRs:     permission.DenyCIDRs,
+			deny:          permission.Deny != nil && *permission.Deny,
+			enabled:       permission.Enabled == nil || *permission.Enabled,
+			rank:          rank{methodSpecific: len(permission.Methods) != 0},
+		}
+
+		// Malformed permissions are rejected by the validator but we never let them grant anything.
+		// A malformed denial can't tell which requests it targets, so it denies them all
+		if pattern, err := matchers.CompilePath(path); err == nil {
+			c.pattern = pattern
+			c.rank.specificity = pattern.Specificity()
+		}
+
+		c.malformed = c.deny && (c.pattern == nil || c.conditions == nil || (networks != nil && networks.invalid))
+		c.rank.malformed = c.malformed
+
+		p.permissions = append(p.permissions, c)
+
+		if !c.enabled || (!c.malformed && (c.pattern == nil || c.conditions == nil)) {
+			continue
+		}
+
+		node := p.tries[c.resource]
+		if node == nil {
+			node = &permissionNode{}
+			p.tries[c.resource] = node
+		}
+
+		// A malformed denial is walked for any request path
+		prefix := []string{}
+		if !c.malformed {
+			prefix = c.pattern.LiteralPrefix()
+		}
+
+		for _, segment := range prefix {
+			if node.children == nil {
+				node.children = map[string]*permissionNode{}
+			}
+
+			child := node.children[segment]
+			if child == nil {
+				child = &permissionNode{}
+				node.children[segment] = child
+			}
+
+			node = child
+		}
+
+		node.permissions = append(node.permissions, c)
+	}
+}
+
+// walk calls fn for each enabled permission which can match the request path on the given resource.
+// Only the trie branches following the request path are visited.
+func (p *compiledPolicy) walk(resource string, reqPath []string, fn func(permission *compiledPermission)) {
+	keys := [2]string{resource, "*"}
+
+	for idx, key := range keys {
+		if idx == 1 && resource == "*" {
+			break
+		}
+
+		node := p.tries[key]
+
+		for depth := 0; node != nil; depth++ {
+			for _, permission := range node.permissions {
+				fn(permission)
+			}
+
+			if depth == len(reqPath) {
+				break
+			}
+
+			node = node.children[reqPath[depth]]
+		}
+	}
+}
+
+// matchConditions checks that all the permission conditions hold over the given session attributes.
+func (c *compiledPermission) matchConditions(attributes map[string]interface{}) bool {
+	for _, condition := range c.conditions {
+		if !condition.Match(attributes) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// open checks that the permission is within its window and the ones of the policies it is inherited from.
+func (c *compiledPermission) open(now time.Time) bool {
+	for _, w := range c.windows {
+		if !w.open(now) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compileWindow returns nil if no window is given.
+func compileWindow(w *models.Window) *window {
+	if w == nil {
+		return nil
+	}
+
+	c := &window{from: w.From, to: w.To}
+
+	for _, schedule := range w.Schedules {
+		compiled, err := matchers.CompileSchedule(schedule.Days, schedule.From, schedule.To, schedule.TimeZone)
+		if err != nil {
+			c.invalid = true
+			break
+		}
+		c.schedules = append(c.schedules, compiled)
+	}
+
+	return c
+}
+
+// open checks that the given time is between the window bounds and, if the window has schedules, in any of them.
+// A nil window is always open.
+func (w *window) open(now time.Time) bool {
+	switch {
+	case w == nil:
+		return true
+	case w.invalid:
+		return false
+	case w.from != nil && now.Before(*w.from):
+		return false
+	case w.to != nil && !now.Before(*w.to):
+		return false
+	case len(w.schedules) == 0:
+		return true
+	}
+
+	for _, schedule := range w.schedules {
+		if schedule.Contains(now) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resourceNetworks returns the client IP restrictions of the given resource, nil if there are none.
+func (s *AuthSnapshot) resourceNetworks(resource *models.Resource) *networks {
+	return s.networks[*resource.Name]
+}
+
+// compileNetworks returns nil if no range is given.
+func compileNetworks(allow, deny []string) *networks {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil
+	}
+
+	n := &networks{}
+	var err error
+
+	if n.allow, err = matchers.ParseNetworks(allow); err != nil {
+		return &networks{invalid: true}
+	}
+
+	if n.deny, err = matchers.ParseNetworks(deny); err != nil {
+		return &networks{invalid: true}
+	}
+
+	return n
+}
+
+// permits checks that the client IP is in an allowed range, if any, and in no denied one.
+// An unknown client IP is never in a range. Nil networks permit any client IP.
+func (n *networks) permits(ip net.IP) bool {
+	switch {
+	case n == nil:
+		return true
+	case n.invalid:
+		return false
+	case matchers.ContainsIP(n.deny, ip):
+		return false
+	case len(n.allow) != 0 && !matchers.ContainsIP(n.allow, ip):
+		return false
+	}
+
+	return true
+}
+
+// windowed indicates if the result of any of the given policies depends on the time.
+func (s *AuthSnapshot) windowed(policies []string) bool {
+	for _, name := range policies {
+		if policy, ok := s.policies[name]; ok && policy.windowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// forResource returns all the permissions concerning the given resource.
+func (p *compiledPolicy) forResource(resource string) []*compiledPermission {
+	permissions := []*compiledPermission{}
+
+	for _, permission := range p.permissions {
+		if permission.resource == resource || permission.resource == "*" {
+			permissions = append(permissions, permission)
+		}
+	}
+
+	return permissions
+}
+
+type byPrefixLength []hostedResource
+
+func (s byPrefixLength) Len() int           { return len(s) }
+func (s byPrefixLength) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byPrefixLength) Less(i, j int) bool { return len(s[i].prefix) > len(s[j].prefix) }
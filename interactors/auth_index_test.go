@@ -0,0 +1,170 @@
+package interactors
+
+import (
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/solher/auth-nginx-proxy-companion/errs"
+	"github.com/solher/auth-nginx-proxy-companion/matchers"
+	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/solher/auth-nginx-proxy-companion/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type authIndexRepo struct {
+	err bool
+}
+
+func (r *authIndexRepo) View(t func(tx *bolt.Tx) error) error {
+	if r.err {
+		return errs.Internal.Database
+	}
+
+	return nil
+}
+
+// TestAuthIndexRebuild runs tests on the AuthIndex Rebuild method.
+func TestAuthIndexRebuild(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	repo := &authIndexRepo{}
+	index := NewAuthIndex(repo)
+	index.Load([]models.Resource{*testResource}, nil)
+
+	// Success
+	err := index.Rebuild()
+	r.NoError(err)
+
+	repo.err = true
+	index.Load([]models.Resource{*testResource}, nil)
+
+	// Database error: the current snapshot is kept
+	err = index.Rebuild()
+	r.Error(err)
+	a.IsType(errs.Internal.Database, err)
+	_, err = index.Snapshot().Resource("foo.bar.com", "/")
+	a.NoError(err)
+}
+
+// TestAuthIndexLoad runs tests on the AuthIndex Load method.
+func TestAuthIndexLoad(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	index := newAuthInterIndex()
+	snapshot := index.Snapshot()
+
+	// Success: resource
+	resource, err := snapshot.Resource("foo.bar.com", "/")
+	r.NoError(err)
+	a.Equal(testResource, resource)
+
+	// Not found: resource
+	_, err = snapshot.Resource("bar.foo.com", "/")
+	r.Error(err)
+	a.IsType(errs.Internal.NotFound, err)
+
+	// Not found: policy
+	_, err = snapshot.policy("Qux")
+	r.Error(err)
+	a.IsType(errs.Internal.NotFound, err)
+
+	// Success: inherited permissions are flattened after the policy ones
+	policy, err := snapshot.policy("Baz")
+	r.NoError(err)
+	r.Len(policy.permissions, 11)
+	a.Empty(policy.permissions[0].inheritedFrom)
+	a.Equal("Foo", policy.permissions[1].inheritedFrom)
+
+	walked := func(path string) []string {
+		paths := []string{}
+		policy.walk("Foobar", matchers.SplitPath(path), func(permission *compiledPermission) {
+			paths = append(paths, permission.path)
+		})
+		return paths
+	}
+
+	// Success: only the permissions under the request path are walked
+	a.ElementsMatch([]string{"/foo/baz", "/foo/*", "*"}, walked("/foo/baz"))
+	a.ElementsMatch([]string{"/users/*/profile", "*"}, walked("/users/42/profile"))
+	a.ElementsMatch([]string{"/bar", "/bar", "*"}, walked("/bar"))
+
+	wildcard := models.Resource{
+		Name:     utils.StrCpy("Previews"),
+		Hostname: utils.StrCpy("*.Bar.com"),
+		Aliases:  []string{"bar.com", "*.staging.bar.com"},
+	}
+	index.Load([]models.Resource{*testResource, wildcard}, nil)
+	snapshot = index.Snapshot()
+
+	hosts := map[string]string{
+		"foo.bar.com":            "Foobar", // The exact host name wins
+		"FOO.bar.com:8080":       "Foobar",
+		"baz.bar.com":            "Previews",
+		"bar.com.":               "Previews",
+		"pr-1.staging.bar.com":   "Previews",
+		"a.baz.bar.com":          "",
+		"staging.bar.com":        "Previews",
+		"a.pr-1.staging.bar.com": "",
+	}
+
+	for host, name := range hosts {
+		resource, err := snapshot.Resource(host, "/")
+		if name == "" {
+			a.Error(err, host)
+			continue
+		}
+
+		if a.NoError(err, host) {
+			a.Equal(name, *resource.Name, host)
+		}
+	}
+
+	grafana := models.Resource{
+		Name:       utils.StrCpy("Grafana"),
+		Hostname:   utils.StrCpy("tools.bar.com"),
+		PathPrefix: utils.StrCpy("/grafana"),
+	}
+	dashboards := models.Resource{
+		Name:       utils.StrCpy("Dashboards"),
+		Hostname:   utils.StrCpy("tools.bar.com"),
+		PathPrefix: utils.StrCpy("/grafana/dashboards"),
+	}
+	tools := models.Resource{
+		Name:     utils.StrCpy("Tools"),
+		Hostname: utils.StrCpy("tools.bar.com"),
+	}
+	index.Load([]models.Resource{grafana, dashboards, tools}, nil)
+	snapshot = index.Snapshot()
+
+	paths := map[string]string{
+		"/grafana":               "Grafana",
+		"/grafana/api/health":    "Grafana",
+		"/grafana/dashboards/42": "Dashboards", // The longest prefix wins
+		"/grafanas":              "Tools",      // Whole segments are compared
+		"/kibana":                "Tools",
+		"/":                      "Tools",
+	}
+
+	for path, name := range paths {
+		resource, err := snapshot.Resource("tools.bar.com", path)
+		if a.NoError(err, path) {
+			a.Equal(name, *resource.Name, path)
+		}
+	}
+
+	index.Load([]models.Resource{grafana}, nil)
+
+	// Not found: no resource serves the path
+	_, err = index.Snapshot().Resource("tools.bar.com", "/kibana")
+	r.Error(err)
+	a.IsType(errs.Internal.NotFound, err)
+
+	index.Load(nil, nil)
+
+	// The loaded snapshot is replaced, the previous one is unchanged
+	_, err = index.Snapshot().Resource("tools.bar.com", "/")
+	a.Error(err)
+	_, err = snapshot.Resource("tools.bar.com", "/")
+	a.NoError(err)
+}
@@ -1,9 +1,15 @@
 package interactors
 
 import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/solher/auth-nginx-proxy-companion/errs"
+	"github.com/solher/auth-nginx-proxy-companion/matchers"
 	"github.com/solher/auth-nginx-proxy-companion/models"
 	"github.com/solher/zest"
 )
@@ -12,40 +18,86 @@ func init() {
 	zest.Injector.Register(NewAuthInter)
 }
 
+// The permission statuses reported in the decision traces.
+const (
+	statusApplied        = "applied"
+	statusOverridden     = "overridden"
+	statusNoMatch        = "no match"
+	statusMethodMismatch = "method mismatch"
+	statusDisabled       = "disabled"
+	statusInvalidPath    = "invalid path"
+	statusInvalidCond    = "invalid condition"
+	statusCondMismatch   = "condition mismatch"
+	statusOutsideWindow  = "outside window"
+	statusIPMismatch     = "client IP mismatch"
+	statusInvalidCIDR    = "invalid CIDR"
+)
+
 type (
-	AuthInterPoliciesInter interface {
-		FindByName(name string) (*models.Policy, error)
+	AuthInterAuthIndex interface {
+		Snapshot() *AuthSnapshot
+	}
+
+	AuthInterDecisionCache interface {
+		Get(generation uint64, hostname, path, method, clientIP, token string) (bool, *models.Session, bool)
+		Set(generation uint64, hostname, path, method, clientIP, token string, granted bool, session *models.Session)
+		Stats() *models.CacheStats
 	}
 
-	AuthInterResourcesInter interface {
-		FindByHostname(hostname string) (*models.Resource, error)
+	AuthInterRateLimiter interface {
+		Take(limits []Limit) (bool, time.Duration)
+	}
+
+	AuthInterAuditInter interface {
+		Record(entry *models.AuditEntry) error
 	}
 
 	AuthInterSessionsInter interface {
+		Find() ([]models.Session, error)
 		FindByToken(id string) (*models.Session, error)
+		Touch(token string)
+	}
+
+	AuthInterOptionsGetter interface {
+		GetCombiningAlgorithm() string
 	}
 
 	AuthInter struct {
-		policiesInter  AuthInterPoliciesInter
-		resourcesInter AuthInterResourcesInter
-		sessionsInter  AuthInterSessionsInter
+		index         AuthInterAuthIndex
+		cache         AuthInterDecisionCache
+		limiter       AuthInterRateLimiter
+		auditInter    AuthInterAuditInter
+		sessionsInter AuthInterSessionsInter
+		g             AuthInterOptionsGetter
+	}
+
+	// policyRule is the permission deciding the result of a session policy.
+	policyRule struct {
+		policy     string
+		permission *compiledPermission
 	}
 )
 
 func NewAuthInter(
-	policiesInter AuthInterPoliciesInter,
-	resourcesInter AuthInterResourcesInter,
+	index AuthInterAuthIndex,
+	cache AuthInterDecisionCache,
+	limiter AuthInterRateLimiter,
+	auditInter AuthInterAuditInter,
 	sessionsInter AuthInterSessionsInter,
+	g AuthInterOptionsGetter,
 ) *AuthInter {
 	return &AuthInter{
-		policiesInter:  policiesInter,
-		resourcesInter: resourcesInter,
-		sessionsInter:  sessionsInter,
+		index:         index,
+		cache:         cache,
+		limiter:       limiter,
+		auditInter:    auditInter,
+		sessionsInter: sessionsInter,
+		g:             g,
 	}
 }
 
-func (i *AuthInter) GetRedirectURL(hostname string) (string, error) {
-	resource, err := i.resourcesInter.FindByHostname(hostname)
+func (i *AuthInter) GetRedirectURL(hostname, path string) (string, error) {
+	resource, err := i.index.Snapshot().Resource(hostname, path)
 	if err != nil {
 		return "", err
 	}
@@ -57,228 +109,699 @@ func (i *AuthInter) GetRedirectURL(hostname string) (string, error) {
 	return *resource.RedirectURL, nil
 }
 
-func (i *AuthInter) AuthorizeToken(hostname, path, token string) (bool, *models.Session, error) {
-	// We try to find concurrently the resource and the session corresponding to the request
-	resourceCh, errCh1 := i.findResource(hostname)
-	sessionCh, errCh2 := i.findSession(token)
+func (i *AuthInter) AuthorizeToken(hostname, path, method, clientIP, token string) (bool, *models.Session, error) {
+	snapshot := i.index.Snapshot()
 
-	// If we can't find a resource, we deny the access
-	if err := <-errCh1; err != nil {
+	granted, session, err := i.authorize(snapshot, hostname, path, method, clientIP, token)
+	if err != nil || !granted {
+		return granted, session, err
+	}
+
+	// Only the granted requests are rate limited, the denied ones never reach the backends
+	if err := i.limit(snapshot, hostname, path, method, clientIP, token, session); err != nil {
 		return false, nil, err
 	}
 
-	resource := <-resourceCh
+	// Keeps the session alive. The guests have no session
+	if session != nil {
+		i.sessionsInter.Touch(*session.Token)
+	}
 
-	// If the found resource is marked as public, we allow the access without restriction
-	if resource.Public != nil && *resource.Public {
-		return true, nil, nil
+	return true, session, nil
+}
+
+func (i *AuthInter) authorize(
+	snapshot *AuthSnapshot,
+	hostname, path, method, clientIP, token string,
+) (bool, *models.Session, error) {
+	// The same request is usually repeated for each asset of a page
+	if granted, session, ok := i.cache.Get(snapshot.Generation(), hostname, path, method, clientIP, token); ok {
+		return granted, session, nil
 	}
 
-	// If no session is found for the token, we initiate a guest session
-	// Otherwise, the access is denied with an error
-	if err := <-errCh2; err != nil {
+	decision, err := i.decide(snapshot, hostname, path, method, clientIP, token, false)
+
+	// In report mode, the access is granted anyway and the denial is only audited
+	// The decision is not cached so every would-be denial is recorded
+	if !decision.Granted && i.reports(decision.Resource) {
+		switch err.(type) {
+		case nil, errs.ErrNotFound:
+			i.audit(decision)
+			return true, decision.Session, nil
+		}
+	}
+
+	if err != nil {
+		return false, nil, err
+	}
+
+	// A decision depending on the time can't be reused
+	// No policy is evaluated if the resource is public
+	cacheable := decision.Algorithm == "" || !snapshot.windowed(i.policiesOf(decision.Session))
+
+	// The session is only returned when it was used to grant the access
+	if !decision.Granted {
+		decision.Session = nil
+	}
+
+	if cacheable {
+		i.cache.Set(snapshot.Generation(), hostname, path, method, clientIP, token, decision.Granted, decision.Session)
+	}
+
+	return decision.Granted, decision.Session, nil
+}
+
+// limit takes a token from the buckets of the rate limits of the resource and of the session policies.
+// In report mode, the exceeded limits are only audited.
+func (i *AuthInter) limit(
+	snapshot *AuthSnapshot,
+	hostname, path, method, clientIP, token string,
+	session *models.Session,
+) error {
+	resource, err := snapshot.Resource(hostname, path)
+	if err != nil {
+		return nil
+	}
+
+	limits := []Limit{}
+
+	add := func(scope string, rateLimits []models.RateLimit) {
+		for idx, limit := range rateLimits {
+			if limit.By == nil || limit.Rate == nil || *limit.Rate <= 0 {
+				continue
+			}
+
+			value := ""
+
+			switch *limit.By {
+			case models.LimitByToken:
+				value = token
+			case models.LimitByOwnerToken:
+				if session != nil && session.OwnerToken != nil {
+					value = *session.OwnerToken
+				}
+			case models.LimitByClientIP:
+				value = clientIP
+			case models.LimitByResource:
+				value = *resource.Name
+			}
+
+			// The requests without the key are not limited
+			if value == "" {
+				continue
+			}
+
+			burst := int(math.Ceil(*limit.Rate))
+			if limit.Burst != nil {
+				burst = *limit.Burst
+			}
+
+			limits = append(limits, Limit{
+				Key:   fmt.Sprintf("%s\x00%d\x00%s\x00%s", scope, idx, *limit.By, value),
+				Rate:  *limit.Rate,
+				Burst: burst,
+			})
+		}
+	}
+
+	add("resource/"+*resource.Name, resource.RateLimits)
+
+	// No policy is evaluated if the resource is public
+	if resource.Public == nil || !*resource.Public {
+		now := time.Now()
+
+		for _, name := range i.policiesOf(session) {
+			if policy, err := snapshot.policy(name); err == nil && policy.enabled && policy.window.open(now) {
+				add("policy/"+name, policy.rateLimits)
+			}
+		}
+	}
+
+	if len(limits) == 0 {
+		return nil
+	}
+
+	ok, retryAfter := i.limiter.Take(limits)
+	if ok {
+		return nil
+	}
+
+	decision := &models.Decision{
+		Hostname: hostname,
+		Path:     path,
+		Method:   method,
+		ClientIP: clientIP,
+		Resource: resource,
+		Session:  session,
+		Reason:   "a rate limit is exceeded",
+	}
+
+	if i.reports(resource) {
+		i.audit(decision)
+		return nil
+	}
+
+	return errs.NewErrRateLimited(decision.Reason, retryAfter)
+}
+
+// policiesOf returns the policies evaluated for the given session. A nil session is evaluated as a guest.
+func (i *AuthInter) policiesOf(session *models.Session) []string {
+	if session == nil {
+		return []string{"guest"}
+	}
+
+	return session.Policies
+}
+
+func (i *AuthInter) reports(resource *models.Resource) bool {
+	return resource != nil && resource.Mode != nil && *resource.Mode == models.ModeReport
+}
+
+func (i *AuthInter) audit(decision *models.Decision) {
+	now := time.Now().UTC()
+
+	entry := &models.AuditEntry{
+		Time:      &now,
+		Resource:  decision.Resource.Name,
+		Hostname:  decision.Hostname,
+		Path:      decision.Path,
+		Method:    decision.Method,
+		ClientIP:  decision.ClientIP,
+		Guest:     decision.Session == nil,
+		Algorithm: decision.Algorithm,
+		Reason:    decision.Reason,
+		Rule:      decision.Rule,
+	}
+
+	if decision.Session != nil {
+		entry.OwnerToken = decision.Session.OwnerToken
+		entry.Policies = decision.Session.Policies
+	}
+
+	// A failing record must not deny the access to a resource in report mode
+	i.auditInter.Record(entry)
+}
+
+// CacheStats returns the counters of the decision cache.
+func (i *AuthInter) CacheStats() *models.CacheStats {
+	return i.cache.Stats()
+}
+
+// Explain runs the same evaluation as AuthorizeToken but returns the whole decision trace.
+func (i *AuthInter) Explain(hostname, path, method, clientIP, token string) (*models.Decision, error) {
+	decision, err := i.decide(i.index.Snapshot(), hostname, path, method, clientIP, token, true)
+	if err != nil {
 		switch err.(type) {
 		case errs.ErrNotFound:
-			return i.authorizeGuestSession(path, *resource.Name)
+			// A denial is a valid explanation if the resource or a policy was not found
+			return decision, nil
 		default:
-			return false, nil, err
+			return nil, err
 		}
 	}
 
-	// If a session is found, we try to authorize it
-	return i.authorizeSession(path, *resource.Name, <-sessionCh)
+	return decision, nil
 }
 
-func (i *AuthInter) authorizeSession(path, resource string, session *models.Session) (bool, *models.Session, error) {
-	ch := make(chan bool, len(session.Policies))
-	errCh := make(chan error, len(session.Policies))
+// Simulate evaluates the probes for every active session and for a guest, against the current state and
+// against the proposal. The decisions which would flip are reported. Nothing is persisted.
+func (i *AuthInter) Simulate(simulation *models.Simulation) (*models.SimulationResult, error) {
+	current := i.index.Snapshot()
+	proposed := current.Propose(simulation.Config, simulation.Policy)
+
+	sessions, err := i.sessionsInter.Find()
+	if err != nil {
+		return nil, err
+	}
+
+	// A nil session is evaluated as a guest
+	subjects := make([]*models.Session, 0, len(sessions)+1)
+	subjects = append(subjects, nil)
+	for idx := range sessions {
+		subjects = append(subjects, &sessions[idx])
+	}
 
-	// We check concurrently the associated policies and the permissions associated
-	for _, policyID := range session.Policies {
-		go i.checkPermissions(path, resource, policyID, ch, errCh)
+	result := &models.SimulationResult{
+		Sessions: len(subjects),
+		Probes:   len(simulation.Probes),
+		Flips:    []models.DecisionFlip{},
 	}
 
-	// We don't wait for all the policies to be checked
-	// We return as soon as we find a positive result
-	for range session.Policies {
-		select {
-		case granted := <-ch:
-			if granted {
-				return true, session, nil
+	for _, probe := range simulation.Probes {
+		if probe.Path == "" {
+			probe.Path = "/"
+		}
+		probe.Method = strings.ToUpper(probe.Method)
+
+		for _, session := range subjects {
+			before, err := i.simulate(current, probe, session)
+			if err != nil {
+				return nil, err
+			}
+
+			after, err := i.simulate(proposed, probe, session)
+			if err != nil {
+				return nil, err
 			}
-		case err := <-errCh:
-			return false, nil, err
+
+			if before.Granted == after.Granted {
+				continue
+			}
+
+			flip := models.DecisionFlip{
+				Probe:           probe,
+				Granted:         before.Granted,
+				ProposedGranted: after.Granted,
+				Reason:          before.Reason,
+				ProposedReason:  after.Reason,
+				Guest:           session == nil,
+			}
+
+			// The session tokens are only stored hashed
+			if session != nil {
+				flip.OwnerToken = session.OwnerToken
+			}
+
+			result.Flips = append(result.Flips, flip)
 		}
 	}
 
-	return false, nil, nil
+	return result, nil
 }
 
-func (i *AuthInter) authorizeGuestSession(path, resource string) (bool, *models.Session, error) {
-	ch := make(chan bool, 1)
-	errCh := make(chan error, 1)
+// simulate evaluates a probe for the given session against a snapshot.
+// As with the authorize method, a missing resource or policy denies the access.
+func (i *AuthInter) simulate(snapshot *AuthSnapshot, probe models.Probe, session *models.Session) (*models.Decision, error) {
+	decision := &models.Decision{Hostname: probe.Hostname, Path: probe.Path, Method: probe.Method, ClientIP: probe.ClientIP}
+
+	resource, decided, err := i.resolve(snapshot, decision)
+	if err == nil && !decided {
+		err = i.evaluate(snapshot, decision, resource, session, false)
+	}
+
+	if err != nil {
+		switch err.(type) {
+		case errs.ErrNotFound:
+			return decision, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return decision, nil
+}
 
-	// We check the guest permissions
-	i.checkPermissions(path, resource, "guest", ch, errCh)
+// decide evaluates the request against the given index snapshot.
+// The whole evaluation uses the same snapshot, even if the index is rebuilt meanwhile.
+// The policy traces are only built in explain mode, the hot path only keeps the deciding permissions.
+func (i *AuthInter) decide(
+	snapshot *AuthSnapshot,
+	hostname, path, method, clientIP, token string,
+	explain bool,
+) (*models.Decision, error) {
+	decision := &models.Decision{Hostname: hostname, Path: path, Method: method, ClientIP: clientIP}
+
+	resource, decided, err := i.resolve(snapshot, decision)
+	if err != nil || decided {
+		return decision, err
+	}
 
-	// We don't wait for all the policies to be checked
-	// We return as soon as we find a positive result
-	select {
-	case granted := <-ch:
-		if granted {
-			return true, nil, nil
+	// If no session is found for the token, we initiate a guest session
+	// Otherwise, the access is denied with an error
+	session, err := i.sessionsInter.FindByToken(token)
+	if err != nil {
+		switch err.(type) {
+		case errs.ErrNotFound:
+			session = nil
+		default:
+			return decision, err
 		}
-	case err := <-errCh:
-		return false, nil, err
 	}
 
-	return false, nil, nil
+	err = i.evaluate(snapshot, decision, resource, session, explain)
+
+	return decision, err
 }
 
-func (i *AuthInter) checkPermissions(path, resource, policyName string, ch chan bool, errCh chan error) {
-	// First, we find the policy corresponding to the given name in database
-	policy, err := i.policiesInter.FindByName(policyName)
+// resolve sets the resource serving the request in the decision.
+// The access is directly decided if the client IP is not allowed on the resource or if the resource is public.
+func (i *AuthInter) resolve(snapshot *AuthSnapshot, decision *models.Decision) (*models.Resource, bool, error) {
+	// If we can't find a resource, we deny the access
+	resource, err := snapshot.Resource(decision.Hostname, decision.Path)
 	if err != nil {
-		errCh <- err
-		return
+		decision.Reason = "no resource found for the host name and path"
+		return nil, true, err
 	}
 
-	// If the policy is disabled, we skip it
-	if policy.Enabled != nil && *policy.Enabled == false {
-		ch <- false
-		return
+	decision.Resource = resource
+
+	// The client IP restrictions of the resource apply whatever the session, even if the resource is public
+	if !snapshot.resourceNetworks(resource).permits(net.ParseIP(decision.ClientIP)) {
+		decision.Reason = "the client IP is not allowed on the resource"
+		return resource, true, nil
+	}
+
+	// If the found resource is marked as public, we allow the access without restriction
+	if resource.Public != nil && *resource.Public {
+		decision.Granted = true
+		decision.Reason = "the resource is public"
+		return resource, true, nil
+	}
+
+	return resource, false, nil
+}
+
+// evaluate checks the policies of the session on the resource. A nil session is evaluated as a guest.
+func (i *AuthInter) evaluate(
+	snapshot *AuthSnapshot,
+	decision *models.Decision,
+	resource *models.Resource,
+	session *models.Session,
+	explain bool,
+) error {
+	policies := i.policiesOf(session)
+	decision.Session = session
+
+	// The policies are combined with the algorithm of the resource or the default one
+	decision.Algorithm = i.g.GetCombiningAlgorithm()
+	if resource.CombiningAlgorithm != nil && *resource.CombiningAlgorithm != "" {
+		decision.Algorithm = *resource.CombiningAlgorithm
+	}
+	if decision.Algorithm == "" {
+		decision.Algorithm = models.PermitOverrides
 	}
 
 	// "reqPath" is the splited path of the incoming request
 	// We will use it to compare it with the permissions
-	reqPath := i.splitPath(path)
-	reqWeight := len(reqPath)
+	reqPath := matchers.SplitPath(decision.Path)
+	clientIP := net.ParseIP(decision.ClientIP)
 
-	// "granted" is obviously the boolean value indicating if the access must be granted
-	granted := false
+	// We check the policies one after the other, in the session order, so the result is deterministic
+	rules := make([]policyRule, 0, len(policies))
 
-	// "maxWeight" is used to ponderate the permissions
-	// A permission with a higher weight will override others with a lower one
-	// The weight is here the number of "segments" in a permission path
-	//
-	// Example:
-	//   "/foo" -> weight 1
-	//   "/foo/bar" -> weight 2
-	maxWeight := 0
-
-	// "wildcard" indicates if the current maxWeight was set by a permission with a wildcard
-	// In that case, a regular permission with the same weight would override it
-	wildcard := false
-
-	// We now check each permission of the policy
-	for _, permission := range policy.Permissions {
-		// If the permission does not concern the requested resource, we skip it
-		if *permission.Resource != resource && *permission.Resource != "*" {
-			continue
+	// The windows are all checked at the same time
+	now := time.Now()
+
+	for _, name := range policies {
+		policy, err := snapshot.policy(name)
+		if err != nil {
+			// A jwt token can't be rewritten when one of its policies is deleted, so the policy is skipped
+			if session != nil && session.TokenID != nil {
+				continue
+			}
+
+			decision.Reason = "a policy of the session was not found"
+			return err
 		}
 
-		// If the permission is disabled, we skip it
-		if permission.Enabled != nil && *permission.Enabled == false {
-			continue
+		var trace *models.PolicyTrace
+		if explain {
+			trace = &models.PolicyTrace{Name: name, Enabled: policy.enabled, InWindow: policy.window.open(now)}
 		}
 
-		// nil paths is considered as a wildcard
-		if permission.Paths == nil {
-			permission.Paths = []string{"*"}
+		rule := i.checkPermissions(policy, *resource.Name, reqPath, decision.Method, clientIP, session, now, trace)
+
+		if rule != nil {
+			rules = append(rules, policyRule{policy: name, permission: rule})
 		}
 
-		for _, path := range permission.Paths {
-			// We get the splited path and the weight of the permission
-			permPath := i.splitPath(path)
-			permWeight := len(permPath)
-
-			// If the weight of the permission is higher than the weight of the request, we skip it
-			//
-			// Example:
-			//    Req: "/foo"
-			//    Perm: "/foo/bar" -> Does not apply here
-			if permWeight > reqWeight {
-				continue
+		if trace != nil {
+			if rule != nil {
+				trace.Rule = i.traceOf(name, rule, statusApplied)
+				trace.Granted = !rule.deny
 			}
 
-			// We override the granted/maxWeight/wildcard variables if the paths match and:
-			//   - Current permission weight is higher than the current maxWeight
-			//   or
-			//   - Current permission weight is equal to the current maxWeight but was set by a wildcard
-			if ok, wc := i.match(reqPath, permPath); ok && ((permWeight > maxWeight) || (wildcard && permWeight == maxWeight)) {
-				if permission.Deny == nil {
-					granted = true
-				} else {
-					granted = !*permission.Deny
-				}
+			decision.Policies = append(decision.Policies, *trace)
+		}
+	}
 
-				maxWeight = permWeight
-				wildcard = wc
+	i.combine(decision, rules)
+
+	return nil
+}
+
+// combine sets the decision result from the policy rules, according to the decision combining algorithm.
+// Only the policies with a matching permission are applicable.
+func (i *AuthInter) combine(decision *models.Decision, rules []policyRule) {
+	var rule *policyRule
+
+	switch decision.Algorithm {
+	case models.FirstApplicable:
+		// The first applicable policy decides
+		if len(rules) > 0 {
+			rule = &rules[0]
+		}
+	case models.DenyOverrides:
+		// An applicable denial wins over any grant
+		for idx := range rules {
+			if rule == nil || (!rule.permission.deny && rules[idx].permission.deny) {
+				rule = &rules[idx]
 			}
+		}
+	case models.MostSpecificWins:
+		// The most specific permission across all the policies wins, a denial wins the ties
+		for idx := range rules {
+			if rule == nil {
+				rule = &rules[idx]
+				continue
+			}
+
+			c := rules[idx].permission.rank.compare(&rule.permission.rank)
+			if c > 0 || (c == 0 && rules[idx].permission.deny) {
+				rule = &rules[idx]
+			}
+		}
+	default:
+		// An applicable grant wins over any denial
+		for idx := range rules {
+			if rule == nil || (rule.permission.deny && !rules[idx].permission.deny) {
+				rule = &rules[idx]
+			}
+		}
+	}
+
+	if rule == nil {
+		decision.Reason = "no permission matches the request"
+		return
+	}
+
+	decision.Rule = i.traceOf(rule.policy, rule.permission, statusApplied)
+	decision.Granted = !rule.permission.deny
+
+	if decision.Granted {
+		decision.Reason = fmt.Sprintf("granted by the policy '%s' (%s)", rule.policy, decision.Algorithm)
+	} else {
+		decision.Reason = fmt.Sprintf("denied by the policy '%s' (%s)", rule.policy, decision.Algorithm)
+	}
+}
+
+// checkPermissions returns the permission of the policy deciding the access, if any.
+// If a trace is given, every permission concerning the resource is evaluated and reported in it.
+// Otherwise, only the permissions indexed under the request path are evaluated.
+func (i *AuthInter) checkPermissions(
+	policy *compiledPolicy,
+	resource string,
+	reqPath []string,
+	method string,
+	clientIP net.IP,
+	session *models.Session,
+	now time.Time,
+	trace *models.PolicyTrace,
+) *compiledPermission {
+	// If the policy is disabled or outside of its window, we skip it
+	if !policy.enabled || !policy.window.open(now) {
+		return nil
+	}
+
+	// "best" is the permission which currently decides the access
+	// A permission with a higher rank will override others with a lower one
+	// The rank is firstly the specificity of the matching path pattern
+	//
+	// Example:
+	//   "/foo/**" < "/foo/*" < "/foo/{id}" < "/foo/*.js" < "/foo/bar"
+	//
+	// At equal specificity, a permission targeting the request method explicitly overrides a method agnostic one
+	var best *compiledPermission
+
+	// The session variables of the path patterns and the attributes of the conditions
+	vars := sessionVariables{session: session}
+	var attributes map[string]interface{}
+	if session != nil {
+		attributes = session.Attributes
+	}
+
+	check := func(permission *compiledPermission) string {
+		switch {
+		// If the permission is disabled, we skip it
+		case !permission.enabled:
+			return statusDisabled
+		// If the permission or a policy it is inherited from is outside of its window, we skip it
+		case !permission.open(now):
+			return statusOutsideWindow
+		// If the permission is restricted to some methods not including the requested one, we skip it
+		case permission.rank.methodSpecific && !i.matchMethod(method, permission):
+			return statusMethodMismatch
+		// A malformed denial matches any request
+		case permission.malformed:
+		case permission.pattern == nil:
+			return statusInvalidPath
+		case permission.conditions == nil:
+			return statusInvalidCond
+		case permission.networks != nil && permission.networks.invalid:
+			return statusInvalidCIDR
+		case !permission.pattern.Match(reqPath, vars):
+			return statusNoMatch
+		// If the session attributes don't satisfy the permission conditions, we skip it
+		case !permission.matchConditions(attributes):
+			return statusCondMismatch
+		// If the client IP is outside of the permission ranges, we skip it
+		case !permission.networks.permits(clientIP):
+			return statusIPMismatch
+		}
+
+		// We override the current best permission if the new one outranks it
+		// At equal rank, a denial always wins over a grant, then the first permission in the policy
+		//
+		// Example:
+		//    Req: "DELETE /foo/bar"
+		//    Perm: "/foo/*" (granted) -> Overridden by the next one
+		//    Perm: "DELETE /foo/*" (denied)
+		if best == nil || i.outranks(permission, best) {
+			best = permission
+		}
+
+		return statusOverridden
+	}
+
+	if trace == nil {
+		policy.walk(resource, reqPath, func(permission *compiledPermission) { check(permission) })
+		return best
+	}
+
+	permissions := policy.forResource(resource)
+	statuses := make([]string, len(permissions))
+
+	for idx, permission := range permissions {
+		statuses[idx] = check(permission)
+	}
 
+	for idx, permission := range permissions {
+		if permission == best {
+			statuses[idx] = statusApplied
 		}
+
+		trace.Permissions = append(trace.Permissions, *i.traceOf(policy.name, permission, statuses[idx]))
 	}
 
-	// We return the result
-	ch <- granted
+	return best
 }
 
-func (i *AuthInter) findResource(hostname string) (chan *models.Resource, chan error) {
-	ch := make(chan *models.Resource, 1)
-	errCh := make(chan error, 1)
+func (i *AuthInter) outranks(permission, best *compiledPermission) bool {
+	if c := permission.rank.compare(&best.rank); c != 0 {
+		return c > 0
+	}
 
-	go func() {
-		resource, err := i.resourcesInter.FindByHostname(hostname)
-		if err != nil {
-			errCh <- err
-			close(ch)
-			return
+	if permission.deny != best.deny {
+		return permission.deny
+	}
+
+	return permission.position < best.position
+}
+
+func (i *AuthInter) traceOf(policy string, permission *compiledPermission, status string) *models.PermissionTrace {
+	t := &models.PermissionTrace{
+		Policy:         policy,
+		InheritedFrom:  permission.inheritedFrom,
+		Index:          permission.index,
+		Path:           permission.path,
+		Methods:        permission.methods,
+		Conditions:     permission.rawConditions,
+		AllowCIDRs:     permission.allowCIDRs,
+		DenyCIDRs:      permission.denyCIDRs,
+		Deny:           permission.deny,
+		MethodSpecific: permission.rank.methodSpecific,
+		Status:         status,
+	}
+
+	if permission.pattern != nil {
+		specificity := permission.rank.specificity
+		t.Specificity = &specificity
+	}
+
+	return t
+}
+
+// matchMethod returns true if the method is one the permission is restricted to.
+// An unknown method only matches the denials, so a request whose method isn't forwarded can't escape them.
+func (i *AuthInter) matchMethod(method string, permission *compiledPermission) bool {
+	if method == "" {
+		return permission.deny
+	}
+
+	for _, m := range permission.methods {
+		if strings.EqualFold(m, method) {
+			return true
 		}
+	}
 
-		ch <- resource
-		close(errCh)
-	}()
+	return false
+}
 
-	return ch, errCh
+// sessionVariables resolves the session variables of the path patterns. A guest has no variables.
+type sessionVariables struct {
+	session *models.Session
 }
 
-func (i *AuthInter) findSession(token string) (chan *models.Session, chan error) {
-	ch := make(chan *models.Session, 1)
-	errCh := make(chan error, 1)
+func (v sessionVariables) Lookup(name string) (string, bool) {
+	if v.session == nil {
+		return "", false
+	}
 
-	go func() {
-		resource, err := i.sessionsInter.FindByToken(token)
-		if err != nil {
-			errCh <- err
-			close(ch)
-			return
+	if name == "ownerToken" {
+		if v.session.OwnerToken == nil {
+			return "", false
 		}
+		return *v.session.OwnerToken, true
+	}
 
-		ch <- resource
-		close(errCh)
-	}()
+	// Only the scalar attributes can be substituted in a path
+	switch value := matchers.LookupAttribute(v.session.Attributes, strings.TrimPrefix(name, "attributes.")).(type) {
+	case string:
+		return value, true
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(value), true
+	}
 
-	return ch, errCh
+	return "", false
 }
 
-func (i *AuthInter) splitPath(path string) []string {
-	return strings.Split(strings.TrimPrefix(strings.TrimSuffix(path, "/"), "/"), "/")
+// rank is used to order the permissions matching a request.
+type rank struct {
+	specificity    matchers.Specificity
+	methodSpecific bool
+	malformed      bool // A malformed denial outranks any other permission
 }
 
-func (i *AuthInter) match(reqPath, permPath []string) (bool, bool) {
-	for i, p := range permPath {
-		switch p {
-		case reqPath[i]:
-			if i == len(permPath)-1 && len(permPath) < len(reqPath) {
-				return false, false
-			}
-			continue
-		case "*":
-			return true, true
-		default:
-			return false, false
+func (r *rank) compare(o *rank) int {
+	if r.malformed != o.malformed {
+		if r.malformed {
+			return 1
 		}
+		return -1
+	}
+
+	if c := r.specificity.Compare(o.specificity); c != 0 {
+		return c
+	}
+
+	switch {
+	case r.methodSpecific && !o.methodSpecific:
+		return 1
+	case !r.methodSpecific && o.methodSpecific:
+		return -1
 	}
 
-	return true, false
+	return 0
 }
@@ -45,6 +45,22 @@ var testPolicy1 = &models.Policy{
 			Paths:    []string{"/bar", "/bar2"},
 			Deny:     utils.BoolCpy(true),
 		},
+		{
+			Resource: utils.StrCpy("Foobar"),
+			Paths:    []string{"/foo/bar"},
+			Methods:  []string{"DELETE"},
+			Deny:     utils.BoolCpy(true),
+		},
+		{
+			Resource: utils.StrCpy("Foobar"),
+			Paths:    []string{"/bar"},
+			Methods:  []string{"get", "HEAD"},
+		},
+		{
+			Resource: utils.StrCpy("Foobar"),
+			Paths:    []string{"/users/*/profile", "/static/**/*.js"},
+			Deny:     utils.BoolCpy(true),
+		},
 		{
 			Resource: utils.StrCpy("Foobar"),
 		},
@@ -52,8 +68,30 @@ var testPolicy1 = &models.Policy{
 }
 
 var testPolicy2 = &models.Policy{
-	Name:        utils.StrCpy("Bar"),
-	Permissions: []models.Permission{},
+	Name: utils.StrCpy("Bar"),
+	Permissions: []models.Permission{
+		{
+			Resource: utils.StrCpy("Foobar"),
+			Paths:    []string{"/foo/bar"},
+			Methods:  []string{"PUT"},
+			Deny:     utils.BoolCpy(true),
+		},
+		{
+			Resource: utils.StrCpy("Foobar"),
+			Paths:    []string{"/foo/**/edit"},
+		},
+	},
+}
+
+var testPolicy3 = &models.Policy{
+	Name:    utils.StrCpy("Baz"),
+	Extends: []string{"Foo", "Qux", "Baz"}, // "Qux" does not exist, "Baz" is a cycle
+	Permissions: []models.Permission{
+		{
+			Resource: utils.StrCpy("Foobar"),
+			Paths:    []string{"/foo/baz"},
+		},
+	},
 }
 
 var guestPolicy = &models.Policy{
@@ -67,58 +105,54 @@ var guestPolicy = &models.Policy{
 	},
 }
 
-type authInterPoliciesInter struct {
-	errDB, errNotFound                         bool
-	disableGuestPolicy, disableGuestPermission bool
-	policy                                     *models.Policy
+// newAuthInterIndex returns an auth index compiled from the test fixtures.
+func newAuthInterIndex() *AuthIndex {
+	idx := NewAuthIndex(nil)
+	loadAuthInterIndex(idx)
+
+	return idx
 }
 
-func (r *authInterPoliciesInter) FindByName(name string) (*models.Policy, error) {
-	if r.errDB {
-		return nil, errs.Internal.Database
-	}
+// loadAuthInterIndex recompiles the test fixtures.
+// As the index keeps a compiled copy of them, it must be called after each fixture update.
+func loadAuthInterIndex(idx *AuthIndex) {
+	idx.Load(
+		[]models.Resource{*testResource},
+		[]models.Policy{*guestPolicy, *testPolicy1, *testPolicy2, *testPolicy3},
+	)
+}
 
-	if r.errNotFound {
-		return nil, errs.Internal.NotFound
-	}
+type authInterAuditInter struct {
+	err     bool
+	entries []models.AuditEntry
+}
 
-	switch name {
-	case "guest":
-		return guestPolicy, nil
-	case "Foo":
-		return testPolicy1, nil
-	case "Bar":
-		return testPolicy2, nil
+func (i *authInterAuditInter) Record(entry *models.AuditEntry) error {
+	if i.err {
+		return errs.Internal.Database
 	}
 
-	return r.policy, nil
+	i.entries = append(i.entries, *entry)
+
+	return nil
 }
 
-type authInterResourcesInter struct {
+type authInterSessionsInter struct {
 	errDB, errNotFound bool
-	resource           *models.Resource
+	session            *models.Session
+	touched            []string
 }
 
-func (r *authInterResourcesInter) FindByHostname(hostname string) (*models.Resource, error) {
+func (r *authInterSessionsInter) Find() ([]models.Session, error) {
 	if r.errDB {
 		return nil, errs.Internal.Database
 	}
 
-	if r.errNotFound {
-		return nil, errs.Internal.NotFound
-	}
-
-	switch hostname {
-	case "foo.bar.com":
-		return testResource, nil
-	}
-
-	return r.resource, nil
+	return []models.Session{*testSession}, nil
 }
 
-type authInterSessionsInter struct {
-	errDB, errNotFound bool
-	session            *models.Session
+func (r *authInterSessionsInter) Touch(token string) {
+	r.touched = append(r.touched, token)
 }
 
 func (r *authInterSessionsInter) FindByToken(token string) (*models.Session, error) {
@@ -142,20 +176,23 @@ func (r *authInterSessionsInter) FindByToken(token string) (*models.Session, err
 func TestAuthInterAuthorizeToken(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
-	policiesInter := &authInterPoliciesInter{}
-	resourcesInter := &authInterResourcesInter{}
+	index := newAuthInterIndex()
 	sessionsInter := &authInterSessionsInter{}
 	inter := NewAuthInter(
-		policiesInter,
-		resourcesInter,
+		index,
+		NewDecisionCache(utils.NewFakeModelsGetter(), NewTokenHasher()),
+		NewRateLimiter(),
+		&authInterAuditInter{},
 		sessionsInter,
+		utils.NewFakeModelsGetter(),
 	)
 	hostname := "foo.bar.com"
 	path := ""
+	method := "POST"
 	token := "F00bAr"
 
 	// Success: root
-	granted, session, err := inter.AuthorizeToken(hostname, path, token)
+	granted, session, err := inter.AuthorizeToken(hostname, path, method, "", token)
 	r.NoError(err)
 	a.True(granted)
 	a.NotNil(session)
@@ -163,7 +200,7 @@ func TestAuthInterAuthorizeToken(t *testing.T) {
 	path = "/foo/bar"
 
 	// Success: weight system
-	granted, session, err = inter.AuthorizeToken(hostname, path, token)
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
 	r.NoError(err)
 	a.True(granted)
 	a.NotNil(session)
@@ -171,7 +208,7 @@ func TestAuthInterAuthorizeToken(t *testing.T) {
 	path = "/foo/bar/"
 
 	// Success: trailing slash
-	granted, session, err = inter.AuthorizeToken(hostname, path, token)
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
 	r.NoError(err)
 	a.True(granted)
 	a.NotNil(session)
@@ -179,7 +216,7 @@ func TestAuthInterAuthorizeToken(t *testing.T) {
 	path = "/foo/"
 
 	// Success: trailing slash
-	granted, session, err = inter.AuthorizeToken(hostname, path, token)
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
 	r.NoError(err)
 	a.True(granted)
 	a.NotNil(session)
@@ -187,63 +224,127 @@ func TestAuthInterAuthorizeToken(t *testing.T) {
 	path = "/bar"
 
 	// Multipath denied
-	granted, session, err = inter.AuthorizeToken(hostname, path, token)
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
 	r.NoError(err)
 	a.False(granted)
 
 	path = "/bar2"
 
 	// Multipath denied
-	granted, session, err = inter.AuthorizeToken(hostname, path, token)
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
 	r.NoError(err)
 	a.False(granted)
 
+	path = "/bar"
+	method = "GET"
+
+	// Success: method specific permission overrides the method agnostic one
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
+	r.NoError(err)
+	a.True(granted)
+	a.NotNil(session)
+
+	path = "/foo/bar"
+	method = "DELETE"
+
+	// Denied: method specific permission
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
+	r.NoError(err)
+	a.False(granted)
+
+	method = ""
+
+	// Denied: method specific denials apply without a request method
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
+	r.NoError(err)
+	a.False(granted)
+
+	path = "/bar"
+
+	// Denied: method specific grants don't apply without a request method
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
+	r.NoError(err)
+	a.False(granted)
+
+	path = "/users/42/profile"
+	method = "GET"
+
+	// Denied: mid-path wildcard
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
+	r.NoError(err)
+	a.False(granted)
+
+	path = "/users/42/profile/edit"
+
+	// Success: a mid-path wildcard does not cover the subtree
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
+	r.NoError(err)
+	a.True(granted)
+
+	path = "/static/js/vendor/app.js"
+
+	// Denied: recursive wildcard and suffix glob
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
+	r.NoError(err)
+	a.False(granted)
+
+	path = "/static/js/vendor/app.css"
+
+	// Success: suffix glob does not match
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
+	r.NoError(err)
+	a.True(granted)
+
 	path = "/foo/foo"
 
 	// Denied
-	granted, session, err = inter.AuthorizeToken(hostname, path, token)
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
 	r.NoError(err)
 	a.False(granted)
 
 	testResource.Public = utils.BoolCpy(true)
+	loadAuthInterIndex(index)
 
 	// Success: public resource
-	granted, session, err = inter.AuthorizeToken(hostname, path, token)
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
 	r.NoError(err)
 	a.True(granted)
 	a.Nil(session)
 
 	testResource.Public = utils.BoolCpy(false)
+	loadAuthInterIndex(index)
 	sessionsInter.errNotFound = true
 
 	// Success: guest policy
-	granted, session, err = inter.AuthorizeToken(hostname, path, token)
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
 	r.NoError(err)
 	a.True(granted)
 	a.Nil(session)
 
 	guestPolicy.Enabled = utils.BoolCpy(false)
+	loadAuthInterIndex(index)
 
 	// Denied: guest policy is disabled
-	granted, session, err = inter.AuthorizeToken(hostname, path, token)
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
 	r.NoError(err)
 	a.False(granted)
 	a.Nil(session)
 
 	guestPolicy.Enabled = utils.BoolCpy(true)
 	guestPolicy.Permissions[0].Enabled = utils.BoolCpy(false)
+	loadAuthInterIndex(index)
 
 	// Denied: guest policy permissions are disabled
-	granted, session, err = inter.AuthorizeToken(hostname, path, token)
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
 	r.NoError(err)
 	a.False(granted)
 	a.Nil(session)
 
 	guestPolicy.Permissions[0].Enabled = utils.BoolCpy(true)
-	policiesInter.errNotFound = true
+	index.Load([]models.Resource{*testResource}, nil)
 
 	// Error: guest policy
-	granted, session, err = inter.AuthorizeToken(hostname, path, token)
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
 	r.Error(err)
 	a.False(granted)
 	a.Nil(session)
@@ -251,44 +352,948 @@ func TestAuthInterAuthorizeToken(t *testing.T) {
 	sessionsInter.errNotFound = false
 
 	// Not found error
-	granted, session, err = inter.AuthorizeToken(hostname, path, token)
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
 	r.Error(err)
 	a.IsType(errs.Internal.NotFound, err)
 	a.False(granted)
 
-	policiesInter.errNotFound = false
-	resourcesInter.errNotFound = true
+	index.Load(nil, []models.Policy{*guestPolicy, *testPolicy1, *testPolicy2})
 
 	// Not found error
-	granted, session, err = inter.AuthorizeToken(hostname, path, token)
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
 	r.Error(err)
 	a.IsType(errs.Internal.NotFound, err)
 	a.False(granted)
 
-	resourcesInter.errNotFound = false
-	policiesInter.errDB = true
+	loadAuthInterIndex(index)
+	sessionsInter.errDB = true
 
 	// Database error
-	granted, session, err = inter.AuthorizeToken(hostname, path, token)
+	granted, session, err = inter.AuthorizeToken(hostname, path, method, "", token)
 	r.Error(err)
 	a.IsType(errs.Internal.Database, err)
 	a.False(granted)
+}
 
-	policiesInter.errDB = false
-	resourcesInter.errDB = true
+// TestAuthInterExplain runs tests on the AuthInter Explain method.
+func TestAuthInterExplain(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	index := newAuthInterIndex()
+	sessionsInter := &authInterSessionsInter{}
+	inter := NewAuthInter(
+		index,
+		NewDecisionCache(utils.NewFakeModelsGetter(), NewTokenHasher()),
+		NewRateLimiter(),
+		&authInterAuditInter{},
+		sessionsInter,
+		utils.NewFakeModelsGetter(),
+	)
+
+	// Success: granted by the most specific permission
+	decision, err := inter.Explain("foo.bar.com", "/foo/bar", "GET", "", "F00bAr")
+	r.NoError(err)
+	a.True(decision.Granted)
+	a.Equal(testResource, decision.Resource)
+	a.Equal(testSession, decision.Session)
+	r.Len(decision.Policies, 2)
+	a.Equal("Foo", decision.Policies[0].Name)
+	a.Equal("Bar", decision.Policies[1].Name)
+	r.NotNil(decision.Rule)
+	a.Equal("Foo", decision.Rule.Policy)
+	a.Equal(1, decision.Rule.Index)
+	a.Equal("/foo/bar", decision.Rule.Path)
+	a.Equal("applied", decision.Rule.Status)
+
+	statuses := map[int]string{}
+	for _, permission := range decision.Policies[0].Permissions {
+		statuses[permission.Index] = permission.Status
+	}
+	a.Equal("overridden", statuses[0])
+	a.Equal("no match", statuses[3])
+	a.Equal("method mismatch", statuses[4])
+
+	// Denied: the denying permission is reported
+	decision, err = inter.Explain("foo.bar.com", "/foo/foo", "GET", "", "F00bAr")
+	r.NoError(err)
+	a.False(decision.Granted)
+	r.NotNil(decision.Rule)
+	a.Equal("/foo/*", decision.Rule.Path)
+	a.True(decision.Rule.Deny)
+
+	sessionsInter.errNotFound = true
+
+	// Success: guest policy
+	decision, err = inter.Explain("foo.bar.com", "/foo/foo", "GET", "", "")
+	r.NoError(err)
+	a.True(decision.Granted)
+	a.Nil(decision.Session)
+	r.Len(decision.Policies, 1)
+	a.Equal("guest", decision.Policies[0].Name)
+
+	sessionsInter.errNotFound = false
+	index.Load(nil, nil)
+
+	// Denied: the resource is not found
+	decision, err = inter.Explain("foo.bar.com", "/foo/foo", "GET", "", "F00bAr")
+	r.NoError(err)
+	a.False(decision.Granted)
+	a.Nil(decision.Resource)
+
+	loadAuthInterIndex(index)
+	sessionsInter.session = &models.Session{Token: utils.StrCpy("B4r"), Policies: []string{"Qux"}}
+
+	// Denied: a policy of the session is not found
+	decision, err = inter.Explain("foo.bar.com", "/foo/foo", "GET", "", "B4r")
+	r.NoError(err)
+	a.False(decision.Granted)
+	a.Equal(testResource, decision.Resource)
+	a.Equal("a policy of the session was not found", decision.Reason)
+
+	sessionsInter.session = nil
+	sessionsInter.errDB = true
 
 	// Database error
-	granted, session, err = inter.AuthorizeToken(hostname, path, token)
+	decision, err = inter.Explain("foo.bar.com", "/foo/foo", "GET", "", "F00bAr")
 	r.Error(err)
 	a.IsType(errs.Internal.Database, err)
+	a.Nil(decision)
+}
+
+// TestAuthInterCombiningAlgorithms runs tests on the AuthInter policy combining algorithms.
+func TestAuthInterCombiningAlgorithms(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	getter := utils.NewFakeModelsGetter()
+	index := newAuthInterIndex()
+	inter := NewAuthInter(
+		index,
+		NewDecisionCache(utils.NewFakeModelsGetter(), NewTokenHasher()),
+		NewRateLimiter(),
+		&authInterAuditInter{},
+		&authInterSessionsInter{},
+		getter,
+	)
+
+	// "Foo" grants "PUT /foo/bar" with a method agnostic permission
+	// "Bar" denies it with a method specific one
+
+	// Success: permit-overrides is the default
+	granted, _, err := inter.AuthorizeToken("foo.bar.com", "/foo/bar", "PUT", "", "F00bAr")
+	r.NoError(err)
+	a.True(granted)
+
+	getter.CombiningAlgorithm = "first-applicable"
+
+	// Success: the first policy decides
+	decision, err := inter.Explain("foo.bar.com", "/foo/bar", "PUT", "", "F00bAr")
+	r.NoError(err)
+	a.True(decision.Granted)
+	a.Equal("first-applicable", decision.Algorithm)
+	a.Equal("Foo", decision.Rule.Policy)
+
+	getter.CombiningAlgorithm = "deny-overrides"
+
+	// Denied: the denial of the second policy wins
+	decision, err = inter.Explain("foo.bar.com", "/foo/bar", "PUT", "", "F00bAr")
+	r.NoError(err)
+	a.False(decision.Granted)
+	a.Equal("Bar", decision.Rule.Policy)
+
+	// Success: no policy denies the access
+	granted, _, err = inter.AuthorizeToken("foo.bar.com", "/foo/bar", "GET", "", "F00bAr")
+	r.NoError(err)
+	a.True(granted)
+
+	// Denied: the first policy denies the access
+	granted, _, err = inter.AuthorizeToken("foo.bar.com", "/foo/foo/edit", "GET", "", "F00bAr")
+	r.NoError(err)
 	a.False(granted)
 
-	resourcesInter.errDB = false
+	getter.CombiningAlgorithm = "most-specific-wins"
+
+	// Denied: the method specific permission of the second policy is the most specific
+	decision, err = inter.Explain("foo.bar.com", "/foo/bar", "PUT", "", "F00bAr")
+	r.NoError(err)
+	a.False(decision.Granted)
+	a.Equal("Bar", decision.Rule.Policy)
+
+	// Success: "/foo/**/edit" in the second policy is more specific than "/foo/*" in the first one
+	decision, err = inter.Explain("foo.bar.com", "/foo/foo/edit", "GET", "", "F00bAr")
+	r.NoError(err)
+	a.True(decision.Granted)
+	a.Equal("Bar", decision.Rule.Policy)
+
+	testResource.CombiningAlgorithm = utils.StrCpy("permit-overrides")
+	loadAuthInterIndex(index)
+
+	// Success: the resource algorithm overrides the default one
+	decision, err = inter.Explain("foo.bar.com", "/foo/bar", "PUT", "", "F00bAr")
+	r.NoError(err)
+	a.True(decision.Granted)
+	a.Equal("permit-overrides", decision.Algorithm)
+
+	testResource.CombiningAlgorithm = nil
+	loadAuthInterIndex(index)
+}
+
+// TestAuthInterPolicyInheritance runs tests on the AuthInter policy inheritance.
+func TestAuthInterPolicyInheritance(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	index := newAuthInterIndex()
+	sessionsInter := &authInterSessionsInter{
+		session: &models.Session{
+			Token:    utils.StrCpy("B4z"),
+			ValidTo:  utils.TimeCpy(time.Now().UTC().Add(time.Hour)),
+			Policies: []string{"Baz"},
+		},
+	}
+	inter := NewAuthInter(
+		index,
+		NewDecisionCache(utils.NewFakeModelsGetter(), NewTokenHasher()),
+		NewRateLimiter(),
+		&authInterAuditInter{},
+		sessionsInter,
+		utils.NewFakeModelsGetter(),
+	)
+
+	// Success: own permission
+	decision, err := inter.Explain("foo.bar.com", "/foo/baz", "GET", "", "B4z")
+	r.NoError(err)
+	a.True(decision.Granted)
+	a.Equal("Baz", decision.Rule.Policy)
+	a.Empty(decision.Rule.InheritedFrom)
+
+	// Denied: inherited permission
+	decision, err = inter.Explain("foo.bar.com", "/foo/foo", "GET", "", "B4z")
+	r.NoError(err)
+	a.False(decision.Granted)
+	a.Equal("Baz", decision.Rule.Policy)
+	a.Equal("Foo", decision.Rule.InheritedFrom)
+	a.Equal("/foo/*", decision.Rule.Path)
+
+	// Success: inherited permission
+	granted, _, err := inter.AuthorizeToken("foo.bar.com", "/foo/bar", "GET", "", "B4z")
+	r.NoError(err)
+	a.True(granted)
+
+	testPolicy1.Enabled = utils.BoolCpy(false)
+	loadAuthInterIndex(index)
+
+	// Denied: the extended policy is disabled
+	granted, _, err = inter.AuthorizeToken("foo.bar.com", "/foo/bar", "GET", "", "B4z")
+	r.NoError(err)
+	a.False(granted)
+
+	testPolicy1.Enabled = nil
+	loadAuthInterIndex(index)
+}
+
+// TestAuthInterSimulate runs tests on the AuthInter Simulate method.
+func TestAuthInterSimulate(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	index := newAuthInterIndex()
+	sessionsInter := &authInterSessionsInter{}
+	getter := utils.NewFakeModelsGetter()
+	inter := NewAuthInter(index, NewDecisionCache(getter, NewTokenHasher()), NewRateLimiter(), &authInterAuditInter{}, sessionsInter, getter)
+	generation := index.Snapshot().Generation()
+	simulation := &models.Simulation{
+		Policy: &models.Policy{
+			Name: utils.StrCpy("Foo"),
+			Permissions: []models.Permission{
+				{
+					Resource: utils.StrCpy("Foobar"),
+					Paths:    []string{"/foo/bar"},
+					Deny:     utils.BoolCpy(true),
+				},
+			},
+		},
+		Probes: []models.Probe{
+			{Hostname: "foo.bar.com", Path: "/foo/bar", Method: "get"},
+			{Hostname: "bar.foo.com"},
+		},
+	}
+
+	// Success: the session loses the access, the guest is unchanged
+	result, err := inter.Simulate(simulation)
+	r.NoError(err)
+	a.Equal(2, result.Sessions)
+	a.Equal(2, result.Probes)
+	r.Len(result.Flips, 1)
+	a.False(result.Flips[0].Guest)
+	a.Equal("GET", result.Flips[0].Probe.Method)
+	a.True(result.Flips[0].Granted)
+	a.False(result.Flips[0].ProposedGranted)
+	a.Contains(result.Flips[0].ProposedReason, "'Foo'")
+
+	// Nothing is persisted
+	a.Equal(generation, index.Snapshot().Generation())
+	granted, _, err := inter.AuthorizeToken("foo.bar.com", "/foo/bar", "GET", "", "F00bAr")
+	r.NoError(err)
+	a.True(granted)
+
+	simulation.Policy = nil
+	simulation.Config = &models.SimulationConfig{Resources: []models.Resource{*testResource}}
+
+	// Success: the policies are replaced by an empty guest one
+	result, err = inter.Simulate(simulation)
+	r.NoError(err)
+	r.Len(result.Flips, 2)
+	a.True(result.Flips[0].Guest)
+	a.False(result.Flips[1].Guest)
+	a.Equal("a policy of the session was not found", result.Flips[1].ProposedReason)
+
 	sessionsInter.errDB = true
 
 	// Database error
-	granted, session, err = inter.AuthorizeToken(hostname, path, token)
+	_, err = inter.Simulate(simulation)
 	r.Error(err)
 	a.IsType(errs.Internal.Database, err)
+}
+
+// TestAuthInterReportMode runs tests on the AuthInter AuthorizeToken method on a resource in report mode.
+func TestAuthInterReportMode(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	index := newAuthInterIndex()
+	getter := utils.NewFakeModelsGetter()
+	getter.DecisionCacheTTL = time.Minute
+	getter.DecisionCacheSize = 10
+	auditInter := &authInterAuditInter{}
+	sessionsInter := &authInterSessionsInter{}
+	inter := NewAuthInter(index, NewDecisionCache(getter, NewTokenHasher()), NewRateLimiter(), auditInter, sessionsInter, getter)
+
+	testResource.Mode = utils.StrCpy(models.ModeReport)
+	loadAuthInterIndex(index)
+
+	// Success: granted, nothing is audited
+	granted, _, err := inter.AuthorizeToken("foo.bar.com", "/foo/bar", "GET", "", "F00bAr")
+	r.NoError(err)
+	a.True(granted)
+	a.Len(auditInter.entries, 0)
+
+	// Success: the denial is audited, the session is returned
+	for i := 0; i < 2; i++ {
+		granted, session, err := inter.AuthorizeToken("foo.bar.com", "/bar", "DELETE", "", "F00bAr")
+		r.NoError(err)
+		a.True(granted)
+		a.NotNil(session)
+	}
+	r.Len(auditInter.entries, 2)
+	a.Equal("Foobar", *auditInter.entries[0].Resource)
+	a.Equal("/bar", auditInter.entries[0].Path)
+	a.Equal(testSession.Policies, auditInter.entries[0].Policies)
+	a.False(auditInter.entries[0].Guest)
+	r.NotNil(auditInter.entries[0].Rule)
+	a.True(auditInter.entries[0].Rule.Deny)
+
+	sessionsInter.session = &models.Session{Token: utils.StrCpy("B4r"), Policies: []string{"Qux"}}
+
+	// Success: the missing policy denial is audited
+	granted, _, err = inter.AuthorizeToken("foo.bar.com", "/foo", "GET", "", "B4r")
+	r.NoError(err)
+	a.True(granted)
+	r.Len(auditInter.entries, 3)
+	a.Equal("a policy of the session was not found", auditInter.entries[2].Reason)
+
+	sessionsInter.session = nil
+	auditInter.err = true
+
+	// Success: the audit failure does not deny the access
+	granted, _, err = inter.AuthorizeToken("foo.bar.com", "/bar", "DELETE", "", "F00bAr")
+	r.NoError(err)
+	a.True(granted)
+
+	sessionsInter.errDB = true
+
+	// Database error
+	_, _, err = inter.AuthorizeToken("foo.bar.com", "/foo", "GET", "", "B4r")
+	r.Error(err)
+
+	testResource.Mode = utils.StrCpy(models.ModeEnforce)
+	sessionsInter.errDB = false
+	loadAuthInterIndex(index)
+
+	// Denied: the resource is enforced
+	granted, _, err = inter.AuthorizeToken("foo.bar.com", "/bar", "DELETE", "", "F00bAr")
+	r.NoError(err)
+	a.False(granted)
+	a.Len(auditInter.entries, 3)
+
+	testResource.Mode = nil
+	loadAuthInterIndex(index)
+}
+
+// TestAuthInterDeletedPolicies runs tests on the sessions naming a deleted policy.
+func TestAuthInterDeletedPolicies(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	sessionsInter := &authInterSessionsInter{}
+	inter := NewAuthInter(
+		newAuthInterIndex(),
+		NewDecisionCache(utils.NewFakeModelsGetter(), NewTokenHasher()),
+		NewRateLimiter(),
+		&authInterAuditInter{},
+		sessionsInter,
+		utils.NewFakeModelsGetter(),
+	)
+
+	sessionsInter.session = &models.Session{Token: utils.StrCpy("B4r"), Policies: []string{"Foo", "Qux"}}
+
+	// Not found: an opaque session is rewritten when a policy is deleted, a missing one is unexpected
+	granted, _, err := inter.AuthorizeToken("foo.bar.com", "/foo/bar", "GET", "", "B4r")
+	r.Error(err)
+	a.IsType(errs.Internal.NotFound, err)
+	a.False(granted)
+
+	sessionsInter.session.TokenID = utils.StrCpy("1d")
+
+	// Success: the deleted policy of a jwt session is skipped
+	granted, _, err = inter.AuthorizeToken("foo.bar.com", "/foo/bar", "GET", "", "B4r")
+	r.NoError(err)
+	a.True(granted)
+
+	// Success: the remaining policies still deny
+	granted, _, err = inter.AuthorizeToken("foo.bar.com", "/foo/foo", "GET", "", "B4r")
+	r.NoError(err)
+	a.False(granted)
+}
+
+// TestAuthInterConditions runs tests on the permission conditions over the session attributes.
+func TestAuthInterConditions(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	index := NewAuthIndex(nil)
+	sessionsInter := &authInterSessionsInter{}
+	inter := NewAuthInter(
+		index,
+		NewDecisionCache(utils.NewFakeModelsGetter(), NewTokenHasher()),
+		NewRateLimiter(),
+		&authInterAuditInter{},
+		sessionsInter,
+		utils.NewFakeModelsGetter(),
+	)
+	tenantPolicy := models.Policy{
+		Name: utils.StrCpy("Tenant"),
+		Permissions: []models.Permission{
+			{
+				Resource:   utils.StrCpy("Foobar"),
+				Paths:      []string{"/acme/*"},
+				Conditions: []string{`tenant == "acme"`},
+			},
+			{
+				Resource:   utils.StrCpy("Foobar"),
+				Paths:      []string{"/admin/*"},
+				Conditions: []string{`tenant == "acme"`, `"admin" in roles`},
+			},
+			{
+				Resource:   utils.StrCpy("Foobar"),
+				Paths:      []string{"/broken"},
+				Conditions: []string{`tenant = "acme"`},
+			},
+		},
+	}
+	index.Load([]models.Resource{*testResource}, []models.Policy{*guestPolicy, tenantPolicy})
+
+	sessionsInter.session = &models.Session{
+		Token:      utils.StrCpy("T3n"),
+		Policies:   []string{"Tenant"},
+		Attributes: map[string]interface{}{"tenant": "acme", "roles": []interface{}{"dev"}},
+	}
+
+	cases := []struct {
+		path    string
+		granted bool
+	}{
+		{"/acme/foo", true},
+		{"/admin/foo", false},
+		{"/broken", false},
+	}
+
+	for _, c := range cases {
+		granted, _, err := inter.AuthorizeToken("foo.bar.com", c.path, "GET", "", "T3n")
+		r.NoError(err)
+		a.Equal(c.granted, granted, c.path)
+	}
+
+	// Success: the conditions are reported in the trace
+	decision, err := inter.Explain("foo.bar.com", "/admin/foo", "GET", "", "T3n")
+	r.NoError(err)
+	r.Len(decision.Policies, 1)
+	r.Len(decision.Policies[0].Permissions, 3)
+	a.Equal("no match", decision.Policies[0].Permissions[0].Status)
+	a.Equal("condition mismatch", decision.Policies[0].Permissions[1].Status)
+	a.Len(decision.Policies[0].Permissions[1].Conditions, 2)
+	a.Equal("invalid condition", decision.Policies[0].Permissions[2].Status)
+
+	sessionsInter.session.Attributes["roles"] = []interface{}{"admin"}
+
+	// Granted: the session is an admin
+	granted, _, err := inter.AuthorizeToken("foo.bar.com", "/admin/foo", "GET", "", "T3n")
+	r.NoError(err)
+	a.True(granted)
+
+	sessionsInter.session.Attributes = map[string]interface{}{"tenant": "foo"}
+
+	// Denied: another tenant
+	granted, _, err = inter.AuthorizeToken("foo.bar.com", "/acme/foo", "GET", "", "T3n")
+	r.NoError(err)
+	a.False(granted)
+
+	sessionsInter.session.Attributes = nil
+
+	// Denied: no attributes
+	granted, _, err = inter.AuthorizeToken("foo.bar.com", "/acme/foo", "GET", "", "T3n")
+	r.NoError(err)
+	a.False(granted)
+}
+
+// TestAuthInterMalformedDenials runs tests on the permissions malformed in the database.
+func TestAuthInterMalformedDenials(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	index := NewAuthIndex(nil)
+	sessionsInter := &authInterSessionsInter{}
+	inter := NewAuthInter(
+		index,
+		NewDecisionCache(utils.NewFakeModelsGetter(), NewTokenHasher()),
+		NewRateLimiter(),
+		&authInterAuditInter{},
+		sessionsInter,
+		utils.NewFakeModelsGetter(),
+	)
+	lockedPolicy := models.Policy{
+		Name: utils.StrCpy("Locked"),
+		Permissions: []models.Permission{
+			{
+				Resource: utils.StrCpy("Foobar"),
+				Paths:    []string{"/foo/bar"},
+			},
+			{
+				Resource:   utils.StrCpy("Foobar"),
+				Paths:      []string{"/admin/*"},
+				Conditions: []string{`tenant = "acme"`},
+				Deny:       utils.BoolCpy(true),
+			},
+		},
+	}
+	index.Load([]models.Resource{*testResource}, []models.Policy{*guestPolicy, lockedPolicy})
+
+	sessionsInter.session = &models.Session{
+		Token:    utils.StrCpy("L0ck"),
+		Policies: []string{"Locked"},
+	}
+
+	// Denied: a malformed condition denies any request, even a more specific grant
+	granted, _, err := inter.AuthorizeToken("foo.bar.com", "/foo/bar", "GET", "", "L0ck")
+	r.NoError(err)
+	a.False(granted)
+
+	lockedPolicy.Permissions[1].Conditions = nil
+	lockedPolicy.Permissions[1].Paths = []string{"/admin/${password}"}
+	index.Load([]models.Resource{*testResource}, []models.Policy{*guestPolicy, lockedPolicy})
+
+	// Denied: a malformed path denies any request
+	decision, err := inter.Explain("foo.bar.com", "/foo/bar", "GET", "", "L0ck")
+	r.NoError(err)
+	a.False(decision.Granted)
+	r.Len(decision.Policies, 1)
+	r.Len(decision.Policies[0].Permissions, 2)
+	a.Equal("applied", decision.Policies[0].Permissions[1].Status)
+
+	lockedPolicy.Permissions[1].Paths = []string{"/admin/*"}
+	lockedPolicy.Permissions[1].DenyCIDRs = []string{"10.0.0.0/33"}
+	index.Load([]models.Resource{*testResource}, []models.Policy{*guestPolicy, lockedPolicy})
+
+	// Denied: a malformed range denies any request
+	granted, _, err = inter.AuthorizeToken("foo.bar.com", "/foo/bar", "GET", "", "L0ck")
+	r.NoError(err)
+	a.False(granted)
+
+	lockedPolicy.Permissions[1].DenyCIDRs = nil
+	index.Load([]models.Resource{*testResource}, []models.Policy{*guestPolicy, lockedPolicy})
+
+	// Success: the denial is fixed
+	granted, _, err = inter.AuthorizeToken("foo.bar.com", "/foo/bar", "GET", "", "L0ck")
+	r.NoError(err)
+	a.True(granted)
+}
+
+// TestAuthInterPathVariables runs tests on the session variables in the permission paths.
+func TestAuthInterPathVariables(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	index := NewAuthIndex(nil)
+	sessionsInter := &authInterSessionsInter{}
+	inter := NewAuthInter(
+		index,
+		NewDecisionCache(utils.NewFakeModelsGetter(), NewTokenHasher()),
+		NewRateLimiter(),
+		&authInterAuditInter{},
+		sessionsInter,
+		utils.NewFakeModelsGetter(),
+	)
+	userPolicy := models.Policy{
+		Name: utils.StrCpy("User"),
+		Permissions: []models.Permission{
+			{
+				Resource: utils.StrCpy("Foobar"),
+				Paths:    []string{"/users/${ownerToken}/*", "/tenants/${attributes.tenant}/levels/${attributes.level}"},
+			},
+		},
+	}
+	index.Load([]models.Resource{*testResource}, []models.Policy{userPolicy})
+
+	sessionsInter.session = &models.Session{
+		Token:      utils.StrCpy("Us3r"),
+		OwnerToken: utils.StrCpy("owner1"),
+		Policies:   []string{"User"},
+		Attributes: map[string]interface{}{"tenant": "acme", "level": float64(2)},
+	}
+
+	cases := []struct {
+		path    string
+		granted bool
+	}{
+		{"/users/owner1/profile", true},
+		{"/users/owner2/profile", false},
+		{"/tenants/acme/levels/2", true},
+		{"/tenants/foo/levels/2", false},
+	}
+
+	for _, c := range cases {
+		granted, _, err := inter.AuthorizeToken("foo.bar.com", c.path, "GET", "", "Us3r")
+		r.NoError(err)
+		a.Equal(c.granted, granted, c.path)
+	}
+
+	sessionsInter.session.OwnerToken = nil
+
+	// Denied: the session has no owner token
+	granted, _, err := inter.AuthorizeToken("foo.bar.com", "/users/owner1/profile", "GET", "", "Us3r")
+	r.NoError(err)
 	a.False(granted)
 }
+
+// TestAuthInterWindows runs tests on the validity windows of the policies and permissions.
+func TestAuthInterWindows(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	index := NewAuthIndex(nil)
+	getter := utils.NewFakeModelsGetter()
+	getter.DecisionCacheTTL = time.Minute
+	getter.DecisionCacheSize = 10
+	sessionsInter := &authInterSessionsInter{}
+	inter := NewAuthInter(index, NewDecisionCache(getter, NewTokenHasher()), NewRateLimiter(), &authInterAuditInter{}, sessionsInter, getter)
+
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	everyDay := []models.Schedule{{From: "00:00", To: "24:00", TimeZone: "Europe/Paris"}}
+
+	contractorPolicy := models.Policy{
+		Name:    utils.StrCpy("Contractor"),
+		Extends: []string{"Office"},
+		Permissions: []models.Permission{
+			{
+				Resource: utils.StrCpy("Foobar"),
+				Paths:    []string{"/current/*"},
+				Window:   &models.Window{From: &past, To: &future, Schedules: everyDay},
+			},
+			{
+				Resource: utils.StrCpy("Foobar"),
+				Paths:    []string{"/upcoming/*"},
+				Window:   &models.Window{From: &future},
+			},
+		},
+	}
+	officePolicy := models.Policy{
+		Name:        utils.StrCpy("Office"),
+		Window:      &models.Window{To: &past},
+		Permissions: []models.Permission{{Resource: utils.StrCpy("Foobar"), Paths: []string{"/office/*"}}},
+	}
+	expiredPolicy := models.Policy{
+		Name:        utils.StrCpy("Expired"),
+		Window:      &models.Window{To: &past},
+		Permissions: []models.Permission{{Resource: utils.StrCpy("Foobar")}},
+	}
+	index.Load([]models.Resource{*testResource}, []models.Policy{contractorPolicy, officePolicy, expiredPolicy})
+
+	sessionsInter.session = &models.Session{Token: utils.StrCpy("C0ntr4ct0r"), Policies: []string{"Contractor", "Expired"}}
+
+	cases := []struct {
+		path    string
+		granted bool
+	}{
+		{"/current/foo", true},
+		{"/upcoming/foo", false},
+		{"/office/foo", false},
+	}
+
+	for _, c := range cases {
+		granted, _, err := inter.AuthorizeToken("foo.bar.com", c.path, "GET", "", "C0ntr4ct0r")
+		r.NoError(err)
+		a.Equal(c.granted, granted, c.path)
+	}
+
+	// Success: the windows are reported in the trace
+	decision, err := inter.Explain("foo.bar.com", "/upcoming/foo", "GET", "", "C0ntr4ct0r")
+	r.NoError(err)
+	r.Len(decision.Policies, 2)
+	a.True(decision.Policies[0].InWindow)
+	r.Len(decision.Policies[0].Permissions, 3)
+	a.Equal("no match", decision.Policies[0].Permissions[0].Status)
+	a.Equal("outside window", decision.Policies[0].Permissions[1].Status)
+	a.Equal("outside window", decision.Policies[0].Permissions[2].Status)
+	a.False(decision.Policies[1].InWindow)
+	a.Len(decision.Policies[1].Permissions, 0)
+
+	// Success: the decisions depending on the time are never cached
+	stats := inter.CacheStats()
+	a.Equal(uint64(0), stats.Hits)
+	a.Equal(0, stats.Entries)
+
+	// "Team" extends "Night" and "Day", which both extend "Shared". Only "Night" has a window
+	diamond := []models.Policy{
+		{Name: utils.StrCpy("Team"), Extends: []string{"Night", "Day"}},
+		{Name: utils.StrCpy("Night"), Window: &models.Window{To: &past}, Extends: []string{"Shared"}},
+		{Name: utils.StrCpy("Day"), Extends: []string{"Shared"}},
+		{
+			Name:        utils.StrCpy("Shared"),
+			Extends:     []string{"Team"}, // A cycle
+			Permissions: []models.Permission{{Resource: utils.StrCpy("Foobar"), Paths: []string{"/shared/*"}}},
+		},
+	}
+	index.Load([]models.Resource{*testResource}, diamond)
+
+	sessionsInter.session = &models.Session{Token: utils.StrCpy("T34m"), Policies: []string{"Team"}}
+
+	// Success: the permission inherited through "Day" is not restricted to the window of "Night"
+	decision, err = inter.Explain("foo.bar.com", "/shared/foo", "GET", "", "T34m")
+	r.NoError(err)
+	a.True(decision.Granted)
+	r.Len(decision.Policies, 1)
+	r.Len(decision.Policies[0].Permissions, 2)
+	a.Equal("outside window", decision.Policies[0].Permissions[0].Status)
+	a.Equal("applied", decision.Policies[0].Permissions[1].Status)
+}
+
+// TestAuthInterClientIP runs tests on the client IP restrictions of the resources and permissions.
+func TestAuthInterClientIP(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	index := NewAuthIndex(nil)
+	getter := utils.NewFakeModelsGetter()
+	getter.DecisionCacheTTL = time.Minute
+	getter.DecisionCacheSize = 10
+	sessionsInter := &authInterSessionsInter{}
+	inter := NewAuthInter(index, NewDecisionCache(getter, NewTokenHasher()), NewRateLimiter(), &authInterAuditInter{}, sessionsInter, getter)
+
+	vpnResource := models.Resource{
+		Name:       utils.StrCpy("Vpn"),
+		Hostname:   utils.StrCpy("vpn.bar.com"),
+		Public:     utils.BoolCpy(true),
+		AllowCIDRs: []string{"10.8.0.0/16"},
+		DenyCIDRs:  []string{"10.8.1.0/24"},
+	}
+	staffPolicy := models.Policy{
+		Name: utils.StrCpy("Staff"),
+		Permissions: []models.Permission{
+			{
+				Resource: utils.StrCpy("Foobar"),
+				Paths:    []string{"/**"},
+			},
+			{
+				Resource:  utils.StrCpy("Foobar"),
+				Paths:     []string{"/admin/**"},
+				DenyCIDRs: []string{"10.8.0.0/16"},
+				Deny:      utils.BoolCpy(true),
+			},
+			{
+				Resource:   utils.StrCpy("Foobar"),
+				Paths:      []string{"/broken"},
+				AllowCIDRs: []string{"10.8.0.0/33"},
+			},
+		},
+	}
+	index.Load([]models.Resource{*testResource, vpnResource}, []models.Policy{staffPolicy})
+
+	sessionsInter.session = &models.Session{Token: utils.StrCpy("St4ff"), Policies: []string{"Staff"}}
+
+	cases := []struct {
+		hostname, path, clientIP string
+		granted                  bool
+	}{
+		{"vpn.bar.com", "/", "10.8.0.1", true},
+		{"vpn.bar.com", "/", "10.8.1.1", false},
+		{"vpn.bar.com", "/", "192.0.2.1", false},
+		{"vpn.bar.com", "/", "", false},
+		{"foo.bar.com", "/admin/foo", "10.8.0.1", true},
+		{"foo.bar.com", "/admin/foo", "192.0.2.1", false},
+		{"foo.bar.com", "/admin/foo", "", false},
+		{"foo.bar.com", "/foo", "192.0.2.1", true},
+	}
+
+	for _, c := range cases {
+		granted, _, err := inter.AuthorizeToken(c.hostname, c.path, "GET", c.clientIP, "St4ff")
+		r.NoError(err)
+		a.Equal(c.granted, granted, c)
+
+		// The decisions are cached by client IP
+		granted, _, err = inter.AuthorizeToken(c.hostname, c.path, "GET", c.clientIP, "St4ff")
+		r.NoError(err)
+		a.Equal(c.granted, granted, c)
+	}
+
+	// Success: the client IP restrictions are reported in the trace
+	decision, err := inter.Explain("foo.bar.com", "/broken", "GET", "10.8.0.1", "St4ff")
+	r.NoError(err)
+	a.Equal("10.8.0.1", decision.ClientIP)
+	r.Len(decision.Policies, 1)
+	r.Len(decision.Policies[0].Permissions, 3)
+	a.Equal("applied", decision.Policies[0].Permissions[0].Status)
+	a.Equal("no match", decision.Policies[0].Permissions[1].Status)
+	a.Equal("invalid CIDR", decision.Policies[0].Permissions[2].Status)
+
+	decision, err = inter.Explain("foo.bar.com", "/admin/foo", "GET", "10.8.0.1", "St4ff")
+	r.NoError(err)
+	r.Len(decision.Policies, 1)
+	a.Equal("client IP mismatch", decision.Policies[0].Permissions[1].Status)
+	a.Len(decision.Policies[0].Permissions[1].DenyCIDRs, 1)
+
+	// Denied: the client IP is not allowed on the resource
+	decision, err = inter.Explain("vpn.bar.com", "/", "GET", "192.0.2.1", "St4ff")
+	r.NoError(err)
+	a.False(decision.Granted)
+	a.Equal("the client IP is not allowed on the resource", decision.Reason)
+}
+
+// TestAuthInterRateLimits runs tests on the rate limits of the resources and policies.
+func TestAuthInterRateLimits(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	index := NewAuthIndex(nil)
+	getter := utils.NewFakeModelsGetter()
+	getter.DecisionCacheTTL = time.Minute
+	getter.DecisionCacheSize = 10
+	sessionsInter := &authInterSessionsInter{}
+	auditInter := &authInterAuditInter{}
+	inter := NewAuthInter(index, NewDecisionCache(getter, NewTokenHasher()), NewRateLimiter(), auditInter, sessionsInter, getter)
+
+	resource := *testResource
+	resource.RateLimits = []models.RateLimit{{By: utils.StrCpy("clientIp"), Rate: utils.Float64Cpy(0.001), Burst: utils.IntCpy(3)}}
+	reportResource := models.Resource{
+		Name:       utils.StrCpy("Report"),
+		Hostname:   utils.StrCpy("report.bar.com"),
+		Public:     utils.BoolCpy(true),
+		Mode:       utils.StrCpy(models.ModeReport),
+		RateLimits: []models.RateLimit{{By: utils.StrCpy("resource"), Rate: utils.Float64Cpy(0.001)}},
+	}
+	scraperPolicy := models.Policy{
+		Name:        utils.StrCpy("Scraper"),
+		RateLimits:  []models.RateLimit{{By: utils.StrCpy("ownerToken"), Rate: utils.Float64Cpy(0.001), Burst: utils.IntCpy(2)}},
+		Permissions: []models.Permission{{Resource: utils.StrCpy("Foobar"), Paths: []string{"/foo/*"}}},
+	}
+	index.Load([]models.Resource{resource, reportResource}, []models.Policy{scraperPolicy})
+
+	sessionsInter.session = &models.Session{
+		Token:      utils.StrCpy("Scr4p3r"),
+		OwnerToken: utils.StrCpy("owner1"),
+		Policies:   []string{"Scraper"},
+	}
+
+	// Success: the owner limit allows two requests, even from the cache
+	for i := 0; i < 2; i++ {
+		granted, _, err := inter.AuthorizeToken("foo.bar.com", "/foo/bar", "GET", "10.0.0.1", "Scr4p3r")
+		r.NoError(err)
+		a.True(granted)
+	}
+
+	// Rate limited: the owner bucket is empty
+	granted, _, err := inter.AuthorizeToken("foo.bar.com", "/foo/baz", "GET", "10.0.0.1", "Scr4p3r")
+	r.Error(err)
+	a.False(granted)
+	r.IsType(errs.ErrRateLimited{}, err)
+	a.True(err.(errs.ErrRateLimited).RetryAfter > time.Minute)
+
+	// Denied: the denied requests are not limited
+	granted, _, err = inter.AuthorizeToken("foo.bar.com", "/bar", "GET", "10.0.0.1", "Scr4p3r")
+	r.NoError(err)
+	a.False(granted)
+
+	sessionsInter.session.OwnerToken = utils.StrCpy("owner2")
+
+	// Success: another owner has its own bucket but shares the client IP one, which has one token left
+	granted, _, err = inter.AuthorizeToken("foo.bar.com", "/foo/qux", "GET", "10.0.0.1", "Scr4p3r")
+	r.NoError(err)
+	a.True(granted)
+
+	// Rate limited: the client IP bucket is empty
+	_, _, err = inter.AuthorizeToken("foo.bar.com", "/foo/qux", "GET", "10.0.0.1", "Scr4p3r")
+	r.IsType(errs.ErrRateLimited{}, err)
+
+	// Success: the exceeded limits are only audited in report mode
+	for i := 0; i < 2; i++ {
+		granted, _, err = inter.AuthorizeToken("report.bar.com", "/", "GET", "10.0.0.1", "")
+		r.NoError(err)
+		a.True(granted)
+	}
+
+	r.Len(auditInter.entries, 1)
+	a.Equal("a rate limit is exceeded", auditInter.entries[0].Reason)
+}
+
+// TestAuthInterDecisionCache runs tests on the AuthInter decision caching.
+func TestAuthInterDecisionCache(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	index := newAuthInterIndex()
+	getter := utils.NewFakeModelsGetter()
+	getter.DecisionCacheTTL = time.Minute
+	getter.DecisionCacheSize = 10
+	sessionsInter := &authInterSessionsInter{}
+	inter := NewAuthInter(index, NewDecisionCache(getter, NewTokenHasher()), NewRateLimiter(), &authInterAuditInter{}, sessionsInter, getter)
+
+	// Success: evaluated
+	granted, session, err := inter.AuthorizeToken("foo.bar.com", "/foo/bar", "GET", "", "F00bAr")
+	r.NoError(err)
+	a.True(granted)
+	r.NotNil(session)
+
+	sessionsInter.errDB = true
+
+	// Success: cached, the session is not looked up
+	granted, session, err = inter.AuthorizeToken("foo.bar.com", "/foo/bar", "GET", "", "F00bAr")
+	r.NoError(err)
+	a.True(granted)
+	r.NotNil(session)
+
+	session.Policies = nil
+
+	// Success: cached, the returned sessions are copies
+	granted, session, err = inter.AuthorizeToken("foo.bar.com", "/foo/bar", "GET", "", "F00bAr")
+	r.NoError(err)
+	a.True(granted)
+	r.NotNil(session)
+	a.Equal(testSession.Policies, session.Policies)
+
+	sessionsInter.errDB = false
+	testResource.Public = utils.BoolCpy(true)
+	loadAuthInterIndex(index)
+
+	// Success: the index was rebuilt, the decision is evaluated again
+	granted, session, err = inter.AuthorizeToken("foo.bar.com", "/foo/bar", "GET", "", "F00bAr")
+	r.NoError(err)
+	a.True(granted)
+	a.Nil(session)
+
+	testResource.Public = nil
+	loadAuthInterIndex(index)
+
+	// The activity of the session is recorded on the cached decisions too, not on the public resources
+	a.Equal([]string{"F00bAr", "F00bAr", "F00bAr"}, sessionsInter.touched)
+
+	stats := inter.CacheStats()
+	a.Equal(uint64(2), stats.Hits)
+	a.Equal(uint64(2), stats.Misses)
+}
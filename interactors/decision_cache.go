@@ -0,0 +1,196 @@
+package interactors
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/solher/zest"
+)
+
+func init() {
+	zest.Injector.Register(NewDecisionCache)
+}
+
+type (
+	DecisionCacheOptionsGetter interface {
+		GetDecisionCacheTTL() time.Duration
+		GetDecisionCacheSize() int
+	}
+
+	DecisionCacheTokenHasher interface {
+		Hash(token string) string
+	}
+
+	// DecisionCache is a LRU cache of the authorization decisions.
+	// An entry is only valid for the index generation it was computed with,
+	// so any policy or resource change invalidates the whole cache.
+	DecisionCache struct {
+		g            DecisionCacheOptionsGetter
+		h            DecisionCacheTokenHasher
+		mu           sync.Mutex
+		entries      map[decisionKey]*list.Element
+		tokens       map[string]map[*list.Element]bool // The entries by session token hash
+		lru          *list.List
+		hits, misses uint64
+	}
+
+	decisionKey struct {
+		token, hostname, path, method, clientIP string
+	}
+
+	cachedDecision struct {
+		key        decisionKey
+		tokenHash  string
+		generation uint64
+		granted    bool
+		session    *models.Session
+		expires    time.Time
+	}
+)
+
+func NewDecisionCache(g DecisionCacheOptionsGetter, h DecisionCacheTokenHasher) *DecisionCache {
+	return &DecisionCache{
+		g:       g,
+		h:       h,
+		entries: map[decisionKey]*list.Element{},
+		tokens:  map[string]map[*list.Element]bool{},
+		lru:     list.New(),
+	}
+}
+
+// Get returns the cached decision for the given request, if any.
+// The returned session is a copy which can be freely modified.
+func (c *DecisionCache) Get(generation uint64, hostname, path, method, clientIP, token string) (bool, *models.Session, bool) {
+	if !c.enabled() {
+		return false, nil, false
+	}
+
+	key := decisionKey{token: token, hostname: hostname, path: path, method: method, clientIP: clientIP}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return false, nil, false
+	}
+
+	entry := elem.Value.(*cachedDecision)
+
+	// The stale entries are removed as soon as they are found
+	if entry.generation != generation || time.Now().After(entry.expires) {
+		c.remove(elem)
+		atomic.AddUint64(&c.misses, 1)
+		return false, nil, false
+	}
+
+	c.lru.MoveToFront(elem)
+	atomic.AddUint64(&c.hits, 1)
+
+	return entry.granted, copySession(entry.session), true
+}
+
+// Set caches a decision. The entry never outlives the session it was computed with.
+func (c *DecisionCache) Set(
+	generation uint64,
+	hostname, path, method, clientIP, token string,
+	granted bool,
+	session *models.Session,
+) {
+	if !c.enabled() {
+		return
+	}
+
+	entry := &cachedDecision{
+		key:        decisionKey{token: token, hostname: hostname, path: path, method: method, clientIP: clientIP},
+		tokenHash:  c.h.Hash(token),
+		generation: generation,
+		granted:    granted,
+		session:    copySession(session),
+		expires:    time.Now().Add(c.g.GetDecisionCacheTTL()),
+	}
+
+	if session != nil && session.ValidTo != nil && session.ValidTo.Before(entry.expires) {
+		entry.expires = *session.ValidTo
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[entry.key]; ok {
+		c.remove(elem)
+	}
+
+	elem := c.lru.PushFront(entry)
+	c.entries[entry.key] = elem
+
+	if c.tokens[entry.tokenHash] == nil {
+		c.tokens[entry.tokenHash] = map[*list.Element]bool{}
+	}
+	c.tokens[entry.tokenHash][elem] = true
+
+	// The least recently used entries are evicted when the cache is full
+	for size := c.g.GetDecisionCacheSize(); c.lru.Len() > size; {
+		c.remove(c.lru.Back())
+	}
+}
+
+// InvalidateTokens removes the decisions cached for the given session token hashes.
+// The sessions are only known by their token hash once stored.
+func (c *DecisionCache) InvalidateTokens(hashes ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, hash := range hashes {
+		for elem := range c.tokens[hash] {
+			c.remove(elem)
+		}
+	}
+}
+
+// Stats returns the cache counters.
+func (c *DecisionCache) Stats() *models.CacheStats {
+	c.mu.Lock()
+	entries := c.lru.Len()
+	c.mu.Unlock()
+
+	return &models.CacheStats{
+		Hits:    atomic.LoadUint64(&c.hits),
+		Misses:  atomic.LoadUint64(&c.misses),
+		Entries: entries,
+		Size:    c.g.GetDecisionCacheSize(),
+		TTL:     c.g.GetDecisionCacheTTL().String(),
+	}
+}
+
+func (c *DecisionCache) enabled() bool {
+	return c.g.GetDecisionCacheTTL() > 0 && c.g.GetDecisionCacheSize() > 0
+}
+
+// remove must be called with the lock held.
+func (c *DecisionCache) remove(elem *list.Element) {
+	entry := elem.Value.(*cachedDecision)
+
+	c.lru.Remove(elem)
+	delete(c.entries, entry.key)
+
+	delete(c.tokens[entry.tokenHash], elem)
+	if len(c.tokens[entry.tokenHash]) == 0 {
+		delete(c.tokens, entry.tokenHash)
+	}
+}
+
+// copySession returns a shallow copy of a session, as the callers are allowed to modify the returned one.
+func copySession(session *models.Session) *models.Session {
+	if session == nil {
+		return nil
+	}
+
+	s := *session
+
+	return &s
+}
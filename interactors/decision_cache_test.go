@@ -0,0 +1,91 @@
+package interactors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/solher/auth-nginx-proxy-companion/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDecisionCache runs tests on the DecisionCache methods.
+func TestDecisionCache(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	getter := utils.NewFakeModelsGetter()
+	hasher := NewTokenHasher()
+	cache := NewDecisionCache(getter, hasher)
+	session := &models.Session{
+		Token:   utils.StrCpy("F00bAr"),
+		ValidTo: utils.TimeCpy(time.Now().UTC().Add(time.Hour)),
+	}
+
+	cache.Set(1, "foo.bar.com", "/foo", "GET", "10.0.0.1", "F00bAr", true, session)
+
+	// Miss: the cache is disabled
+	_, _, ok := cache.Get(1, "foo.bar.com", "/foo", "GET", "10.0.0.1", "F00bAr")
+	a.False(ok)
+
+	getter.DecisionCacheTTL = time.Minute
+	getter.DecisionCacheSize = 2
+	cache.Set(1, "foo.bar.com", "/foo", "GET", "10.0.0.1", "F00bAr", true, session)
+
+	// Hit
+	granted, cached, ok := cache.Get(1, "foo.bar.com", "/foo", "GET", "10.0.0.1", "F00bAr")
+	r.True(ok)
+	a.True(granted)
+	r.NotNil(cached)
+	a.Equal(*session.Token, *cached.Token)
+
+	// Hit: the returned session is a copy
+	cached.Token = nil
+	_, cached, ok = cache.Get(1, "foo.bar.com", "/foo", "GET", "10.0.0.1", "F00bAr")
+	r.True(ok)
+	a.NotNil(cached.Token)
+
+	// Miss: another method
+	_, _, ok = cache.Get(1, "foo.bar.com", "/foo", "POST", "10.0.0.1", "F00bAr")
+	a.False(ok)
+
+	// Miss: the index was rebuilt
+	_, _, ok = cache.Get(2, "foo.bar.com", "/foo", "GET", "10.0.0.1", "F00bAr")
+	a.False(ok)
+	a.Equal(0, cache.Stats().Entries)
+
+	cache.Set(2, "foo.bar.com", "/foo", "GET", "10.0.0.1", "F00bAr", true, session)
+	cache.Set(2, "foo.bar.com", "/bar", "GET", "10.0.0.1", "", false, nil)
+	cache.InvalidateTokens(hasher.Hash("F00bAr"))
+
+	// Miss: the session was revoked
+	_, _, ok = cache.Get(2, "foo.bar.com", "/foo", "GET", "10.0.0.1", "F00bAr")
+	a.False(ok)
+
+	// Hit: guest decision
+	granted, cached, ok = cache.Get(2, "foo.bar.com", "/bar", "GET", "10.0.0.1", "")
+	r.True(ok)
+	a.False(granted)
+	a.Nil(cached)
+
+	cache.Set(2, "foo.bar.com", "/foo", "GET", "10.0.0.1", "F00bAr", true, session)
+	cache.Set(2, "foo.bar.com", "/baz", "GET", "10.0.0.1", "F00bAr", true, session)
+
+	// Miss: the least recently used entry was evicted
+	_, _, ok = cache.Get(2, "foo.bar.com", "/bar", "GET", "10.0.0.1", "")
+	a.False(ok)
+	a.Equal(2, cache.Stats().Entries)
+
+	session.ValidTo = utils.TimeCpy(time.Now().UTC().Add(-time.Second))
+	cache.Set(2, "foo.bar.com", "/foo", "GET", "10.0.0.1", "F00bAr", true, session)
+
+	// Miss: the entry does not outlive the session
+	_, _, ok = cache.Get(2, "foo.bar.com", "/foo", "GET", "10.0.0.1", "F00bAr")
+	a.False(ok)
+
+	stats := cache.Stats()
+	a.Equal(uint64(3), stats.Hits)
+	a.Equal(uint64(5), stats.Misses)
+	a.Equal(2, stats.Size)
+	a.Equal("1m0s", stats.TTL)
+}
@@ -28,10 +28,15 @@ type (
 		ValidateDeletion(policy *models.Policy) error
 	}
 
+	PoliciesInterAuthIndex interface {
+		Rebuild() error
+	}
+
 	PoliciesInter struct {
-		r  PoliciesInterPoliciesRepo
-		si PoliciesInterSessionsInter
-		v  PoliciesInterPoliciesValidator
+		r   PoliciesInterPoliciesRepo
+		si  PoliciesInterSessionsInter
+		v   PoliciesInterPoliciesValidator
+		idx PoliciesInterAuthIndex
 	}
 )
 
@@ -39,8 +44,9 @@ func NewPoliciesInter(
 	r PoliciesInterPoliciesRepo,
 	si PoliciesInterSessionsInter,
 	v PoliciesInterPoliciesValidator,
+	idx PoliciesInterAuthIndex,
 ) *PoliciesInter {
-	return &PoliciesInter{r: r, si: si, v: v}
+	return &PoliciesInter{r: r, si: si, v: v, idx: idx}
 }
 
 func (i *PoliciesInter) Find() ([]models.Policy, error) {
@@ -110,6 +116,10 @@ func (i *PoliciesInter) Create(policy *models.Policy) (*models.Policy, error) {
 		return nil, err
 	}
 
+	if err := i.idx.Rebuild(); err != nil {
+		return nil, err
+	}
+
 	return policy, nil
 }
 
@@ -124,13 +134,55 @@ func (i *PoliciesInter) DeleteByName(name string) (*models.Policy, error) {
 	}
 
 	err = i.r.Update(func(tx *bolt.Tx) error {
-		return tx.Bucket([]byte("policies")).Delete([]byte(name))
+		b := tx.Bucket([]byte("policies"))
+
+		if err := b.Delete([]byte(name)); err != nil {
+			return err
+		}
+
+		// The deleted policy is removed from the policies extending it
+		c := b.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			p := models.Policy{}
+			if err := json.Unmarshal(v, &p); err != nil {
+				return err
+			}
+
+			newExtends := []string{}
+
+			for _, parent := range p.Extends {
+				if parent == name {
+					continue
+				}
+
+				newExtends = append(newExtends, parent)
+			}
+
+			if len(newExtends) == len(p.Extends) {
+				continue
+			}
+
+			p.Extends = newExtends
+
+			raw, _ := json.Marshal(p)
+
+			if err := b.Put(k, raw); err != nil {
+				return err
+			}
+		}
+
+		return nil
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
+	if err := i.idx.Rebuild(); err != nil {
+		return nil, err
+	}
+
 	if err := i.si.DeleteCascade(policy); err != nil {
 		return nil, err
 	}
@@ -138,6 +190,8 @@ func (i *PoliciesInter) DeleteByName(name string) (*models.Policy, error) {
 	return policy, nil
 }
 
+// DeleteCascade removes the permissions concerning the given resource.
+// The auth index is not rebuilt: it is the responsibility of the caller, once the resource itself is deleted.
 func (i *PoliciesInter) DeleteCascade(resource *models.Resource) error {
 	if resource == nil {
 		return errors.New("nil resource")
@@ -202,5 +256,9 @@ func (i *PoliciesInter) UpdateByName(name string, policy *models.Policy) (*model
 		return nil, err
 	}
 
+	if err := i.idx.Rebuild(); err != nil {
+		return nil, err
+	}
+
 	return policy, nil
 }
@@ -55,12 +55,24 @@ func (v *policiesInterPoliciesValid) ValidateDeletion(policy *models.Policy) err
 	return nil
 }
 
+type policiesInterAuthIndex struct {
+	err bool
+}
+
+func (i *policiesInterAuthIndex) Rebuild() error {
+	if i.err {
+		return errs.Internal.Database
+	}
+
+	return nil
+}
+
 // TestPoliciesInterFind runs tests on the PoliciesInter Find method.
 func TestPoliciesInterFind(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
 	repo := &policiesInterPoliciesRepo{}
-	inter := NewPoliciesInter(repo, nil, nil)
+	inter := NewPoliciesInter(repo, nil, nil, &policiesInterAuthIndex{})
 
 	// Success
 	result, err := inter.Find()
@@ -81,7 +93,7 @@ func TestPoliciesInterFindByName(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
 	repo := &policiesInterPoliciesRepo{}
-	inter := NewPoliciesInter(repo, nil, nil)
+	inter := NewPoliciesInter(repo, nil, nil, &policiesInterAuthIndex{})
 
 	// Not found
 	result, err := inter.FindByName("")
@@ -103,7 +115,8 @@ func TestPoliciesInterCreate(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
 	repo := &policiesInterPoliciesRepo{}
-	inter := NewPoliciesInter(repo, nil, nil)
+	index := &policiesInterAuthIndex{}
+	inter := NewPoliciesInter(repo, nil, nil, index)
 
 	// Success
 	repo.err = false
@@ -123,6 +136,15 @@ func TestPoliciesInterCreate(t *testing.T) {
 	r.Error(err)
 	a.IsType(errs.Internal.Database, err)
 	a.Nil(result)
+
+	repo.err = false
+	index.err = true
+
+	// Index error
+	result, err = inter.Create(&models.Policy{})
+	r.Error(err)
+	a.IsType(errs.Internal.Database, err)
+	a.Nil(result)
 }
 
 // TestPoliciesInterDeleteByName runs tests on the PoliciesInter DeleteByName method.
@@ -132,7 +154,7 @@ func TestPoliciesInterDeleteByName(t *testing.T) {
 	repo := &policiesInterPoliciesRepo{}
 	sessionsInter := &policiesInterSessionsInter{}
 	valid := &policiesInterPoliciesValid{}
-	inter := NewPoliciesInter(repo, sessionsInter, valid)
+	inter := NewPoliciesInter(repo, sessionsInter, valid, &policiesInterAuthIndex{})
 
 	valid.errValid = true
 
@@ -173,7 +195,7 @@ func TestPoliciesInterUpdateByName(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
 	repo := &policiesInterPoliciesRepo{}
-	inter := NewPoliciesInter(repo, nil, nil)
+	inter := NewPoliciesInter(repo, nil, nil, &policiesInterAuthIndex{})
 
 	// Not found
 	result, err := inter.UpdateByName("", &models.Policy{})
@@ -0,0 +1,118 @@
+package interactors
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/solher/zest"
+)
+
+func init() {
+	zest.Injector.Register(NewRateLimiter)
+}
+
+// The number of buckets from which the idle ones start to be swept.
+const minSweepSize = 1024
+
+type (
+	// RateLimiter keeps the token buckets of the rate limits in memory.
+	// The buckets are local to the instance.
+	RateLimiter struct {
+		mu        sync.Mutex
+		buckets   map[string]*bucket
+		sweepSize int
+		now       func() time.Time // Replaced in tests
+	}
+
+	// Limit is a bucket to take a token from.
+	Limit struct {
+		Key   string
+		Rate  float64 // The tokens added per second
+		Burst int     // The capacity of the bucket
+	}
+
+	bucket struct {
+		tokens  float64
+		updated time.Time
+		full    time.Time // When the bucket will be full again
+	}
+)
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		buckets:   map[string]*bucket{},
+		sweepSize: minSweepSize,
+		now:       time.Now,
+	}
+}
+
+// Take takes a token from each of the given buckets, only if all of them have one.
+// Otherwise, nothing is taken and the duration after which all the buckets will have a token is returned.
+func (l *RateLimiter) Take(limits []Limit) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	buckets := make([]*bucket, len(limits))
+	var retryAfter time.Duration
+
+	for idx, limit := range limits {
+		b := l.buckets[limit.Key]
+		if b == nil {
+			b = &bucket{tokens: float64(limit.Burst), updated: now}
+			l.buckets[limit.Key] = b
+		}
+
+		b.update(limit, now, 0)
+		buckets[idx] = b
+
+		if b.tokens < 1 {
+			if wait := duration((1 - b.tokens) / limit.Rate); wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+	}
+
+	if retryAfter == 0 {
+		for idx, b := range buckets {
+			b.update(limits[idx], now, 1)
+		}
+	}
+
+	if len(l.buckets) >= l.sweepSize {
+		l.sweep(now)
+	}
+
+	return retryAfter == 0, retryAfter
+}
+
+// sweep removes the buckets which are full again, as they are recreated full.
+func (l *RateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if !now.Before(b.full) {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.sweepSize = 2 * len(l.buckets)
+	if l.sweepSize < minSweepSize {
+		l.sweepSize = minSweepSize
+	}
+}
+
+// update adds the tokens accumulated since the last update, up to the bucket capacity, then takes the given ones.
+func (b *bucket) update(limit Limit, now time.Time, taken float64) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * limit.Rate
+	}
+
+	b.tokens = math.Min(b.tokens, float64(limit.Burst)) - taken
+	b.updated = now
+	b.full = now.Add(duration((float64(limit.Burst) - b.tokens) / limit.Rate))
+}
+
+// duration converts seconds to a duration, rounded up to the nanosecond.
+func duration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
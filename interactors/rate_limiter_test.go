@@ -0,0 +1,76 @@
+package interactors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRateLimiterTake runs tests on the RateLimiter Take method.
+func TestRateLimiterTake(t *testing.T) {
+	a := assert.New(t)
+	limiter := NewRateLimiter()
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	perToken := Limit{Key: "token", Rate: 2, Burst: 3}
+	perResource := Limit{Key: "resource", Rate: 1, Burst: 1}
+
+	// Success: the burst is available at once
+	for i := 0; i < 3; i++ {
+		ok, _ := limiter.Take([]Limit{perToken})
+		a.True(ok)
+	}
+
+	// Failure: the bucket is empty, a token comes back in half a second
+	ok, retryAfter := limiter.Take([]Limit{perToken})
+	a.False(ok)
+	a.Equal(500*time.Millisecond, retryAfter)
+
+	now = now.Add(500 * time.Millisecond)
+
+	// Success: the bucket was refilled
+	ok, _ = limiter.Take([]Limit{perToken, perResource})
+	a.True(ok)
+
+	now = now.Add(500 * time.Millisecond)
+
+	// Failure: nothing is taken if a bucket is empty
+	ok, retryAfter = limiter.Take([]Limit{perToken, perResource})
+	a.False(ok)
+	a.Equal(500*time.Millisecond, retryAfter)
+	ok, _ = limiter.Take([]Limit{perToken})
+	a.True(ok)
+
+	now = now.Add(time.Hour)
+
+	// Success: the refill never exceeds the burst
+	for i := 0; i < 3; i++ {
+		ok, _ := limiter.Take([]Limit{perToken})
+		a.True(ok)
+	}
+	ok, _ = limiter.Take([]Limit{perToken})
+	a.False(ok)
+}
+
+// TestRateLimiterSweep runs tests on the removal of the full buckets.
+func TestRateLimiterSweep(t *testing.T) {
+	a := assert.New(t)
+	limiter := NewRateLimiter()
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+	limiter.sweepSize = 3
+
+	limiter.Take([]Limit{{Key: "slow", Rate: 0.01, Burst: 1}})
+	limiter.Take([]Limit{{Key: "fast", Rate: 100, Burst: 1}})
+
+	now = now.Add(time.Second)
+	limiter.Take([]Limit{{Key: "other", Rate: 1, Burst: 2}})
+
+	// The full buckets are removed, the slow one is still being refilled
+	a.Len(limiter.buckets, 2)
+	a.Contains(limiter.buckets, "slow")
+	a.Contains(limiter.buckets, "other")
+	a.Equal(minSweepSize, limiter.sweepSize)
+}
@@ -24,14 +24,19 @@ type (
 		DeleteCascade(resource *models.Resource) error
 	}
 
+	ResourcesInterAuthIndex interface {
+		Rebuild() error
+	}
+
 	ResourcesInter struct {
-		r  ResourcesInterResourcesRepo
-		pi ResourcesInterPoliciesInter
+		r   ResourcesInterResourcesRepo
+		pi  ResourcesInterPoliciesInter
+		idx ResourcesInterAuthIndex
 	}
 )
 
-func NewResourcesInter(r ResourcesInterResourcesRepo, pi ResourcesInterPoliciesInter) *ResourcesInter {
-	return &ResourcesInter{r: r, pi: pi}
+func NewResourcesInter(r ResourcesInterResourcesRepo, pi ResourcesInterPoliciesInter, idx ResourcesInterAuthIndex) *ResourcesInter {
+	return &ResourcesInter{r: r, pi: pi, idx: idx}
 }
 
 func (i *ResourcesInter) Find() ([]models.Resource, error) {
@@ -58,11 +63,11 @@ func (i *ResourcesInter) Find() ([]models.Resource, error) {
 	return resources, nil
 }
 
-func (i *ResourcesInter) FindByHostname(hostname string) (*models.Resource, error) {
+func (i *ResourcesInter) FindByName(name string) (*models.Resource, error) {
 	var raw []byte
 
 	err := i.r.View(func(tx *bolt.Tx) error {
-		raw = tx.Bucket([]byte("resources")).Get([]byte(hostname))
+		raw = tx.Bucket([]byte("resources")).Get([]byte(name))
 
 		return nil
 	})
@@ -94,24 +99,28 @@ func (i *ResourcesInter) Create(resource *models.Resource) (*models.Resource, er
 
 		raw, _ := json.Marshal(resource)
 
-		return b.Put([]byte(*resource.Hostname), raw)
+		return b.Put([]byte(*resource.Name), raw)
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
+	if err := i.idx.Rebuild(); err != nil {
+		return nil, err
+	}
+
 	return resource, nil
 }
 
-func (i *ResourcesInter) DeleteByHostname(hostname string) (*models.Resource, error) {
-	resource, err := i.FindByHostname(hostname)
+func (i *ResourcesInter) DeleteByName(name string) (*models.Resource, error) {
+	resource, err := i.FindByName(name)
 	if err != nil {
 		return nil, err
 	}
 
 	err = i.r.Update(func(tx *bolt.Tx) error {
-		return tx.Bucket([]byte("resources")).Delete([]byte(hostname))
+		return tx.Bucket([]byte("resources")).Delete([]byte(name))
 	})
 
 	if err != nil {
@@ -122,29 +131,37 @@ func (i *ResourcesInter) DeleteByHostname(hostname string) (*models.Resource, er
 		return nil, err
 	}
 
+	if err := i.idx.Rebuild(); err != nil {
+		return nil, err
+	}
+
 	return resource, nil
 }
 
-func (i *ResourcesInter) UpdateByHostname(hostname string, resource *models.Resource) (*models.Resource, error) {
+func (i *ResourcesInter) UpdateByName(name string, resource *models.Resource) (*models.Resource, error) {
 	if resource == nil {
 		return nil, errors.New("nil resource")
 	}
 
-	oldResource, err := i.FindByHostname(hostname)
+	oldResource, err := i.FindByName(name)
 	if err != nil {
 		return nil, err
 	}
 
-	resource.Hostname = oldResource.Hostname
+	resource.Name = oldResource.Name
 
 	err = i.r.Update(func(tx *bolt.Tx) error {
 		raw, _ := json.Marshal(resource)
-		return tx.Bucket([]byte("resources")).Put([]byte(hostname), raw)
+		return tx.Bucket([]byte("resources")).Put([]byte(name), raw)
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
+	if err := i.idx.Rebuild(); err != nil {
+		return nil, err
+	}
+
 	return resource, nil
 }
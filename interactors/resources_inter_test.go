@@ -42,12 +42,24 @@ func (r *resourcesInterPoliciesInter) DeleteCascade(resource *models.Resource) e
 	return nil
 }
 
+type resourcesInterAuthIndex struct {
+	err bool
+}
+
+func (i *resourcesInterAuthIndex) Rebuild() error {
+	if i.err {
+		return errs.Internal.Database
+	}
+
+	return nil
+}
+
 // TestResourcesInterFind runs tests on the ResourcesInter Find method.
 func TestResourcesInterFind(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
 	repo := &resourcesInterResourcesRepo{}
-	inter := NewResourcesInter(repo, nil)
+	inter := NewResourcesInter(repo, nil, &resourcesInterAuthIndex{})
 
 	// Success
 	result, err := inter.Find()
@@ -63,15 +75,15 @@ func TestResourcesInterFind(t *testing.T) {
 	a.Nil(result)
 }
 
-// TestResourcesInterFindByHostname runs tests on the ResourcesInter FindByHostname method.
-func TestResourcesInterFindByHostname(t *testing.T) {
+// TestResourcesInterFindByName runs tests on the ResourcesInter FindByName method.
+func TestResourcesInterFindByName(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
 	repo := &resourcesInterResourcesRepo{}
-	inter := NewResourcesInter(repo, nil)
+	inter := NewResourcesInter(repo, nil, &resourcesInterAuthIndex{})
 
 	// Not found
-	result, err := inter.FindByHostname("")
+	result, err := inter.FindByName("")
 	r.Error(err)
 	a.IsType(errs.Internal.NotFound, err)
 	a.Nil(result)
@@ -79,7 +91,7 @@ func TestResourcesInterFindByHostname(t *testing.T) {
 	repo.err = true
 
 	// Database error
-	result, err = inter.FindByHostname("")
+	result, err = inter.FindByName("")
 	r.Error(err)
 	a.IsType(errs.Internal.Database, err)
 	a.Nil(result)
@@ -90,7 +102,8 @@ func TestResourcesInterCreate(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
 	repo := &resourcesInterResourcesRepo{}
-	inter := NewResourcesInter(repo, nil)
+	index := &resourcesInterAuthIndex{}
+	inter := NewResourcesInter(repo, nil, index)
 
 	// Success
 	result, err := inter.Create(&models.Resource{})
@@ -109,18 +122,27 @@ func TestResourcesInterCreate(t *testing.T) {
 	r.Error(err)
 	a.IsType(errs.Internal.Database, err)
 	a.Nil(result)
+
+	repo.err = false
+	index.err = true
+
+	// Index error
+	result, err = inter.Create(&models.Resource{})
+	r.Error(err)
+	a.IsType(errs.Internal.Database, err)
+	a.Nil(result)
 }
 
-// TestResourcesInterDeleteByHostname runs tests on the ResourcesInter DeleteByHostname method.
-func TestResourcesInterDeleteByHostname(t *testing.T) {
+// TestResourcesInterDeleteByName runs tests on the ResourcesInter DeleteByName method.
+func TestResourcesInterDeleteByName(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
 	repo := &resourcesInterResourcesRepo{}
 	policiesInter := &resourcesInterPoliciesInter{}
-	inter := NewResourcesInter(repo, policiesInter)
+	inter := NewResourcesInter(repo, policiesInter, &resourcesInterAuthIndex{})
 
 	// Not found
-	result, err := inter.DeleteByHostname("")
+	result, err := inter.DeleteByName("")
 	r.Error(err)
 	a.IsType(errs.Internal.NotFound, err)
 	a.Nil(result)
@@ -128,7 +150,7 @@ func TestResourcesInterDeleteByHostname(t *testing.T) {
 	repo.err = true
 
 	// Database error
-	result, err = inter.DeleteByHostname("")
+	result, err = inter.DeleteByName("")
 	r.Error(err)
 	a.IsType(errs.Internal.Database, err)
 	a.Nil(result)
@@ -137,34 +159,34 @@ func TestResourcesInterDeleteByHostname(t *testing.T) {
 	policiesInter.err = true
 
 	// Database error when cascade
-	result, err = inter.DeleteByHostname("")
+	result, err = inter.DeleteByName("")
 	r.Error(err)
 	// a.IsType(errs.Internal.Database, err)
 	a.IsType(errs.Internal.NotFound, err) // Can't mock BoltDB...
 	a.Nil(result)
 }
 
-// TestResourcesInterUpdateByHostname runs tests on the ResourcesInter UpdateByHostname method.
-func TestResourcesInterUpdateByHostname(t *testing.T) {
+// TestResourcesInterUpdateByName runs tests on the ResourcesInter UpdateByName method.
+func TestResourcesInterUpdateByName(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
 	repo := &resourcesInterResourcesRepo{}
-	inter := NewResourcesInter(repo, nil)
+	inter := NewResourcesInter(repo, nil, &resourcesInterAuthIndex{})
 
 	// Not found
-	result, err := inter.UpdateByHostname("", &models.Resource{})
+	result, err := inter.UpdateByName("", &models.Resource{})
 	r.Error(err)
 	a.IsType(errs.Internal.NotFound, err)
 	a.Nil(result)
 
 	// Nil error
-	result, err = inter.UpdateByHostname("", nil)
+	result, err = inter.UpdateByName("", nil)
 	r.Error(err)
 	a.Nil(result)
 
 	// Database error
 	repo.err = true
-	result, err = inter.UpdateByHostname("", &models.Resource{})
+	result, err = inter.UpdateByName("", &models.Resource{})
 	r.Error(err)
 	a.IsType(errs.Internal.Database, err)
 	a.Nil(result)
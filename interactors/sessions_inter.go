@@ -1,8 +1,14 @@
 package interactors
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/solher/auth-nginx-proxy-companion/errs"
@@ -16,6 +22,12 @@ func init() {
 	zest.Injector.Register(NewSessionsInter)
 }
 
+// The number of sessions returned when no limit is given, and the maximum one.
+const (
+	defaultSessionsLimit = 100
+	maxSessionsLimit     = 1000
+)
+
 type (
 	SessionsInterSessionsRepo interface {
 		Update(func(tx *bolt.Tx) error) error
@@ -25,34 +37,86 @@ type (
 	SessionOptionsGetter interface {
 		GetSessionValidity() time.Duration
 		GetSessionTokenLength() int
+		GetSessionIdleTimeout() time.Duration
+		GetSessionMaxLifetime() time.Duration
+		GetRefreshTokenValidity() time.Duration
+		GetTokenFormat() string
+	}
+
+	SessionsInterDecisionCache interface {
+		InvalidateTokens(hashes ...string)
+	}
+
+	SessionsInterTokenHasher interface {
+		Hash(token string) string
+	}
+
+	SessionsInterTokenSigner interface {
+		Sign(id string, session *models.Session) (string, error)
+		Verify(token string) (*models.Session, error)
 	}
 
+	// SessionsInter stores the sessions keyed by the hash of their token. The stored sessions
+	// don't hold their token, which is only returned at creation.
+	// The "sessionOwners" bucket indexes the keys of the sessions by owner token then by validity, in a bucket per owner.
+	// The "sessionExpiries" bucket indexes them by validity, so the live ones are found without scanning the others.
+	// In jwt mode, the sessions are still stored but the tokens are verified without any lookup.
 	SessionsInter struct {
 		r SessionsInterSessionsRepo
 		g SessionOptionsGetter
+		c SessionsInterDecisionCache
+		h SessionsInterTokenHasher
+		s SessionsInterTokenSigner
+
+		mu       sync.Mutex
+		activity map[string]time.Time // The last activity of the sessions by token hash, not flushed yet
+
+		rmu     sync.RWMutex
+		revoked map[string]time.Time // The expiry of the revoked JWT tokens by ID
 	}
 )
 
-func NewSessionsInter(r SessionsInterSessionsRepo, g SessionOptionsGetter) *SessionsInter {
-	return &SessionsInter{r: r, g: g}
+func NewSessionsInter(
+	r SessionsInterSessionsRepo,
+	g SessionOptionsGetter,
+	c SessionsInterDecisionCache,
+	h SessionsInterTokenHasher,
+	s SessionsInterTokenSigner,
+) *SessionsInter {
+	return &SessionsInter{
+		r:        r,
+		g:        g,
+		c:        c,
+		h:        h,
+		s:        s,
+		activity: map[string]time.Time{},
+		revoked:  map[string]time.Time{},
+	}
 }
 
 func (i *SessionsInter) Find() ([]models.Session, error) {
 	sessions := []models.Session{}
+	now := time.Now()
 
 	err := i.r.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket([]byte("sessions")).Cursor()
+		b := tx.Bucket([]byte("sessions"))
+
+		for _, k := range expiringKeys(tx, &now, nil) {
+			raw := b.Get(k)
+			if raw == nil {
+				continue
+			}
 
-		for k, v := c.First(); k != nil; k, v = c.Next() {
 			session := models.Session{}
-			if err := json.Unmarshal(v, &session); err != nil {
+			if err := json.Unmarshal(raw, &session); err != nil {
 				return err
 			}
 
-			if session.ValidTo.Before(time.Now()) {
+			if session.ValidTo.Before(now) {
 				continue
 			}
 
+			session.RefreshToken = nil
 			sessions = append(sessions, session)
 		}
 
@@ -66,11 +130,220 @@ func (i *SessionsInter) Find() ([]models.Session, error) {
 	return sessions, nil
 }
 
+// FindByFilter returns a page of the live sessions matching the filter, in the order of their validity.
+// The sessions of an owner are listed from the owner index, without scanning the other sessions.
+func (i *SessionsInter) FindByFilter(filter *models.SessionFilter) (*models.SessionPage, error) {
+	after, err := decodeCursor(filter.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.SessionPage{Sessions: []models.Session{}}
+	now := time.Now()
+
+	err = i.r.View(func(tx *bolt.Tx) error {
+		var err error
+		page, err = findPage(tx, filter, after, &now)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// errInvalidCursor is returned when the cursor of a page was not returned with the previous one.
+var errInvalidCursor = errs.NewErrValidation("invalid cursor")
+
+// pageCursor is the position of the last session of a page in the validity index. As the sessions keys are
+// the hashes of their tokens, it is given by the validity of the session and by its rank among the sessions
+// of the same validity, so the cursor doesn't disclose them.
+type pageCursor struct {
+	validity []byte
+	rank     uint32
+}
+
+// encode returns the opaque form of the cursor, returned with the page.
+func (c *pageCursor) encode() string {
+	raw := make([]byte, 12)
+	copy(raw, c.validity)
+	binary.BigEndian.PutUint32(raw[8:], c.rank)
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeCursor parses the cursor returned with the previous page. It returns nil for the first page.
+func decodeCursor(cursor string) (*pageCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(raw) != 12 {
+		return nil, errInvalidCursor
+	}
+
+	return &pageCursor{validity: raw[:8], rank: binary.BigEndian.Uint32(raw[8:])}, nil
+}
+
+// findPage returns a page of the sessions of the transaction matching the filter, in the order of their validity.
+// Only the sessions valid at the given time are returned, if any. The page is read from the validity index of
+// the owner in the "sessionOwners" bucket, or from the "sessionExpiries" bucket, seeking from the cursor of the
+// previous page. So a page only reads the sessions up to its end, except the first one which counts them all.
+func findPage(tx *bolt.Tx, filter *models.SessionFilter, after *pageCursor, now *time.Time) (*models.SessionPage, error) {
+	page := &models.SessionPage{Sessions: []models.Session{}}
+	sessions := tx.Bucket([]byte("sessions"))
+	total := 0
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultSessionsLimit
+	}
+	if limit > maxSessionsLimit {
+		limit = maxSessionsLimit
+	}
+
+	index := tx.Bucket([]byte("sessionExpiries"))
+	if filter.OwnerToken != "" {
+		index = tx.Bucket([]byte("sessionOwners")).Bucket([]byte(filter.OwnerToken))
+	}
+
+	if index == nil {
+		if filter.Cursor == "" {
+			page.Total = utils.IntCpy(0)
+		}
+
+		return page, nil
+	}
+
+	from := filter.ExpiresSince
+	if now != nil && (from == nil || from.Before(*now)) {
+		from = now
+	}
+
+	start := []byte{}
+	if from != nil {
+		start = ExpiryKey(*from, "")
+	}
+
+	// The sessions before the cursor may have expired since the previous page
+	if after != nil && bytes.Compare(after.validity, start) >= 0 {
+		start = after.validity
+	} else {
+		after = nil
+	}
+
+	var validity []byte
+	var rank uint32
+	var last *pageCursor
+
+	c := index.Cursor()
+
+	for k, _ := c.Seek(start); k != nil && len(k) >= 8; k, _ = c.Next() {
+		if filter.ExpiresUntil != nil && bytes.Compare(k, ExpiryKey(*filter.ExpiresUntil, "")) >= 0 {
+			break
+		}
+
+		if bytes.Equal(k[:8], validity) {
+			rank++
+		} else {
+			validity = append(validity[:0], k[:8]...)
+			rank = 1
+		}
+
+		if after != nil && bytes.Equal(validity, after.validity) && rank <= after.rank {
+			continue
+		}
+
+		raw := sessions.Get(k[8:])
+		if raw == nil {
+			continue
+		}
+
+		session := models.Session{}
+		if err := json.Unmarshal(raw, &session); err != nil {
+			return nil, err
+		}
+
+		// An index entry left behind by a previous validity is skipped
+		if session.ValidTo == nil || !bytes.Equal(ExpiryKey(*session.ValidTo, string(k[8:])), k) {
+			continue
+		}
+
+		if now != nil && session.ValidTo.Before(*now) || !matchSession(filter, &session) {
+			continue
+		}
+
+		if len(page.Sessions) == limit {
+			page.NextCursor = last.encode()
+
+			// The matching sessions of all the pages are only counted on the first one
+			if filter.Cursor != "" {
+				return page, nil
+			}
+
+			total++
+			continue
+		}
+
+		total++
+		session.RefreshToken = nil
+		page.Sessions = append(page.Sessions, session)
+		last = &pageCursor{validity: append([]byte{}, validity...), rank: rank}
+	}
+
+	if filter.Cursor == "" {
+		page.Total = utils.IntCpy(total)
+	}
+
+	return page, nil
+}
+
+// matchSession returns true if the session matches the filter.
+func matchSession(filter *models.SessionFilter, session *models.Session) bool {
+	switch {
+	case filter.OwnerToken != "" && (session.OwnerToken == nil || *session.OwnerToken != filter.OwnerToken),
+		filter.Agent != "" && (session.Agent == nil || !strings.Contains(strings.ToLower(*session.Agent), strings.ToLower(filter.Agent))),
+		filter.CreatedSince != nil && (session.Created == nil || session.Created.Before(*filter.CreatedSince)),
+		filter.CreatedUntil != nil && (session.Created == nil || !session.Created.Before(*filter.CreatedUntil)),
+		filter.ExpiresSince != nil && session.ValidTo.Before(*filter.ExpiresSince),
+		filter.ExpiresUntil != nil && !session.ValidTo.Before(*filter.ExpiresUntil):
+		return false
+	}
+
+	if filter.Policy == "" {
+		return true
+	}
+
+	for _, policy := range session.Policies {
+		if policy == filter.Policy {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FindByToken returns the session with its token, which is already known to the caller.
+// In jwt mode, a valid JWT is only checked against the revocation list.
 func (i *SessionsInter) FindByToken(token string) (*models.Session, error) {
+	if IsJWT(token) && i.g.GetTokenFormat() == models.TokenJWT {
+		// The opaque tokens issued before the jwt mode are still looked up
+		if session, err := i.s.Verify(token); err == nil {
+			if i.isRevoked(*session.TokenID) {
+				return nil, errs.Internal.NotFound
+			}
+
+			return session, nil
+		}
+	}
+
 	var raw []byte
 
 	err := i.r.View(func(tx *bolt.Tx) error {
-		raw = tx.Bucket([]byte("sessions")).Get([]byte(token))
+		raw = tx.Bucket([]byte("sessions")).Get([]byte(i.h.Hash(token)))
 
 		return nil
 	})
@@ -93,6 +366,9 @@ func (i *SessionsInter) FindByToken(token string) (*models.Session, error) {
 		return nil, errs.Internal.NotFound
 	}
 
+	session.Token = &token
+	session.RefreshToken = nil
+
 	return session, nil
 }
 
@@ -102,130 +378,932 @@ func (i *SessionsInter) Create(session *models.Session) (*models.Session, error)
 	}
 
 	now := time.Now().UTC()
-	session.Created = &now
-
-	if session.Token == nil {
-		session.Token = utils.StrCpy(utils.GenToken(i.g.GetSessionTokenLength()))
-	}
 
-	if session.ValidTo == nil {
-		session.ValidTo = utils.TimeCpy(now.Add(i.g.GetSessionValidity()))
+	if err := i.prepare(session, now); err != nil {
+		return nil, err
 	}
 
 	err := i.r.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte("sessions"))
-
-		raw, _ := json.Marshal(session)
-
-		return b.Put([]byte(*session.Token), raw)
+		return i.put(tx, session, nil, now)
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
+	// A guest decision may have been cached for the token
+	i.c.InvalidateTokens(i.h.Hash(*session.Token))
+
 	return session, nil
 }
 
-func (i *SessionsInter) DeleteByToken(token string) (*models.Session, error) {
-	session, err := i.FindByToken(token)
-	if err != nil {
-		return nil, err
-	}
+// Refresh exchanges a refresh token for a new session and a new refresh token of the same family.
+// Both tokens are only returned here.
+// The previous session expires. Exchanging a refresh token twice revokes its whole family, as one of
+// the exchanges was made with a stolen token.
+func (i *SessionsInter) Refresh(token string) (*models.Session, error) {
+	now := time.Now().UTC()
+	session := &models.Session{}
+	expired := []string{}
+	found := false
 
-	session.ValidTo = utils.TimeCpy(time.Now().UTC())
+	err := i.r.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("refreshTokens"))
+		key := []byte(i.h.Hash(token))
 
-	err = i.r.Update(func(tx *bolt.Tx) error {
-		raw, _ := json.Marshal(session)
-		return tx.Bucket([]byte("sessions")).Put([]byte(token), raw)
+		raw := b.Get(key)
+		if raw == nil {
+			return nil
+		}
+
+		refresh := &models.RefreshToken{}
+		if err := json.Unmarshal(raw, refresh); err != nil {
+			return err
+		}
+
+		// The revocation must be committed, so the reuse is not reported as an error here
+		if refresh.Rotated != nil && refresh.Revoked == nil {
+			tokens, err := i.revokeFamily(tx, *refresh.Family, now)
+			expired = tokens
+			return err
+		}
+
+		if refresh.Rotated != nil || refresh.Revoked != nil || refresh.ValidTo.Before(now) {
+			return nil
+		}
+
+		raw = tx.Bucket([]byte("sessions")).Get([]byte(*refresh.SessionToken))
+		if raw == nil {
+			return nil
+		}
+
+		previous := &models.Session{}
+		if err := json.Unmarshal(raw, previous); err != nil {
+			return err
+		}
+
+		refresh.Rotated = &now
+
+		raw, _ = json.Marshal(refresh)
+
+		if err := b.Put(key, raw); err != nil {
+			return err
+		}
+
+		if err := i.expire(tx, *refresh.SessionToken, previous, now); err != nil {
+			return err
+		}
+
+		expired = append(expired, *refresh.SessionToken)
+
+		session.OwnerToken = previous.OwnerToken
+		session.Agent = previous.Agent
+		session.Policies = previous.Policies
+		session.Payload = previous.Payload
+		session.Attributes = previous.Attributes
+
+		if err := i.prepare(session, now); err != nil {
+			return err
+		}
+
+		found = true
+
+		return i.put(tx, session, refresh.Family, now)
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
+	i.c.InvalidateTokens(expired...)
+	i.forget(expired...)
+
+	if !found {
+		return nil, errs.Internal.NotFound
+	}
+
+	i.c.InvalidateTokens(i.h.Hash(*session.Token))
+
 	return session, nil
 }
 
-func (i *SessionsInter) DeleteByOwnerTokens(ownerTokens []string) ([]models.Session, error) {
-	sessions, err := i.Find()
-	if err != nil {
-		return nil, err
+// prepare sets the fields of a new session which are managed by the server.
+func (i *SessionsInter) prepare(session *models.Session, now time.Time) error {
+	session.Created = &now
+	session.TokenID = nil
+	session.LastActivity = nil
+	session.MaxValidTo = nil
+	session.RefreshToken = nil
+
+	if idle := i.g.GetSessionIdleTimeout(); idle > 0 {
+		// The requested validity becomes the absolute limit up to which the session is extended
+		maxValidTo := session.ValidTo
+		if maxValidTo == nil {
+			maxValidTo = utils.TimeCpy(now.Add(i.maxLifetime()))
+		}
+
+		session.MaxValidTo = maxValidTo
+		session.ValidTo = utils.TimeCpy(now.Add(idle))
+
+		if session.ValidTo.After(*maxValidTo) {
+			session.ValidTo = utils.TimeCpy(*maxValidTo)
+		}
 	}
 
-	deletedSessions := []models.Session{}
-	now := time.Now().UTC()
+	if session.ValidTo == nil {
+		session.ValidTo = utils.TimeCpy(now.Add(i.g.GetSessionValidity()))
+	}
 
-	err = i.r.Update(func(tx *bolt.Tx) error {
-		s := tx.Bucket([]byte("sessions"))
+	// The JWT tokens carry the session, so they are issued once it is complete
+	if i.g.GetTokenFormat() == models.TokenJWT {
+		id := utils.GenToken(i.g.GetSessionTokenLength())
 
-		for _, session := range sessions {
-			for _, ownerToken := range ownerTokens {
-				if session.OwnerToken == nil || *session.OwnerToken != ownerToken {
-					continue
-				}
+		token, err := i.s.Sign(id, session)
+		if err != nil {
+			return err
+		}
 
-				session.ValidTo = &now
+		session.TokenID = &id
+		session.Token = &token
+	}
 
-				raw, _ := json.Marshal(session)
+	if session.Token == nil {
+		session.Token = utils.StrCpy(utils.GenToken(i.g.GetSessionTokenLength()))
+	}
 
-				if err := s.Put([]byte(*session.Token), raw); err != nil {
-					return err
-				}
+	return nil
+}
 
-				deletedSessions = append(deletedSessions, session)
-			}
+// put stores a new session. When the refresh tokens are enabled, a refresh token of the given family is
+// issued with it. A new family is started if none is given.
+// Only the token hashes are stored, the tokens are set on the given session.
+func (i *SessionsInter) put(tx *bolt.Tx, session *models.Session, family *string, now time.Time) error {
+	key := i.h.Hash(*session.Token)
+	stored := *session
+	stored.Token = nil
+
+	if validity := i.g.GetRefreshTokenValidity(); validity > 0 {
+		if family == nil {
+			family = utils.StrCpy(utils.GenToken(i.g.GetSessionTokenLength()))
 		}
 
-		return nil
-	})
+		token := utils.GenToken(i.g.GetSessionTokenLength())
 
-	if err != nil {
-		return nil, err
+		refresh := &models.RefreshToken{
+			Family:       family,
+			SessionToken: &key,
+			Created:      &now,
+			ValidTo:      utils.TimeCpy(now.Add(validity)),
+		}
+
+		raw, _ := json.Marshal(refresh)
+
+		if err := tx.Bucket([]byte("refreshTokens")).Put([]byte(i.h.Hash(token)), raw); err != nil {
+			return err
+		}
+
+		if err := indexFamily(tx, *family, i.h.Hash(token)); err != nil {
+			return err
+		}
+
+		stored.RefreshToken = utils.StrCpy(i.h.Hash(token))
+		session.RefreshToken = &token
 	}
 
-	return deletedSessions, nil
+	raw, _ := json.Marshal(stored)
+
+	if err := tx.Bucket([]byte("sessions")).Put([]byte(key), raw); err != nil {
+		return err
+	}
+
+	return indexValidity(tx, key, session, nil)
 }
 
-func (i *SessionsInter) DeleteCascade(policy *models.Policy) error {
-	if policy == nil {
-		return errors.New("nil policy")
+// ExpiryKey returns the key of a session in the expiry index, sorted by validity then by session key.
+// The validities are bounded to the ones representable in nanoseconds since the epoch, so they never wrap.
+func ExpiryKey(validTo time.Time, key string) []byte {
+	switch {
+	case validTo.Before(time.Unix(0, 0)):
+		validTo = time.Unix(0, 0)
+	case validTo.After(models.MaxValidTo):
+		validTo = models.MaxValidTo
 	}
 
-	err := i.r.Update(func(tx *bolt.Tx) error {
-		c := tx.Bucket([]byte("sessions")).Cursor()
+	k := make([]byte, 8, 8+len(key))
+	binary.BigEndian.PutUint64(k, uint64(validTo.UnixNano()))
 
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			session := models.Session{}
-			if err := json.Unmarshal(v, &session); err != nil {
+	return append(k, key...)
+}
+
+// indexValidity moves the session key from its previous validity to the current one in the expiry index,
+// and in the index of its owner if any. The owner indexes are sorted by validity too, so the sessions of
+// an owner are paged like the others.
+func indexValidity(tx *bolt.Tx, key string, session *models.Session, previous *time.Time) error {
+	indexes := []*bolt.Bucket{tx.Bucket([]byte("sessionExpiries"))}
+
+	// A bucket name can't be blank
+	if session.OwnerToken != nil && *session.OwnerToken != "" {
+		b, err := tx.Bucket([]byte("sessionOwners")).CreateBucketIfNotExists([]byte(*session.OwnerToken))
+		if err != nil {
+			return err
+		}
+
+		indexes = append(indexes, b)
+	}
+
+	for _, b := range indexes {
+		if previous != nil {
+			if err := b.Delete(ExpiryKey(*previous, key)); err != nil {
 				return err
 			}
+		}
 
-			newPolicies := []string{}
+		if session.ValidTo == nil {
+			continue
+		}
 
-			for _, p := range session.Policies {
-				if *policy.Name == p {
-					continue
-				}
+		if err := b.Put(ExpiryKey(*session.ValidTo, key), []byte{}); err != nil {
+			return err
+		}
+	}
 
-				newPolicies = append(newPolicies, p)
-			}
+	return nil
+}
 
-			session.Policies = newPolicies
+// expiringKeys returns the keys of the sessions expiring from the given time and before the given limit,
+// if any, in key order. Only the expiry index is read.
+func expiringKeys(tx *bolt.Tx, from, until *time.Time) [][]byte {
+	keys := [][]byte{}
+	c := tx.Bucket([]byte("sessionExpiries")).Cursor()
 
-			raw, _ := json.Marshal(session)
+	k, _ := c.First()
+	if from != nil {
+		k, _ = c.Seek(ExpiryKey(*from, ""))
+	}
 
-			if err := c.Bucket().Put([]byte(*session.Token), raw); err != nil {
-				return err
+	for ; k != nil; k, _ = c.Next() {
+		if until != nil && bytes.Compare(k, ExpiryKey(*until, "")) >= 0 {
+			break
+		}
+
+		keys = append(keys, append([]byte{}, k[8:]...))
+	}
+
+	sort.Sort(byKey(keys))
+
+	return keys
+}
+
+type byKey [][]byte
+
+func (s byKey) Len() int           { return len(s) }
+func (s byKey) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byKey) Less(i, j int) bool { return bytes.Compare(s[i], s[j]) < 0 }
+
+// ownedSessions returns the live sessions of the owners by key, looked up from the owner index from the given time.
+func ownedSessions(tx *bolt.Tx, ownerTokens []string, now time.Time) (map[string]*models.Session, error) {
+	sessions := map[string]*models.Session{}
+	b := tx.Bucket([]byte("sessions"))
+
+	for _, ownerToken := range ownerTokens {
+		if ownerToken == "" {
+			continue
+		}
+
+		owned := tx.Bucket([]byte("sessionOwners")).Bucket([]byte(ownerToken))
+		if owned == nil {
+			continue
+		}
+
+		c := owned.Cursor()
+
+		for k, _ := c.Seek(ExpiryKey(now, "")); k != nil; k, _ = c.Next() {
+			raw := b.Get(k[8:])
+			if raw == nil {
+				continue
+			}
+
+			session := &models.Session{}
+			if err := json.Unmarshal(raw, session); err != nil {
+				return nil, err
+			}
+
+			if session.ValidTo.Before(now) {
+				continue
 			}
+
+			sessions[string(k[8:])] = session
 		}
+	}
+
+	return sessions, nil
+}
 
+// indexFamily adds the refresh token key to the index of its family.
+func indexFamily(tx *bolt.Tx, family, key string) error {
+	// A bucket name can't be blank
+	if family == "" {
 		return nil
-	})
+	}
 
+	b, err := tx.Bucket([]byte("refreshFamilies")).CreateBucketIfNotExists([]byte(family))
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return b.Put([]byte(key), []byte{})
+}
+
+// revokeFamily revokes the refresh tokens of the family and expires their sessions.
+// The tokens are looked up from the family index. It returns the token hashes of the expired sessions.
+func (i *SessionsInter) revokeFamily(tx *bolt.Tx, family string, now time.Time) ([]string, error) {
+	b := tx.Bucket([]byte("refreshTokens"))
+	members := map[string]*models.RefreshToken{}
+
+	if index := tx.Bucket([]byte("refreshFamilies")).Bucket([]byte(family)); index != nil {
+		c := index.Cursor()
+
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			raw := b.Get(k)
+			if raw == nil {
+				continue
+			}
+
+			refresh := &models.RefreshToken{}
+			if err := json.Unmarshal(raw, refresh); err != nil {
+				return nil, err
+			}
+
+			members[string(k)] = refresh
+		}
+	}
+
+	expired := []string{}
+
+	// The bucket can't be modified while iterated
+	for key, refresh := range members {
+		if refresh.Revoked == nil {
+			refresh.Revoked = &now
+		}
+
+		raw, _ := json.Marshal(refresh)
+
+		if err := b.Put([]byte(key), raw); err != nil {
+			return nil, err
+		}
+
+		raw = tx.Bucket([]byte("sessions")).Get([]byte(*refresh.SessionToken))
+		if raw == nil {
+			continue
+		}
+
+		session := &models.Session{}
+		if err := json.Unmarshal(raw, session); err != nil {
+			return nil, err
+		}
+
+		if err := i.expire(tx, *refresh.SessionToken, session, now); err != nil {
+			return nil, err
+		}
+
+		expired = append(expired, *refresh.SessionToken)
+	}
+
+	return expired, nil
+}
+
+// expire ends the validity of the stored session, if not already expired, and revokes its refresh token.
+// A JWT token is revoked until its expiry.
+func (i *SessionsInter) expire(tx *bolt.Tx, key string, session *models.Session, now time.Time) error {
+	if session.ValidTo == nil || session.ValidTo.After(now) {
+		if session.TokenID != nil && session.ValidTo != nil {
+			if err := i.revoke(tx, *session.TokenID, *session.ValidTo); err != nil {
+				return err
+			}
+		}
+
+		previous := session.ValidTo
+		session.ValidTo = &now
+
+		raw, _ := json.Marshal(session)
+
+		if err := tx.Bucket([]byte("sessions")).Put([]byte(key), raw); err != nil {
+			return err
+		}
+
+		if err := indexValidity(tx, key, session, previous); err != nil {
+			return err
+		}
+	}
+
+	if session.RefreshToken == nil {
+		return nil
+	}
+
+	b := tx.Bucket([]byte("refreshTokens"))
+
+	raw := b.Get([]byte(*session.RefreshToken))
+	if raw == nil {
+		return nil
+	}
+
+	refresh := &models.RefreshToken{}
+	if err := json.Unmarshal(raw, refresh); err != nil {
+		return err
+	}
+
+	// A rotated refresh token is kept as is, to detect its reuse
+	if refresh.Revoked != nil || refresh.Rotated != nil {
+		return nil
+	}
+
+	refresh.Revoked = &now
+
+	raw, _ = json.Marshal(refresh)
+
+	return b.Put([]byte(*session.RefreshToken), raw)
+}
+
+func (i *SessionsInter) DeleteByToken(token string) (*models.Session, error) {
+	key := i.h.Hash(token)
+	var session *models.Session
+
+	err := i.r.Update(func(tx *bolt.Tx) error {
+		raw := tx.Bucket([]byte("sessions")).Get([]byte(key))
+		if raw == nil {
+			return nil
+		}
+
+		stored := &models.Session{}
+		if err := json.Unmarshal(raw, stored); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+
+		if stored.ValidTo.Before(now) {
+			return nil
+		}
+
+		session = stored
+
+		return i.expire(tx, key, session, now)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil {
+		return nil, errs.Internal.NotFound
+	}
+
+	i.c.InvalidateTokens(key)
+	i.forget(key)
+
+	session.Token = &token
+	session.RefreshToken = nil
+
+	return session, nil
+}
+
+func (i *SessionsInter) DeleteByOwnerTokens(ownerTokens []string) ([]models.Session, error) {
+	deletedSessions := []models.Session{}
+	deletedKeys := []string{}
+	now := time.Now().UTC()
+
+	err := i.r.Update(func(tx *bolt.Tx) error {
+		sessions, err := ownedSessions(tx, ownerTokens, now)
+		if err != nil {
+			return err
+		}
+
+		for key, session := range sessions {
+			if err := i.expire(tx, key, session, now); err != nil {
+				return err
+			}
+
+			session.RefreshToken = nil
+			deletedSessions = append(deletedSessions, *session)
+			deletedKeys = append(deletedKeys, key)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	i.c.InvalidateTokens(deletedKeys...)
+	i.forget(deletedKeys...)
+
+	return deletedSessions, nil
+}
+
+// errJWTUpdate is returned when a session carried by a jwt token is updated, as its token can't change.
+var errJWTUpdate = errs.NewErrValidation("a session with a jwt token cannot be updated, it must be deleted instead")
+
+// UpdateByToken applies a partial update to a session.
+func (i *SessionsInter) UpdateByToken(token string, update *models.SessionUpdate) (*models.Session, error) {
+	if update == nil {
+		return nil, errors.New("nil update")
+	}
+
+	key := i.h.Hash(token)
+	var session *models.Session
+	var jwt bool
+
+	err := i.r.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("sessions"))
+
+		raw := b.Get([]byte(key))
+		if raw == nil {
+			return nil
+		}
+
+		stored := &models.Session{}
+		if err := json.Unmarshal(raw, stored); err != nil {
+			return err
+		}
+
+		if stored.ValidTo.Before(time.Now()) {
+			return nil
+		}
+
+		if jwt = stored.TokenID != nil; jwt {
+			return nil
+		}
+
+		session = stored
+		previous := session.ValidTo
+		i.apply(session, update)
+
+		raw, _ = json.Marshal(session)
+
+		if err := b.Put([]byte(key), raw); err != nil {
+			return err
+		}
+
+		return indexValidity(tx, key, session, previous)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if jwt {
+		return nil, errJWTUpdate
+	}
+
+	if session == nil {
+		return nil, errs.Internal.NotFound
+	}
+
+	i.c.InvalidateTokens(key)
+
+	session.Token = &token
+	session.RefreshToken = nil
+
+	return session, nil
+}
+
+// UpdateByOwnerTokens applies a partial update to the sessions of the owners.
+func (i *SessionsInter) UpdateByOwnerTokens(ownerTokens []string, update *models.SessionUpdate) ([]models.Session, error) {
+	if update == nil {
+		return nil, errors.New("nil update")
+	}
+
+	updatedSessions := []models.Session{}
+	updatedKeys := []string{}
+	now := time.Now().UTC()
+	var jwt bool
+
+	err := i.r.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("sessions"))
+
+		sessions, err := ownedSessions(tx, ownerTokens, now)
+		if err != nil {
+			return err
+		}
+
+		// Nothing is updated if one of the sessions can't be
+		for _, session := range sessions {
+			if jwt = session.TokenID != nil; jwt {
+				return nil
+			}
+		}
+
+		for key, session := range sessions {
+			previous := session.ValidTo
+			i.apply(session, update)
+
+			raw, _ := json.Marshal(session)
+
+			if err := b.Put([]byte(key), raw); err != nil {
+				return err
+			}
+
+			if err := indexValidity(tx, key, session, previous); err != nil {
+				return err
+			}
+
+			session.RefreshToken = nil
+			updatedSessions = append(updatedSessions, *session)
+			updatedKeys = append(updatedKeys, key)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if jwt {
+		return nil, errJWTUpdate
+	}
+
+	i.c.InvalidateTokens(updatedKeys...)
+
+	return updatedSessions, nil
+}
+
+// apply sets the fields of the update on the stored session.
+func (i *SessionsInter) apply(session *models.Session, update *models.SessionUpdate) {
+	if update.Policies != nil {
+		session.Policies = update.Policies
+	}
+
+	if update.Payload != nil {
+		session.Payload = update.Payload
+	}
+
+	if update.ValidTo == nil {
+		return
+	}
+
+	// A sliding session keeps expiring when idle, up to its new absolute limit
+	if session.MaxValidTo != nil {
+		session.MaxValidTo = utils.TimeCpy(*update.ValidTo)
+
+		if session.ValidTo.Before(*update.ValidTo) {
+			return
+		}
+	}
+
+	session.ValidTo = utils.TimeCpy(*update.ValidTo)
+}
+
+func (i *SessionsInter) DeleteCascade(policy *models.Policy) error {
+	if policy == nil {
+		return errors.New("nil policy")
+	}
+
+	err := i.r.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("sessions")).Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			session := models.Session{}
+			if err := json.Unmarshal(v, &session); err != nil {
+				return err
+			}
+
+			newPolicies := []string{}
+
+			for _, p := range session.Policies {
+				if *policy.Name == p {
+					continue
+				}
+
+				newPolicies = append(newPolicies, p)
+			}
+
+			session.Policies = newPolicies
+
+			raw, _ := json.Marshal(session)
+
+			if err := c.Bucket().Put(k, raw); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Touch records an activity of the session. It is only persisted by the next FlushActivity call,
+// so the authorization requests don't wait for a write transaction.
+func (i *SessionsInter) Touch(token string) {
+	now := time.Now().UTC()
+	key := i.h.Hash(token)
+
+	i.mu.Lock()
+	i.activity[key] = now
+	i.mu.Unlock()
+}
+
+// FlushActivity persists the recorded activities in a single transaction.
+// When the idle timeout is enabled, the validity of the active sessions is extended accordingly.
+func (i *SessionsInter) FlushActivity() error {
+	i.mu.Lock()
+	activity := i.activity
+	i.activity = map[string]time.Time{}
+	i.mu.Unlock()
+
+	if len(activity) == 0 {
+		return nil
+	}
+
+	extended := []string{}
+
+	err := i.r.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("sessions"))
+
+		for key, at := range activity {
+			raw := b.Get([]byte(key))
+			if raw == nil {
+				continue
+			}
+
+			session := &models.Session{}
+			if err := json.Unmarshal(raw, session); err != nil {
+				return err
+			}
+
+			// The sessions expired before their activity must not be brought back, even if the flush is late
+			if session.ValidTo.Before(at) {
+				continue
+			}
+
+			previous := session.ValidTo
+
+			if i.slide(session, at) {
+				extended = append(extended, key)
+
+				if err := indexValidity(tx, key, session, previous); err != nil {
+					return err
+				}
+			}
+
+			raw, _ = json.Marshal(session)
+
+			if err := b.Put([]byte(key), raw); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		i.restore(activity)
+		return err
+	}
+
+	// The cached decisions hold the previous validity
+	i.c.InvalidateTokens(extended...)
+
+	return nil
+}
+
+// slide records the activity of the session and extends its validity up to its absolute limit.
+// The validity is never shortened. It returns true if the validity was extended.
+func (i *SessionsInter) slide(session *models.Session, at time.Time) bool {
+	if session.LastActivity == nil || session.LastActivity.Before(at) {
+		session.LastActivity = utils.TimeCpy(at)
+	}
+
+	idle := i.g.GetSessionIdleTimeout()
+	if idle <= 0 {
+		return false
+	}
+
+	maxValidTo := session.MaxValidTo
+	if maxValidTo == nil {
+		// The session was created before the idle timeout was enabled
+		if session.Created == nil {
+			return false
+		}
+
+		maxValidTo = utils.TimeCpy(session.Created.Add(i.maxLifetime()))
+	}
+
+	validTo := at.Add(idle)
+	if validTo.After(*maxValidTo) {
+		validTo = *maxValidTo
+	}
+
+	if !validTo.After(*session.ValidTo) {
+		return false
+	}
+
+	session.ValidTo = &validTo
+
+	return true
+}
+
+// maxLifetime returns the absolute lifetime of the sessions, which defaults to the session validity.
+func (i *SessionsInter) maxLifetime() time.Duration {
+	if lifetime := i.g.GetSessionMaxLifetime(); lifetime > 0 {
+		return lifetime
+	}
+
+	return i.g.GetSessionValidity()
+}
+
+// restore records again the activities which failed to be flushed, unless more recent ones were recorded since.
+func (i *SessionsInter) restore(activity map[string]time.Time) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for key, at := range activity {
+		if last, ok := i.activity[key]; !ok || last.Before(at) {
+			i.activity[key] = at
+		}
+	}
+}
+
+// forget drops the recorded activity of the deleted sessions.
+func (i *SessionsInter) forget(keys ...string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, key := range keys {
+		delete(i.activity, key)
+	}
+}
+
+// LoadRevocations loads the revocation list of the JWT tokens which are not expired yet.
+func (i *SessionsInter) LoadRevocations() error {
+	revoked := map[string]time.Time{}
+	now := time.Now()
+
+	err := i.r.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("revokedTokens")).Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			expires := time.Time{}
+			if err := json.Unmarshal(v, &expires); err != nil {
+				return err
+			}
+
+			if expires.After(now) {
+				revoked[string(k)] = expires
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	i.rmu.Lock()
+	i.revoked = revoked
+	i.rmu.Unlock()
+
+	return nil
+}
+
+// revoke adds a JWT token to the revocation list until its expiry.
+// The token is revoked in memory even if the transaction fails, which only denies more.
+func (i *SessionsInter) revoke(tx *bolt.Tx, id string, expires time.Time) error {
+	raw, _ := json.Marshal(expires)
+
+	if err := tx.Bucket([]byte("revokedTokens")).Put([]byte(id), raw); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	i.rmu.Lock()
+	defer i.rmu.Unlock()
+
+	// The list only holds the tokens which would otherwise be valid
+	for id, expires := range i.revoked {
+		if !expires.After(now) {
+			delete(i.revoked, id)
+		}
+	}
+
+	i.revoked[id] = expires
+
+	return nil
+}
+
+func (i *SessionsInter) isRevoked(id string) bool {
+	i.rmu.RLock()
+	defer i.rmu.RUnlock()
+
+	_, ok := i.revoked[id]
+
+	return ok
 }
@@ -1,6 +1,7 @@
 package interactors
 
 import (
+	"bytes"
 	"testing"
 	"time"
 
@@ -32,12 +33,20 @@ func (r *sessionsInterSessionsRepo) View(t func(tx *bolt.Tx) error) error {
 	return nil
 }
 
+type sessionsInterDecisionCache struct {
+	invalidated []string
+}
+
+func (c *sessionsInterDecisionCache) InvalidateTokens(hashes ...string) {
+	c.invalidated = append(c.invalidated, hashes...)
+}
+
 // TestSessionsInterFind runs tests on the SessionsInter Find method.
 func TestSessionsInterFind(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
 	repo := &sessionsInterSessionsRepo{}
-	inter := NewSessionsInter(repo, nil)
+	inter := NewSessionsInter(repo, nil, &sessionsInterDecisionCache{}, NewTokenHasher(), NewTokenSigner(utils.NewFakeModelsGetter()))
 
 	// Success
 	result, err := inter.Find()
@@ -53,12 +62,95 @@ func TestSessionsInterFind(t *testing.T) {
 	a.Nil(result)
 }
 
+// TestSessionsInterFindByFilter runs tests on the SessionsInter FindByFilter method.
+func TestSessionsInterFindByFilter(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	repo := &sessionsInterSessionsRepo{}
+	inter := NewSessionsInter(repo, nil, &sessionsInterDecisionCache{}, NewTokenHasher(), NewTokenSigner(utils.NewFakeModelsGetter()))
+
+	// Success
+	result, err := inter.FindByFilter(&models.SessionFilter{OwnerToken: "owner1"})
+	r.NoError(err)
+	a.Len(result.Sessions, 0)
+	a.Empty(result.NextCursor)
+
+	// Invalid cursor
+	result, err = inter.FindByFilter(&models.SessionFilter{Cursor: "c0"})
+	r.Error(err)
+	a.IsType(errs.ErrValidation{}, err)
+	a.Nil(result)
+
+	repo.err = true
+
+	// Database error
+	result, err = inter.FindByFilter(&models.SessionFilter{})
+	r.Error(err)
+	a.IsType(errs.Internal.Database, err)
+	a.Nil(result)
+}
+
+// TestExpiryKey runs tests on the ExpiryKey function.
+func TestExpiryKey(t *testing.T) {
+	a := assert.New(t)
+	now := time.Now()
+
+	a.Equal("abc", string(ExpiryKey(now, "abc")[8:]))
+
+	// Sorted by validity first
+	a.Equal(-1, bytes.Compare(ExpiryKey(now, "b"), ExpiryKey(now.Add(time.Nanosecond), "a")))
+	a.Equal(-1, bytes.Compare(ExpiryKey(now, "a"), ExpiryKey(now, "b")))
+
+	// The key of a time without a session key comes before the sessions valid from this time
+	a.Equal(-1, bytes.Compare(ExpiryKey(now, ""), ExpiryKey(now, "a")))
+
+	// The validities out of the nanosecond range are bounded instead of wrapping
+	future := time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC)
+	past := time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+	a.Equal(-1, bytes.Compare(ExpiryKey(now, ""), ExpiryKey(future, "")))
+	a.Equal(ExpiryKey(models.MaxValidTo, "a"), ExpiryKey(future, "a"))
+	a.Equal(-1, bytes.Compare(ExpiryKey(past, ""), ExpiryKey(now, "")))
+	a.Equal(ExpiryKey(time.Unix(0, 0), "a"), ExpiryKey(past, "a"))
+}
+
+// TestMatchSession runs tests on the matchSession function.
+func TestMatchSession(t *testing.T) {
+	a := assert.New(t)
+	now := time.Now()
+	session := &models.Session{
+		Created:    utils.TimeCpy(now.Add(-time.Hour)),
+		ValidTo:    utils.TimeCpy(now.Add(time.Hour)),
+		OwnerToken: utils.StrCpy("owner1"),
+		Agent:      utils.StrCpy("Mozilla/5.0 Firefox/47.0"),
+		Policies:   []string{"guest", "admin"},
+	}
+
+	a.True(matchSession(&models.SessionFilter{}, session))
+	a.True(matchSession(&models.SessionFilter{OwnerToken: "owner1", Policy: "admin", Agent: "firefox"}, session))
+	a.False(matchSession(&models.SessionFilter{OwnerToken: "owner2"}, session))
+	a.False(matchSession(&models.SessionFilter{Policy: "contractor"}, session))
+	a.False(matchSession(&models.SessionFilter{Agent: "chrome"}, session))
+
+	// Time ranges: the lower bounds are included, the upper ones excluded
+	a.True(matchSession(&models.SessionFilter{CreatedSince: session.Created, CreatedUntil: utils.TimeCpy(now)}, session))
+	a.False(matchSession(&models.SessionFilter{CreatedUntil: session.Created}, session))
+	a.True(matchSession(&models.SessionFilter{ExpiresSince: session.ValidTo}, session))
+	a.False(matchSession(&models.SessionFilter{ExpiresUntil: session.ValidTo}, session))
+
+	session.Agent = nil
+	session.OwnerToken = nil
+
+	// Missing fields
+	a.False(matchSession(&models.SessionFilter{Agent: "firefox"}, session))
+	a.False(matchSession(&models.SessionFilter{OwnerToken: "owner1"}, session))
+}
+
 // TestSessionsInterFindByToken runs tests on the SessionsInter FindByToken method.
 func TestSessionsInterFindByToken(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
 	repo := &sessionsInterSessionsRepo{}
-	inter := NewSessionsInter(repo, nil)
+	inter := NewSessionsInter(repo, nil, &sessionsInterDecisionCache{}, NewTokenHasher(), NewTokenSigner(utils.NewFakeModelsGetter()))
 
 	// Not found
 	result, err := inter.FindByToken("")
@@ -83,7 +175,9 @@ func TestSessionsInterCreate(t *testing.T) {
 	getter := utils.NewFakeModelsGetter()
 	getter.SessionValidity = time.Hour
 	getter.SessionTokenLength = 32
-	inter := NewSessionsInter(repo, getter)
+	cache := &sessionsInterDecisionCache{}
+	hasher := NewTokenHasher()
+	inter := NewSessionsInter(repo, getter, cache, hasher, NewTokenSigner(utils.NewFakeModelsGetter()))
 
 	// Success
 	repo.err = false
@@ -92,6 +186,7 @@ func TestSessionsInterCreate(t *testing.T) {
 	a.NotNil(result)
 	a.Len(*result.Token, 32)
 	a.NotNil(result.ValidTo)
+	a.Equal([]string{hasher.Hash(*result.Token)}, cache.invalidated)
 
 	// Nil error
 	result, err = inter.Create(nil)
@@ -112,7 +207,7 @@ func TestSessionsInterDeleteByToken(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
 	repo := &sessionsInterSessionsRepo{}
-	inter := NewSessionsInter(repo, nil)
+	inter := NewSessionsInter(repo, nil, &sessionsInterDecisionCache{}, NewTokenHasher(), NewTokenSigner(utils.NewFakeModelsGetter()))
 
 	// Not found
 	result, err := inter.DeleteByToken("")
@@ -134,7 +229,7 @@ func TestSessionsInterDeleteByOwnerTokens(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
 	repo := &sessionsInterSessionsRepo{}
-	inter := NewSessionsInter(repo, nil)
+	inter := NewSessionsInter(repo, nil, &sessionsInterDecisionCache{}, NewTokenHasher(), NewTokenSigner(utils.NewFakeModelsGetter()))
 
 	// Do nothing
 	result, err := inter.DeleteByOwnerTokens([]string{""})
@@ -149,3 +244,258 @@ func TestSessionsInterDeleteByOwnerTokens(t *testing.T) {
 	a.IsType(errs.Internal.Database, err)
 	a.Nil(result)
 }
+
+// TestSessionsInterUpdateByToken runs tests on the SessionsInter UpdateByToken method.
+func TestSessionsInterUpdateByToken(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	repo := &sessionsInterSessionsRepo{}
+	inter := NewSessionsInter(repo, nil, &sessionsInterDecisionCache{}, NewTokenHasher(), NewTokenSigner(utils.NewFakeModelsGetter()))
+	update := &models.SessionUpdate{Policies: []string{"admin"}}
+
+	// Nil update
+	result, err := inter.UpdateByToken("", nil)
+	r.Error(err)
+	a.Nil(result)
+
+	// Not found
+	result, err = inter.UpdateByToken("", update)
+	r.Error(err)
+	a.IsType(errs.Internal.NotFound, err)
+	a.Nil(result)
+
+	repo.err = true
+
+	// Database error
+	result, err = inter.UpdateByToken("", update)
+	r.Error(err)
+	a.IsType(errs.Internal.Database, err)
+	a.Nil(result)
+}
+
+// TestSessionsInterUpdateByOwnerTokens runs tests on the SessionsInter UpdateByOwnerTokens method.
+func TestSessionsInterUpdateByOwnerTokens(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	repo := &sessionsInterSessionsRepo{}
+	inter := NewSessionsInter(repo, nil, &sessionsInterDecisionCache{}, NewTokenHasher(), NewTokenSigner(utils.NewFakeModelsGetter()))
+	update := &models.SessionUpdate{Policies: []string{"admin"}}
+
+	// Do nothing
+	result, err := inter.UpdateByOwnerTokens([]string{""}, update)
+	r.NoError(err)
+	a.Len(result, 0)
+
+	repo.err = true
+
+	// Database error
+	result, err = inter.UpdateByOwnerTokens([]string{""}, update)
+	r.Error(err)
+	a.IsType(errs.Internal.Database, err)
+	a.Nil(result)
+}
+
+// TestSessionsInterApply runs tests on the SessionsInter apply method.
+func TestSessionsInterApply(t *testing.T) {
+	a := assert.New(t)
+	inter := NewSessionsInter(&sessionsInterSessionsRepo{}, nil, &sessionsInterDecisionCache{}, NewTokenHasher(), NewTokenSigner(utils.NewFakeModelsGetter()))
+	now := time.Now().UTC()
+	session := &models.Session{
+		Policies: []string{"guest"},
+		Payload:  utils.StrCpy("foo"),
+		ValidTo:  utils.TimeCpy(now.Add(time.Hour)),
+	}
+
+	// Only the set fields are updated
+	inter.apply(session, &models.SessionUpdate{Policies: []string{"admin"}})
+	a.Equal([]string{"admin"}, session.Policies)
+	a.Equal("foo", *session.Payload)
+	a.Equal(now.Add(time.Hour), *session.ValidTo)
+
+	// The validity is extended or shortened
+	inter.apply(session, &models.SessionUpdate{Payload: utils.StrCpy("bar"), ValidTo: utils.TimeCpy(now.Add(2 * time.Hour))})
+	a.Equal("bar", *session.Payload)
+	a.Equal(now.Add(2*time.Hour), *session.ValidTo)
+
+	session.MaxValidTo = utils.TimeCpy(now.Add(3 * time.Hour))
+
+	// Sliding session: only the absolute limit is extended
+	inter.apply(session, &models.SessionUpdate{ValidTo: utils.TimeCpy(now.Add(4 * time.Hour))})
+	a.Equal(now.Add(4*time.Hour), *session.MaxValidTo)
+	a.Equal(now.Add(2*time.Hour), *session.ValidTo)
+
+	// Sliding session: the current validity is capped by the new absolute limit
+	inter.apply(session, &models.SessionUpdate{ValidTo: utils.TimeCpy(now.Add(time.Hour))})
+	a.Equal(now.Add(time.Hour), *session.MaxValidTo)
+	a.Equal(now.Add(time.Hour), *session.ValidTo)
+}
+
+// TestSessionsInterCreateSliding runs tests on the SessionsInter Create method with the idle timeout enabled.
+func TestSessionsInterCreateSliding(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	repo := &sessionsInterSessionsRepo{}
+	getter := utils.NewFakeModelsGetter()
+	getter.SessionValidity = 24 * time.Hour
+	getter.SessionIdleTimeout = time.Hour
+	inter := NewSessionsInter(repo, getter, &sessionsInterDecisionCache{}, NewTokenHasher(), NewTokenSigner(utils.NewFakeModelsGetter()))
+
+	// Success: the session expires after the idle timeout, up to the session validity
+	result, err := inter.Create(&models.Session{})
+	r.NoError(err)
+	a.Equal(result.Created.Add(time.Hour), *result.ValidTo)
+	a.Equal(result.Created.Add(24*time.Hour), *result.MaxValidTo)
+
+	getter.SessionMaxLifetime = 48 * time.Hour
+
+	// Success: the maximum lifetime overrides the session validity
+	result, err = inter.Create(&models.Session{})
+	r.NoError(err)
+	a.Equal(result.Created.Add(48*time.Hour), *result.MaxValidTo)
+
+	validTo := time.Now().UTC().Add(time.Minute)
+
+	// Success: the requested validity is the absolute limit
+	result, err = inter.Create(&models.Session{ValidTo: utils.TimeCpy(validTo)})
+	r.NoError(err)
+	a.Equal(validTo, *result.ValidTo)
+	a.Equal(validTo, *result.MaxValidTo)
+}
+
+// TestSessionsInterSlide runs tests on the SessionsInter slide method.
+func TestSessionsInterSlide(t *testing.T) {
+	a := assert.New(t)
+	getter := utils.NewFakeModelsGetter()
+	getter.SessionValidity = 24 * time.Hour
+	inter := NewSessionsInter(&sessionsInterSessionsRepo{}, getter, &sessionsInterDecisionCache{}, NewTokenHasher(), NewTokenSigner(utils.NewFakeModelsGetter()))
+	created := time.Now().UTC().Add(-time.Hour)
+	session := &models.Session{
+		Created:    utils.TimeCpy(created),
+		ValidTo:    utils.TimeCpy(created.Add(2 * time.Hour)),
+		MaxValidTo: utils.TimeCpy(created.Add(3 * time.Hour)),
+	}
+
+	// Not extended: the idle timeout is disabled, only the activity is recorded
+	a.False(inter.slide(session, created.Add(time.Hour)))
+	a.Equal(created.Add(time.Hour), *session.LastActivity)
+	a.Equal(created.Add(2*time.Hour), *session.ValidTo)
+
+	getter.SessionIdleTimeout = 30 * time.Minute
+
+	// Not extended: the validity is never shortened
+	a.False(inter.slide(session, created.Add(time.Hour)))
+	a.Equal(created.Add(2*time.Hour), *session.ValidTo)
+
+	// Extended
+	a.True(inter.slide(session, created.Add(2*time.Hour)))
+	a.Equal(created.Add(150*time.Minute), *session.ValidTo)
+
+	// Extended up to the absolute limit
+	a.True(inter.slide(session, created.Add(170*time.Minute)))
+	a.Equal(created.Add(3*time.Hour), *session.ValidTo)
+
+	// Not recorded: an older activity
+	a.False(inter.slide(session, created.Add(time.Minute)))
+	a.Equal(created.Add(170*time.Minute), *session.LastActivity)
+
+	session.MaxValidTo = nil
+	session.ValidTo = utils.TimeCpy(created.Add(2 * time.Hour))
+
+	// Extended: the absolute limit of a session created before the idle timeout was enabled
+	a.True(inter.slide(session, created.Add(1440*time.Minute)))
+	a.Equal(created.Add(24*time.Hour), *session.ValidTo)
+}
+
+// TestSessionsInterFlushActivity runs tests on the SessionsInter Touch and FlushActivity methods.
+func TestSessionsInterFlushActivity(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	repo := &sessionsInterSessionsRepo{}
+	hasher := NewTokenHasher()
+	inter := NewSessionsInter(repo, utils.NewFakeModelsGetter(), &sessionsInterDecisionCache{}, hasher, NewTokenSigner(utils.NewFakeModelsGetter()))
+
+	// Success: nothing to flush
+	err := inter.FlushActivity()
+	r.NoError(err)
+
+	inter.Touch("F00bAr")
+	inter.Touch("B4rF00")
+	repo.err = true
+
+	// Database error: the activities are kept for the next flush
+	err = inter.FlushActivity()
+	r.Error(err)
+	a.IsType(errs.Internal.Database, err)
+	a.Len(inter.activity, 2)
+
+	repo.err = false
+	inter.forget(hasher.Hash("B4rF00"))
+
+	// Success
+	err = inter.FlushActivity()
+	r.NoError(err)
+	a.Len(inter.activity, 0)
+}
+
+// TestSessionsInterRefresh runs tests on the SessionsInter Refresh method.
+func TestSessionsInterRefresh(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	repo := &sessionsInterSessionsRepo{}
+	inter := NewSessionsInter(repo, utils.NewFakeModelsGetter(), &sessionsInterDecisionCache{}, NewTokenHasher(), NewTokenSigner(utils.NewFakeModelsGetter()))
+
+	// Not found
+	result, err := inter.Refresh("")
+	r.Error(err)
+	a.IsType(errs.Internal.NotFound, err)
+	a.Nil(result)
+
+	repo.err = true
+
+	// Database error
+	result, err = inter.Refresh("")
+	r.Error(err)
+	a.IsType(errs.Internal.Database, err)
+	a.Nil(result)
+}
+
+// TestSessionsInterJWT runs tests on the SessionsInter methods in jwt mode.
+func TestSessionsInterJWT(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	repo := &sessionsInterSessionsRepo{}
+	getter := utils.NewFakeModelsGetter()
+	getter.SessionValidity = time.Hour
+	getter.SessionTokenLength = 32
+	getter.TokenFormat = models.TokenJWT
+	getter.JWTKeys = []models.SigningKey{{ID: "k1", Secret: []byte("s3cr3t")}}
+	inter := NewSessionsInter(repo, getter, &sessionsInterDecisionCache{}, NewTokenHasher(), NewTokenSigner(getter))
+
+	// Success: a signed token is issued, even if one is requested
+	session, err := inter.Create(&models.Session{Token: utils.StrCpy("F00bAr"), Policies: []string{"Foo"}})
+	r.NoError(err)
+	r.True(IsJWT(*session.Token))
+	r.NotNil(session.TokenID)
+
+	repo.err = true
+
+	// Success: the token is verified without any lookup
+	found, err := inter.FindByToken(*session.Token)
+	r.NoError(err)
+	a.Equal([]string{"Foo"}, found.Policies)
+
+	inter.revoked[*session.TokenID] = *session.ValidTo
+
+	// Not found: the token is revoked
+	found, err = inter.FindByToken(*session.Token)
+	r.Error(err)
+	a.IsType(errs.Internal.NotFound, err)
+	a.Nil(found)
+
+	repo.err = false
+	getter.JWTKeys = nil
+
+	// Error: no signing key
+	_, err = inter.Create(&models.Session{Policies: []string{"Foo"}})
+	r.Error(err)
+}
@@ -0,0 +1,36 @@
+package interactors
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/solher/zest"
+)
+
+func init() {
+	zest.Injector.Register(NewTokenHasher)
+}
+
+// TokenHasher computes the keyed hashes under which the tokens are stored, so a copy of the
+// databases doesn't allow to impersonate the users.
+type TokenHasher struct {
+	secret []byte
+}
+
+func NewTokenHasher() *TokenHasher {
+	return &TokenHasher{}
+}
+
+// SetSecret sets the HMAC key. It must be set at startup, before any token is hashed.
+func (h *TokenHasher) SetSecret(secret []byte) {
+	h.secret = secret
+}
+
+// Hash returns the hex encoded HMAC-SHA256 of the token.
+func (h *TokenHasher) Hash(token string) string {
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write([]byte(token))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,29 @@
+package interactors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTokenHasherHash runs tests on the TokenHasher Hash method.
+func TestTokenHasherHash(t *testing.T) {
+	a := assert.New(t)
+	hasher := NewTokenHasher()
+	hasher.SetSecret([]byte("s3cr3t"))
+
+	hash := hasher.Hash("F00bAr")
+	a.Len(hash, 64)
+	a.NotContains(hash, "F00bAr")
+
+	// Same token, same hash
+	a.Equal(hash, hasher.Hash("F00bAr"))
+
+	// Another token
+	a.NotEqual(hash, hasher.Hash("B4rF00"))
+
+	hasher.SetSecret([]byte("4n0th3r"))
+
+	// Another secret
+	a.NotEqual(hash, hasher.Hash("F00bAr"))
+}
@@ -0,0 +1,180 @@
+package interactors
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/solher/zest"
+)
+
+func init() {
+	zest.Injector.Register(NewTokenSigner)
+}
+
+type (
+	TokenSignerOptionsGetter interface {
+		GetJWTKeys() []models.SigningKey
+	}
+
+	// TokenSigner signs and verifies the JWT session tokens with HS256.
+	// The first key signs the new tokens, and all of them verify, so the keys can be rotated.
+	TokenSigner struct {
+		g TokenSignerOptionsGetter
+	}
+
+	tokenHeader struct {
+		Algorithm string `json:"alg"`
+		Type      string `json:"typ"`
+		KeyID     string `json:"kid"`
+	}
+
+	// tokenClaims is the session carried by a token.
+	tokenClaims struct {
+		ID         string                 `json:"jti"`
+		Subject    *string                `json:"sub,omitempty"` // The owner token
+		IssuedAt   int64                  `json:"iat"`
+		Expires    int64                  `json:"exp"`
+		Agent      *string                `json:"agent,omitempty"`
+		Policies   []string               `json:"policies"`
+		Payload    *string                `json:"payload,omitempty"`
+		Attributes map[string]interface{} `json:"attributes,omitempty"`
+	}
+)
+
+var (
+	errNoSigningKey   = errors.New("no JWT signing key")
+	errMalformedToken = errors.New("malformed token")
+	errUnknownKey     = errors.New("unknown signing key")
+	errBadSignature   = errors.New("invalid token signature")
+	errExpiredToken   = errors.New("expired token")
+)
+
+func NewTokenSigner(g TokenSignerOptionsGetter) *TokenSigner {
+	return &TokenSigner{g: g}
+}
+
+// Sign returns a token carrying the session, identified by the given ID.
+func (s *TokenSigner) Sign(id string, session *models.Session) (string, error) {
+	keys := s.g.GetJWTKeys()
+	if len(keys) == 0 {
+		return "", errNoSigningKey
+	}
+
+	header, _ := json.Marshal(tokenHeader{Algorithm: "HS256", Type: "JWT", KeyID: keys[0].ID})
+
+	claims, err := json.Marshal(tokenClaims{
+		ID:         id,
+		Subject:    session.OwnerToken,
+		IssuedAt:   session.Created.Unix(),
+		Expires:    session.ValidTo.Unix(),
+		Agent:      session.Agent,
+		Policies:   session.Policies,
+		Payload:    session.Payload,
+		Attributes: session.Attributes,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := encodeSegment(header) + "." + encodeSegment(claims)
+
+	return unsigned + "." + encodeSegment(sign(keys[0].Secret, unsigned)), nil
+}
+
+// Verify checks the signature and the expiry of the token, and returns the session it carries.
+func (s *TokenSigner) Verify(token string) (*models.Session, error) {
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 {
+		return nil, errMalformedToken
+	}
+
+	header := tokenHeader{}
+	if err := decodeSegment(segments[0], &header); err != nil {
+		return nil, err
+	}
+
+	// The algorithm is never taken from the token, except to reject the other ones
+	if header.Algorithm != "HS256" {
+		return nil, errMalformedToken
+	}
+
+	var key *models.SigningKey
+	for _, k := range s.g.GetJWTKeys() {
+		if k.ID == header.KeyID {
+			key = &k
+			break
+		}
+	}
+
+	if key == nil {
+		return nil, errUnknownKey
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(segments[2])
+	if err != nil {
+		return nil, errMalformedToken
+	}
+
+	if !hmac.Equal(signature, sign(key.Secret, segments[0]+"."+segments[1])) {
+		return nil, errBadSignature
+	}
+
+	claims := tokenClaims{}
+	if err := decodeSegment(segments[1], &claims); err != nil {
+		return nil, err
+	}
+
+	created := time.Unix(claims.IssuedAt, 0).UTC()
+	validTo := time.Unix(claims.Expires, 0).UTC()
+
+	if !validTo.After(time.Now()) {
+		return nil, errExpiredToken
+	}
+
+	return &models.Session{
+		Created:    &created,
+		ValidTo:    &validTo,
+		Token:      &token,
+		TokenID:    &claims.ID,
+		OwnerToken: claims.Subject,
+		Agent:      claims.Agent,
+		Policies:   claims.Policies,
+		Payload:    claims.Payload,
+		Attributes: claims.Attributes,
+	}, nil
+}
+
+// IsJWT returns true if the token has the shape of a JWT. The opaque tokens never contain dots.
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func sign(secret []byte, unsigned string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+
+	return mac.Sum(nil)
+}
+
+func encodeSegment(raw []byte) string {
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errMalformedToken
+	}
+
+	if err := json.Unmarshal(raw, v); err != nil {
+		return errMalformedToken
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package interactors
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/solher/auth-nginx-proxy-companion/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTokenSigner runs tests on the TokenSigner Sign and Verify methods.
+func TestTokenSigner(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	getter := utils.NewFakeModelsGetter()
+	signer := NewTokenSigner(getter)
+	now := time.Now().UTC()
+	session := &models.Session{
+		Created:    utils.TimeCpy(now),
+		ValidTo:    utils.TimeCpy(now.Add(time.Hour)),
+		OwnerToken: utils.StrCpy("owner1"),
+		Policies:   []string{"Foo", "Bar"},
+		Payload:    utils.StrCpy(`{"id": 1}`),
+		Attributes: map[string]interface{}{"tenant": "acme"},
+	}
+
+	// Error: no signing key
+	_, err := signer.Sign("1d", session)
+	r.Error(err)
+
+	getter.JWTKeys = []models.SigningKey{{ID: "k1", Secret: []byte("s3cr3t")}}
+
+	token, err := signer.Sign("1d", session)
+	r.NoError(err)
+	a.True(IsJWT(token))
+
+	// Success: the session is carried by the token
+	verified, err := signer.Verify(token)
+	r.NoError(err)
+	a.Equal(token, *verified.Token)
+	a.Equal("1d", *verified.TokenID)
+	a.Equal("owner1", *verified.OwnerToken)
+	a.Equal(session.Policies, verified.Policies)
+	a.Equal(*session.Payload, *verified.Payload)
+	a.Equal("acme", verified.Attributes["tenant"])
+	a.Equal(session.ValidTo.Unix(), verified.ValidTo.Unix())
+
+	getter.JWTKeys = []models.SigningKey{{ID: "k2", Secret: []byte("n3w")}, {ID: "k1", Secret: []byte("s3cr3t")}}
+
+	// Success: the previous key still verifies after a rotation
+	_, err = signer.Verify(token)
+	r.NoError(err)
+
+	rotated, err := signer.Sign("2d", session)
+	r.NoError(err)
+	getter.JWTKeys = getter.JWTKeys[:1]
+
+	// Error: the key was removed
+	_, err = signer.Verify(token)
+	r.Error(err)
+
+	// Success: signed with the new key
+	_, err = signer.Verify(rotated)
+	r.NoError(err)
+
+	segments := strings.Split(rotated, ".")
+
+	// Error: tampered claims
+	_, err = signer.Verify(segments[0] + "." + encodeSegment([]byte(`{"jti":"3d","exp":9999999999,"policies":["admin"]}`)) + "." + segments[2])
+	r.Error(err)
+
+	// Error: unsigned token
+	_, err = signer.Verify(encodeSegment([]byte(`{"alg":"none","kid":"k2"}`)) + "." + segments[1] + ".")
+	r.Error(err)
+
+	// Error: malformed token
+	_, err = signer.Verify("F00bAr")
+	r.Error(err)
+
+	session.ValidTo = utils.TimeCpy(now.Add(-time.Second))
+	expired, err := signer.Sign("4d", session)
+	r.NoError(err)
+
+	// Error: expired token
+	_, err = signer.Verify(expired)
+	r.Error(err)
+}
@@ -0,0 +1,256 @@
+package matchers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Condition is a compiled permission condition over the session attributes.
+//
+// A condition compares two operands with one of the operators:
+//   - '==' and '!=' compare two values ('tenant == "acme"')
+//   - 'in' checks that a value is an element of an array ('"admin" in roles', 'tenant in ["acme", "foo"]')
+//
+// An operand is either a JSON literal (string, number, boolean, null or array)
+// or an attribute name. Nested attributes are accessed with dots ('org.tenant').
+// A missing attribute evaluates as null.
+type Condition struct {
+	raw         string
+	operator    string
+	left, right operand
+}
+
+type operand struct {
+	attribute []string // The attribute path, nil for a literal
+	literal   interface{}
+}
+
+// CompileCondition parses a condition, returning an error if it is malformed.
+func CompileCondition(condition string) (*Condition, error) {
+	c := &Condition{raw: condition}
+	rest := strings.TrimSpace(condition)
+
+	left, rest, err := parseOperand(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	rest = strings.TrimSpace(rest)
+
+	switch {
+	case strings.HasPrefix(rest, "=="), strings.HasPrefix(rest, "!="):
+		c.operator, rest = rest[:2], rest[2:]
+	case strings.HasPrefix(rest, "in ") || strings.HasPrefix(rest, "in\"") || strings.HasPrefix(rest, "in["):
+		c.operator, rest = "in", rest[2:]
+	case rest == "":
+		return nil, errors.New("missing operator")
+	default:
+		return nil, fmt.Errorf("invalid operator in '%s'", rest)
+	}
+
+	right, rest, err := parseOperand(strings.TrimSpace(rest))
+	if err != nil {
+		return nil, err
+	}
+
+	if rest = strings.TrimSpace(rest); rest != "" {
+		return nil, fmt.Errorf("unexpected '%s'", rest)
+	}
+
+	if c.operator == "in" && right.attribute == nil {
+		if _, ok := right.literal.([]interface{}); !ok {
+			return nil, errors.New("the right operand of 'in' must be an array")
+		}
+	}
+
+	c.left, c.right = left, right
+
+	return c, nil
+}
+
+// String returns the raw condition.
+func (c *Condition) String() string {
+	return c.raw
+}
+
+// Match evaluates the condition over the given attributes, which can be nil.
+func (c *Condition) Match(attributes map[string]interface{}) bool {
+	left := c.left.value(attributes)
+	right := c.right.value(attributes)
+
+	switch c.operator {
+	case "==":
+		return equal(left, right)
+	case "!=":
+		return !equal(left, right)
+	}
+
+	elements, ok := right.([]interface{})
+	if !ok {
+		return false
+	}
+
+	for _, element := range elements {
+		if equal(left, element) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o operand) value(attributes map[string]interface{}) interface{} {
+	if o.attribute == nil {
+		return o.literal
+	}
+
+	return lookup(attributes, o.attribute)
+}
+
+// LookupAttribute returns the value of an attribute, nested ones being accessed with dots ('org.tenant').
+// A missing attribute returns nil.
+func LookupAttribute(attributes map[string]interface{}, name string) interface{} {
+	return lookup(attributes, strings.Split(name, "."))
+}
+
+func lookup(attributes map[string]interface{}, path []string) interface{} {
+	var value interface{} = attributes
+
+	for _, key := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		value = object[key]
+	}
+
+	return value
+}
+
+// parseOperand parses the operand starting the given string and returns the remaining string.
+func parseOperand(s string) (operand, string, error) {
+	switch {
+	case s == "":
+		return operand{}, "", errors.New("missing operand")
+	case s[0] == '"':
+		end := closingQuote(s)
+		if end < 0 {
+			return operand{}, "", errors.New("unterminated string")
+		}
+
+		var literal string
+		if err := json.Unmarshal([]byte(s[:end+1]), &literal); err != nil {
+			return operand{}, "", fmt.Errorf("invalid string %s", s[:end+1])
+		}
+
+		return operand{literal: literal}, s[end+1:], nil
+	case s[0] == '[':
+		end := closingBracket(s)
+		if end < 0 {
+			return operand{}, "", errors.New("unterminated array")
+		}
+
+		var literal []interface{}
+		if err := json.Unmarshal([]byte(s[:end+1]), &literal); err != nil {
+			return operand{}, "", fmt.Errorf("invalid array %s", s[:end+1])
+		}
+
+		return operand{literal: literal}, s[end+1:], nil
+	}
+
+	end := strings.IndexAny(s, " \t=!\"[")
+	if end < 0 {
+		end = len(s)
+	}
+
+	word := s[:end]
+
+	switch word {
+	case "":
+		return operand{}, "", fmt.Errorf("missing operand before '%s'", s)
+	case "true", "false", "null":
+		var literal interface{}
+		json.Unmarshal([]byte(word), &literal)
+		return operand{literal: literal}, s[end:], nil
+	}
+
+	if c := word[0]; c == '-' || (c >= '0' && c <= '9') {
+		var literal float64
+		if err := json.Unmarshal([]byte(word), &literal); err != nil {
+			return operand{}, "", fmt.Errorf("invalid number '%s'", word)
+		}
+
+		return operand{literal: literal}, s[end:], nil
+	}
+
+	attribute := strings.Split(word, ".")
+	for _, key := range attribute {
+		if !isIdentifier(key) {
+			return operand{}, "", fmt.Errorf("invalid attribute name '%s'", word)
+		}
+	}
+
+	return operand{attribute: attribute}, s[end:], nil
+}
+
+// closingQuote returns the index of the quote ending the string starting s, or -1.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+
+	return -1
+}
+
+// closingBracket returns the index of the bracket ending the array starting s, or -1.
+func closingBracket(s string) int {
+	depth := 0
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			end := closingQuote(s[i:])
+			if end < 0 {
+				return -1
+			}
+			i += end
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// equal compares two JSON values. The numbers are compared as float64, like they are decoded from JSON.
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	}
+
+	return value
+}
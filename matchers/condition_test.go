@@ -0,0 +1,92 @@
+package matchers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCompileCondition runs tests on the CompileCondition function.
+func TestCompileCondition(t *testing.T) {
+	r := require.New(t)
+
+	valid := []string{
+		`tenant == "acme"`,
+		`"admin" in roles`,
+		`tenant in ["acme", "foo"]`,
+		`org.tenant != null`,
+		`level == 3`,
+		`verified==true`,
+		`"a \"quoted\" in" == name`,
+	}
+	for _, condition := range valid {
+		_, err := CompileCondition(condition)
+		r.NoError(err, condition)
+	}
+
+	invalid := []string{
+		``,
+		`tenant`,
+		`tenant = "acme"`,
+		`tenant == `,
+		`tenant == "acme`,
+		`tenant in "acme"`,
+		`tenant in [1, 2`,
+		`ten-ant == "acme"`,
+		`tenant == "acme" == "foo"`,
+		`org..tenant == 1`,
+		`level == 3x`,
+	}
+	for _, condition := range invalid {
+		_, err := CompileCondition(condition)
+		r.Error(err, condition)
+	}
+}
+
+// TestConditionMatch runs tests on the Condition Match method.
+func TestConditionMatch(t *testing.T) {
+	a := assert.New(t)
+
+	attributes := map[string]interface{}{
+		"tenant":   "acme",
+		"roles":    []interface{}{"admin", "dev"},
+		"level":    float64(3),
+		"verified": true,
+		"org":      map[string]interface{}{"tenant": "acme"},
+	}
+
+	cases := []struct {
+		condition string
+		match     bool
+	}{
+		{`tenant == "acme"`, true},
+		{`tenant == "foo"`, false},
+		{`tenant != "foo"`, true},
+		{`"admin" in roles`, true},
+		{`"ops" in roles`, false},
+		{`tenant in ["acme", "foo"]`, true},
+		{`tenant in roles`, false},
+		{`level == 3`, true},
+		{`level in [1, 2, 3]`, true},
+		{`verified == true`, true},
+		{`org.tenant == tenant`, true},
+		{`org.name == null`, true},
+		{`missing == "acme"`, false},
+		{`"admin" in missing`, false},
+		{`tenant.name == null`, true},
+	}
+
+	for _, c := range cases {
+		condition, err := CompileCondition(c.condition)
+		if a.NoError(err, c.condition) {
+			a.Equal(c.match, condition.Match(attributes), c.condition)
+		}
+	}
+
+	// A guest has no attributes
+	condition, _ := CompileCondition(`tenant == "acme"`)
+	a.False(condition.Match(nil))
+	condition, _ = CompileCondition(`tenant != "acme"`)
+	a.True(condition.Match(nil))
+}
@@ -0,0 +1,67 @@
+package matchers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// A host name pattern is either an exact host name ('api.example.com')
+// or a wildcard one, where a leading '*' label matches exactly one label ('*.preview.example.com').
+//
+// Host names are matched case insensitively and regardless of the request port.
+// An exact host name is always more specific than a wildcard one.
+
+// CheckHost returns an error if a host name pattern is malformed.
+func CheckHost(pattern string) error {
+	if pattern == "" {
+		return errors.New("empty host name")
+	}
+
+	if strings.ContainsAny(pattern, ":/ ") {
+		return errors.New("a host name cannot contain a scheme, a port, a path or spaces")
+	}
+
+	labels := strings.Split(strings.TrimSuffix(pattern, "."), ".")
+
+	for idx, label := range labels {
+		switch {
+		case label == "":
+			return errors.New("empty label")
+		case label == "*" && idx != 0:
+			return errors.New("'*' must be the first label")
+		case label == "*" && len(labels) < 3:
+			return fmt.Errorf("'%s' is too broad, a wildcard must be followed by at least two labels", pattern)
+		case label != "*" && strings.Contains(label, "*"):
+			return fmt.Errorf("'*' must be a whole label in '%s'", label)
+		}
+	}
+
+	return nil
+}
+
+// NormalizeHost lower-cases a host name and strips its port and trailing dot.
+func NormalizeHost(host string) string {
+	switch {
+	case strings.HasPrefix(host, "["):
+		// IPv6 literal, with or without a port
+		if end := strings.Index(host, "]"); end != -1 {
+			host = host[:end+1]
+		}
+	case strings.Count(host, ":") == 1:
+		host = host[:strings.Index(host, ":")]
+	}
+
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// WildcardHost returns the wildcard pattern matching a normalized host name,
+// or an empty string if the host name has no parent domain.
+func WildcardHost(host string) string {
+	dot := strings.Index(host, ".")
+	if dot == -1 || strings.HasPrefix(host, "[") {
+		return ""
+	}
+
+	return "*" + host[dot:]
+}
@@ -0,0 +1,44 @@
+package matchers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCheckHost runs tests on the CheckHost function.
+func TestCheckHost(t *testing.T) {
+	r := require.New(t)
+
+	valid := []string{"localhost", "foo.bar.com", "Foo.Bar.com.", "*.preview.example.com", "*.example.com"}
+	for _, pattern := range valid {
+		r.NoError(CheckHost(pattern), pattern)
+	}
+
+	invalid := []string{"", "foo.com:8080", "http://foo.com", "foo..com", "foo.*.com", "*.com", "*", "a*.foo.com", "foo bar.com"}
+	for _, pattern := range invalid {
+		r.Error(CheckHost(pattern), pattern)
+	}
+}
+
+// TestNormalizeHost runs tests on the NormalizeHost function.
+func TestNormalizeHost(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal("foo.bar.com", NormalizeHost("foo.bar.com"))
+	a.Equal("foo.bar.com", NormalizeHost("Foo.BAR.com:8080"))
+	a.Equal("foo.bar.com", NormalizeHost("foo.bar.com."))
+	a.Equal("[::1]", NormalizeHost("[::1]:3000"))
+	a.Equal("[::1]", NormalizeHost("[::1]"))
+}
+
+// TestWildcardHost runs tests on the WildcardHost function.
+func TestWildcardHost(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal("*.preview.example.com", WildcardHost("pr-1.preview.example.com"))
+	a.Equal("*.com", WildcardHost("example.com"))
+	a.Equal("", WildcardHost("localhost"))
+	a.Equal("", WildcardHost("[::1]"))
+}
@@ -0,0 +1,44 @@
+package matchers
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseNetworks runs tests on the ParseNetworks function.
+func TestParseNetworks(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	networks, err := ParseNetworks([]string{"10.0.0.0/8", " 192.168.1.10 ", "fd00::/8", "::1"})
+	r.NoError(err)
+	r.Len(networks, 4)
+	a.Equal("192.168.1.10/32", networks[1].String())
+	a.Equal("::1/128", networks[3].String())
+
+	for _, cidr := range []string{"10.0.0.0/33", "10.0.0", "foo", ""} {
+		_, err := ParseNetworks([]string{cidr})
+		a.Error(err, cidr)
+	}
+}
+
+// TestContainsIP runs tests on the ContainsIP function.
+func TestContainsIP(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	networks, err := ParseNetworks([]string{"10.0.0.0/8", "192.168.1.10", "fd00::/8"})
+	r.NoError(err)
+
+	a.True(ContainsIP(networks, net.ParseIP("10.1.2.3")))
+	a.True(ContainsIP(networks, net.ParseIP("::ffff:10.1.2.3")))
+	a.True(ContainsIP(networks, net.ParseIP("192.168.1.10")))
+	a.True(ContainsIP(networks, net.ParseIP("fd12::1")))
+	a.False(ContainsIP(networks, net.ParseIP("192.168.1.11")))
+	a.False(ContainsIP(networks, net.ParseIP("fe80::1")))
+	a.False(ContainsIP(networks, nil))
+	a.False(ContainsIP(nil, net.ParseIP("10.1.2.3")))
+}
@@ -0,0 +1,352 @@
+package matchers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type segmentKind int
+
+const (
+	// A segment which must be equal to the request one. Ex: 'users'
+	literalSegment segmentKind = iota
+	// A segment with wildcards in it, matching a single request segment. Ex: '*.js'
+	globSegment
+	// A named placeholder, matching any non empty request segment. Ex: '{id}'
+	namedSegment
+	// A wildcard, matching any non empty request segment. Ex: '*' in '/users/*/profile'
+	singleSegment
+	// A recursive wildcard, matching zero or more request segments. Ex: '**'
+	recursiveSegment
+	// A trailing wildcard, matching one or more request segments. Ex: '*' in '/users/*'
+	tailSegment
+	// A session variable, matching the segment equal to its value. Ex: '${ownerToken}'
+	variableSegment
+)
+
+type segment struct {
+	kind  segmentKind
+	value string
+}
+
+// Path is a compiled permission path pattern.
+//
+// The pattern language is segment based:
+//   - 'foo' matches the 'foo' segment only
+//   - '*' matches exactly one segment ('/users/*/profile')
+//   - '**' matches zero or more segments ('/static/**/*.js')
+//   - '{id}' matches exactly one segment and names it ('/users/{id}')
+//   - '*.js', 'img-*' match one segment using wildcards inside it
+//   - '${ownerToken}', '${attributes.tenant}' match the segment equal to the session variable value
+//
+// For backward compatibility, a '*' ending a pattern matches the whole subtree:
+// '/foo/*' matches '/foo/bar' and '/foo/bar/baz' but not '/foo'.
+//
+// A session variable without value never matches.
+type Path struct {
+	raw         string
+	segments    []segment
+	specificity Specificity
+}
+
+// Specificity is used to rank the patterns matching a same request path.
+type Specificity struct {
+	// The number of literal segments.
+	Literals int `json:"literals"`
+	// The number of segments with wildcards inside them.
+	Globs int `json:"globs"`
+	// The number of single segment wildcards and named placeholders.
+	Singles int `json:"singles"`
+	// Indicates if the pattern matches a variable number of segments.
+	Recursive bool `json:"recursive"`
+	// Indicates if the variable number of segments can't be zero, as with a trailing '*' unlike '**'.
+	Tail bool `json:"tail"`
+}
+
+// CompilePath parses a path pattern, returning an error if it is malformed.
+func CompilePath(pattern string) (*Path, error) {
+	p := &Path{raw: pattern}
+	names := map[string]bool{}
+	parts := SplitPath(pattern)
+
+	for idx, part := range parts {
+		s := segment{value: part}
+
+		switch {
+		case strings.HasPrefix(part, "${") && strings.HasSuffix(part, "}"):
+			name := part[2 : len(part)-1]
+			if err := checkVariable(name); err != nil {
+				return nil, err
+			}
+			s.kind = variableSegment
+			s.value = name
+			p.specificity.Literals++
+		case strings.Contains(part, "$"):
+			return nil, fmt.Errorf("a session variable must be a whole segment in '%s'", part)
+		case part == "**":
+			s.kind = recursiveSegment
+			p.specificity.Recursive = true
+		case part == "*" && idx == len(parts)-1:
+			s.kind = tailSegment
+			p.specificity.Recursive = true
+			p.specificity.Tail = true
+		case part == "*":
+			s.kind = singleSegment
+			p.specificity.Singles++
+		case strings.HasPrefix(part, "{") || strings.HasSuffix(part, "}"):
+			name := strings.TrimSuffix(strings.TrimPrefix(part, "{"), "}")
+			if len(name) != len(part)-2 || !isIdentifier(name) {
+				return nil, fmt.Errorf("invalid placeholder '%s'", part)
+			}
+			if names[name] {
+				return nil, fmt.Errorf("duplicated placeholder '%s'", part)
+			}
+			names[name] = true
+			s.kind = namedSegment
+			s.value = name
+			p.specificity.Singles++
+		case strings.Contains(part, "**"):
+			return nil, fmt.Errorf("'**' must be a whole segment in '%s'", part)
+		case strings.Contains(part, "*"):
+			s.kind = globSegment
+			p.specificity.Globs++
+		case strings.ContainsAny(part, "{}"):
+			return nil, fmt.Errorf("invalid placeholder in '%s'", part)
+		case part == "" && len(parts) > 1:
+			return nil, errors.New("empty segment")
+		default:
+			s.kind = literalSegment
+			p.specificity.Literals++
+		}
+
+		p.segments = append(p.segments, s)
+	}
+
+	return p, nil
+}
+
+// SplitPath splits a path in segments, ignoring the leading and trailing slashes.
+// The root path gives a single empty segment.
+func SplitPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(strings.TrimSuffix(path, "/"), "/"), "/")
+}
+
+// String returns the raw pattern.
+func (p *Path) String() string {
+	return p.raw
+}
+
+// Specificity returns the specificity of the pattern.
+func (p *Path) Specificity() Specificity {
+	return p.specificity
+}
+
+// LiteralPrefix returns the leading literal segments of the pattern.
+// Every request path matching the pattern starts with them.
+func (p *Path) LiteralPrefix() []string {
+	prefix := []string{}
+
+	for _, s := range p.segments {
+		if s.kind != literalSegment {
+			break
+		}
+
+		prefix = append(prefix, s.value)
+	}
+
+	return prefix
+}
+
+// Variables resolves the session variables referenced by the patterns.
+type Variables interface {
+	Lookup(name string) (string, bool)
+}
+
+// Match indicates if the given splitted request path matches the pattern.
+// The session variables are resolved with vars, which can be nil.
+func (p *Path) Match(reqPath []string, vars Variables) bool {
+	return matchSegments(p.segments, reqPath, vars)
+}
+
+func matchSegments(segments []segment, reqPath []string, vars Variables) bool {
+	for len(segments) > 0 {
+		s := segments[0]
+
+		switch s.kind {
+		case recursiveSegment:
+			for n := 0; n <= len(reqPath); n++ {
+				if matchSegments(segments[1:], reqPath[n:], vars) {
+					return true
+				}
+			}
+			return false
+		case tailSegment:
+			return len(reqPath) > 0
+		}
+
+		if len(reqPath) == 0 || !s.match(reqPath[0], vars) {
+			return false
+		}
+
+		segments, reqPath = segments[1:], reqPath[1:]
+	}
+
+	return len(reqPath) == 0
+}
+
+func (s segment) match(reqSegment string, vars Variables) bool {
+	switch s.kind {
+	case literalSegment:
+		return s.value == reqSegment
+	case variableSegment:
+		if vars == nil {
+			return false
+		}
+		value, ok := vars.Lookup(s.value)
+		return ok && value != "" && value == reqSegment
+	case globSegment:
+		return reqSegment != "" && matchGlob(s.value, reqSegment)
+	default:
+		return reqSegment != ""
+	}
+}
+
+// Compare returns a positive number if s is more specific than o, a negative one if it is less specific
+// and 0 if both are equally specific.
+//
+// Literal segments are the most specific, followed by segments with wildcards inside them,
+// single segment wildcards and placeholders. A recursive pattern is less specific than a fixed length one,
+// and '**' is less specific than a trailing '*', which matches at least one segment.
+func (s Specificity) Compare(o Specificity) int {
+	switch {
+	case s.Literals != o.Literals:
+		return s.Literals - o.Literals
+	case s.Globs != o.Globs:
+		return s.Globs - o.Globs
+	case s.Singles != o.Singles:
+		return s.Singles - o.Singles
+	case s.Recursive != o.Recursive:
+		if o.Recursive {
+			return 1
+		}
+		return -1
+	case s.Tail != o.Tail:
+		if s.Tail {
+			return 1
+		}
+		return -1
+	}
+
+	return 0
+}
+
+// matchGlob matches a string against a pattern in which '*' matches any sequence of characters.
+func matchGlob(pattern, str string) bool {
+	star, next := -1, 0
+	p, s := 0, 0
+
+	for s < len(str) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, s
+			p++
+		case p < len(pattern) && pattern[p] == str[s]:
+			p++
+			s++
+		case star != -1:
+			next++
+			p, s = star+1, next
+		default:
+			return false
+		}
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+
+	return p == len(pattern)
+}
+
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkVariable returns an error if the name is not a known session variable.
+// The known variables are 'ownerToken' and the session attributes ('attributes.tenant').
+func checkVariable(name string) error {
+	if name == "ownerToken" {
+		return nil
+	}
+
+	if strings.HasPrefix(name, "attributes.") {
+		for _, key := range strings.Split(strings.TrimPrefix(name, "attributes."), ".") {
+			if !isIdentifier(key) {
+				return fmt.Errorf("invalid attribute name in '${%s}'", name)
+			}
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unknown session variable '${%s}'", name)
+}
+
+// CheckPathPrefix returns an error if a resource path prefix is malformed.
+// A prefix is a literal path: it must start with a slash and cannot contain wildcards or placeholders.
+func CheckPathPrefix(prefix string) error {
+	if !strings.HasPrefix(prefix, "/") {
+		return errors.New("a path prefix must start with a slash")
+	}
+
+	if strings.ContainsAny(prefix, "*{}?#") {
+		return errors.New("a path prefix cannot contain wildcards, placeholders, queries or fragments")
+	}
+
+	for _, part := range PrefixSegments(prefix) {
+		if part == "" {
+			return errors.New("empty segment")
+		}
+	}
+
+	return nil
+}
+
+// PrefixSegments splits a path prefix in segments. The root prefix has no segment.
+func PrefixSegments(prefix string) []string {
+	if prefix == "" || prefix == "/" {
+		return nil
+	}
+
+	return SplitPath(prefix)
+}
+
+// HasPrefix indicates if the given splitted request path starts with the prefix segments.
+// Whole segments are compared: '/app' is a prefix of '/app/foo' but not of '/application'.
+func HasPrefix(reqPath, prefix []string) bool {
+	if len(prefix) > len(reqPath) {
+		return false
+	}
+
+	for idx, segment := range prefix {
+		if reqPath[idx] != segment {
+			return false
+		}
+	}
+
+	return true
+}
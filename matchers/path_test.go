@@ -0,0 +1,191 @@
+package matchers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCompilePath runs tests on the CompilePath function.
+func TestCompilePath(t *testing.T) {
+	r := require.New(t)
+
+	valid := []string{"*", "/", "/foo", "/foo/*", "/foo/*/bar", "/**", "/static/**/*.js", "/users/{id}", "/a/{b}/{c_2}", "img-*"}
+	for _, pattern := range valid {
+		_, err := CompilePath(pattern)
+		r.NoError(err, pattern)
+	}
+
+	invalid := []string{"/foo/a**", "/***", "/{}", "/{id", "/id}", "/{1d}", "/a{id}", "/{id}/{id}", "/foo//bar"}
+	for _, pattern := range invalid {
+		_, err := CompilePath(pattern)
+		r.Error(err, pattern)
+	}
+}
+
+// TestPathMatch runs tests on the Path Match method.
+func TestPathMatch(t *testing.T) {
+	a := assert.New(t)
+
+	cases := []struct {
+		pattern, path string
+		match         bool
+	}{
+		{"*", "", true},
+		{"*", "/foo/bar", true},
+		{"/", "", true},
+		{"/", "/foo", false},
+		{"/foo", "/foo/", true},
+		{"/foo", "/foo/bar", false},
+		{"/foo/*", "/foo", false},
+		{"/foo/*", "/foo/bar", true},
+		{"/foo/*", "/foo/bar/baz", true},
+		{"/users/*/profile", "/users/42/profile", true},
+		{"/users/*/profile", "/users/42/settings", false},
+		{"/users/*/profile", "/users/42/profile/edit", false},
+		{"/users/{id}", "/users/42", true},
+		{"/users/{id}", "/users/42/profile", false},
+		{"/users/{id}", "/users", false},
+		{"/static/**", "/static", true},
+		{"/static/**", "/static/a/b", true},
+		{"/static/**/*.js", "/static/app.js", true},
+		{"/static/**/*.js", "/static/js/vendor/app.js", true},
+		{"/static/**/*.js", "/static/js/app.css", false},
+		{"/img-*/logo", "/img-2x/logo", true},
+		{"/img-*/logo", "/img/logo", false},
+		{"/a*b*c", "/abc", true},
+		{"/a*b*c", "/axxbyyc", true},
+		{"/a*b*c", "/axxbyy", false},
+	}
+
+	for _, c := range cases {
+		pattern, err := CompilePath(c.pattern)
+		if !a.NoError(err) {
+			continue
+		}
+
+		a.Equal(c.match, pattern.Match(SplitPath(c.path), nil), c.pattern+" "+c.path)
+	}
+}
+
+type fakeVariables map[string]string
+
+func (v fakeVariables) Lookup(name string) (string, bool) {
+	value, ok := v[name]
+	return value, ok
+}
+
+// TestPathVariables runs tests on the session variables in the path patterns.
+func TestPathVariables(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	valid := []string{"/users/${ownerToken}/*", "/${attributes.tenant}", "/orgs/${attributes.org.id}/**"}
+	for _, pattern := range valid {
+		_, err := CompilePath(pattern)
+		r.NoError(err, pattern)
+	}
+
+	invalid := []string{"/users/${token}", "/users/${attributes}", "/users/${attributes.}", "/users/u-${ownerToken}", "/$"}
+	for _, pattern := range invalid {
+		_, err := CompilePath(pattern)
+		r.Error(err, pattern)
+	}
+
+	vars := fakeVariables{"ownerToken": "owner1", "attributes.tenant": ""}
+
+	cases := []struct {
+		pattern, path string
+		match         bool
+	}{
+		{"/users/${ownerToken}/*", "/users/owner1/profile", true},
+		{"/users/${ownerToken}/*", "/users/owner2/profile", false},
+		{"/users/${ownerToken}", "/users/owner1", true},
+		{"/${attributes.tenant}/*", "//foo", false}, // An empty value never matches
+		{"/${attributes.org}/*", "/acme/foo", false},
+	}
+
+	for _, c := range cases {
+		pattern, err := CompilePath(c.pattern)
+		if a.NoError(err) {
+			a.Equal(c.match, pattern.Match(SplitPath(c.path), vars), c.pattern+" "+c.path)
+		}
+	}
+
+	// No variables can be resolved without session
+	pattern, _ := CompilePath("/users/${ownerToken}")
+	a.False(pattern.Match(SplitPath("/users/owner1"), nil))
+
+	// A variable is as specific as a literal segment, and ends the literal prefix
+	a.Equal(Specificity{Literals: 2}, pattern.Specificity())
+	a.Equal([]string{"users"}, pattern.LiteralPrefix())
+}
+
+// TestSpecificityCompare runs tests on the Specificity Compare method.
+func TestSpecificityCompare(t *testing.T) {
+	a := assert.New(t)
+
+	ordered := []string{"*", "/foo/**", "/foo/*", "/foo/{id}", "/foo/*.js", "/foo/bar"}
+
+	for i := 1; i < len(ordered); i++ {
+		less, _ := CompilePath(ordered[i-1])
+		more, _ := CompilePath(ordered[i])
+
+		a.True(more.Specificity().Compare(less.Specificity()) > 0, ordered[i])
+		a.True(less.Specificity().Compare(more.Specificity()) < 0, ordered[i])
+	}
+
+	// A trailing '*' needs at least one segment, so it is more specific than '**'
+	p1, _ := CompilePath("/foo/*")
+	p2, _ := CompilePath("/foo/**")
+	a.True(p1.Specificity().Compare(p2.Specificity()) > 0)
+
+	p3, _ := CompilePath("/bar/*")
+	a.Equal(0, p1.Specificity().Compare(p3.Specificity()))
+}
+
+// TestPathLiteralPrefix runs tests on the Path LiteralPrefix method.
+func TestPathLiteralPrefix(t *testing.T) {
+	a := assert.New(t)
+
+	cases := map[string][]string{
+		"*":               {},
+		"/":               {""},
+		"/foo/bar":        {"foo", "bar"},
+		"/foo/*":          {"foo"},
+		"/foo/{id}/bar":   {"foo"},
+		"/static/**/*.js": {"static"},
+		"/img-*/logo":     {},
+	}
+
+	for pattern, prefix := range cases {
+		p, err := CompilePath(pattern)
+		if a.NoError(err) {
+			a.Equal(prefix, p.LiteralPrefix(), pattern)
+		}
+	}
+}
+
+// TestPathPrefix runs tests on the path prefix functions.
+func TestPathPrefix(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	for _, prefix := range []string{"/", "/grafana", "/grafana/", "/a/b"} {
+		r.NoError(CheckPathPrefix(prefix), prefix)
+	}
+
+	for _, prefix := range []string{"", "grafana", "/app/*", "/{id}", "/a//b", "/a?b"} {
+		r.Error(CheckPathPrefix(prefix), prefix)
+	}
+
+	a.Nil(PrefixSegments("/"))
+	a.Equal([]string{"grafana"}, PrefixSegments("/grafana/"))
+
+	a.True(HasPrefix(SplitPath("/grafana/d/1"), PrefixSegments("/grafana")))
+	a.True(HasPrefix(SplitPath("/grafana"), PrefixSegments("/grafana")))
+	a.True(HasPrefix(SplitPath("/"), PrefixSegments("/")))
+	a.False(HasPrefix(SplitPath("/grafanax"), PrefixSegments("/grafana")))
+	a.False(HasPrefix(SplitPath("/"), PrefixSegments("/grafana")))
+}
@@ -0,0 +1,101 @@
+package matchers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// Schedule is a compiled recurring weekly time range.
+//
+// The range goes from a start time included to an end time excluded ('09:00' to '18:00'), on the given weekdays,
+// in the given time zone. An end time before the start time spans midnight: the range starts on the given
+// weekdays and ends on the next day ('22:00' to '06:00').
+type Schedule struct {
+	days     [7]bool
+	from, to int // In minutes from midnight
+	location *time.Location
+}
+
+// CompileSchedule parses a schedule, returning an error if it is malformed.
+// All the weekdays are included if none is given. The times default to '00:00' and '24:00', the time zone to UTC.
+func CompileSchedule(days []string, from, to, timeZone string) (*Schedule, error) {
+	s := &Schedule{from: 0, to: 24 * 60, location: time.UTC}
+
+	for _, day := range days {
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday '%s'", day)
+		}
+		s.days[weekday] = true
+	}
+
+	if len(days) == 0 {
+		s.days = [7]bool{true, true, true, true, true, true, true}
+	}
+
+	var err error
+
+	if from != "" {
+		if s.from, err = parseClock(from); err != nil {
+			return nil, err
+		}
+	}
+
+	if to != "" {
+		if s.to, err = parseClock(to); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.from == s.to {
+		return nil, fmt.Errorf("empty time range '%s' to '%s'", from, to)
+	}
+
+	if timeZone != "" {
+		if s.location, err = time.LoadLocation(timeZone); err != nil {
+			return nil, fmt.Errorf("invalid time zone '%s'", timeZone)
+		}
+	}
+
+	return s, nil
+}
+
+// Contains indicates if the given time is in the schedule.
+func (s *Schedule) Contains(t time.Time) bool {
+	t = t.In(s.location)
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+
+	if s.from < s.to {
+		return s.days[day] && minute >= s.from && minute < s.to
+	}
+
+	// The range spans midnight
+	return (s.days[day] && minute >= s.from) || (s.days[(day+6)%7] && minute < s.to)
+}
+
+// parseClock parses a 'HH:MM' time of the day, returning it in minutes from midnight. '24:00' is the end of the day.
+func parseClock(clock string) (int, error) {
+	var hours, minutes int
+
+	if _, err := fmt.Sscanf(clock, "%2d:%2d", &hours, &minutes); err != nil || len(clock) != 5 {
+		return 0, fmt.Errorf("invalid time '%s' (expected 'HH:MM')", clock)
+	}
+
+	if hours < 0 || minutes < 0 || minutes > 59 || hours > 24 || (hours == 24 && minutes != 0) {
+		return 0, fmt.Errorf("invalid time '%s'", clock)
+	}
+
+	return hours*60 + minutes, nil
+}
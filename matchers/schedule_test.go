@@ -0,0 +1,72 @@
+package matchers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCompileSchedule runs tests on the CompileSchedule function.
+func TestCompileSchedule(t *testing.T) {
+	r := require.New(t)
+
+	_, err := CompileSchedule(nil, "", "", "")
+	r.NoError(err)
+	_, err = CompileSchedule([]string{"Mon", "friday"}, "09:00", "18:30", "Europe/Paris")
+	r.NoError(err)
+	_, err = CompileSchedule(nil, "22:00", "06:00", "")
+	r.NoError(err)
+	_, err = CompileSchedule(nil, "18:00", "24:00", "")
+	r.NoError(err)
+
+	invalid := []struct {
+		days               []string
+		from, to, timeZone string
+	}{
+		{[]string{"funday"}, "", "", ""},
+		{nil, "9:00", "", ""},
+		{nil, "09:60", "", ""},
+		{nil, "25:00", "", ""},
+		{nil, "", "24:01", ""},
+		{nil, "10:00", "10:00", ""},
+		{nil, "", "", "Mars/Olympus"},
+	}
+
+	for _, c := range invalid {
+		_, err := CompileSchedule(c.days, c.from, c.to, c.timeZone)
+		r.Error(err, c)
+	}
+}
+
+// TestScheduleContains runs tests on the Schedule Contains method.
+func TestScheduleContains(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	// 2016-01-04 is a monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2016, 1, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	office, err := CompileSchedule([]string{"mon", "tue", "wed", "thu", "fri"}, "09:00", "18:00", "")
+	r.NoError(err)
+	a.True(office.Contains(at(4, 9, 0)))
+	a.True(office.Contains(at(8, 17, 59)))
+	a.False(office.Contains(at(4, 18, 0)))
+	a.False(office.Contains(at(4, 8, 59)))
+	a.False(office.Contains(at(9, 10, 0))) // Saturday
+
+	night, err := CompileSchedule([]string{"fri"}, "22:00", "06:00", "")
+	r.NoError(err)
+	a.True(night.Contains(at(8, 23, 0)))
+	a.True(night.Contains(at(9, 5, 59))) // Saturday morning
+	a.False(night.Contains(at(9, 23, 0)))
+	a.False(night.Contains(at(8, 5, 0)))
+
+	paris, err := CompileSchedule([]string{"mon"}, "09:00", "10:00", "Europe/Paris")
+	r.NoError(err)
+	a.True(paris.Contains(at(4, 8, 30))) // UTC+1 in winter
+	a.False(paris.Contains(at(4, 9, 30)))
+}
@@ -0,0 +1,103 @@
+package models
+
+import "time"
+
+type (
+	// AuditEntry is a denial which would have occured on a resource in report mode.
+	// The session tokens are never recorded.
+	AuditEntry struct {
+		// The entry identifier, increasing with time.
+		ID uint64 `json:"id"`
+		// The request timestamp.
+		Time *time.Time `json:"time,omitempty"`
+		// The name of the resource in report mode.
+		Resource *string `json:"resource,omitempty"`
+		// The requested host name.
+		Hostname string `json:"hostname"`
+		// The requested path.
+		Path string `json:"path"`
+		// The requested method.
+		Method string `json:"method,omitempty"`
+		// The IP of the client, if known.
+		ClientIP string `json:"clientIp,omitempty"`
+		// The owner token of the session. Not set for a guest access.
+		OwnerToken *string `json:"ownerToken,omitempty"`
+		// The policies of the session. Not set for a guest access.
+		Policies []string `json:"policies,omitempty"`
+		// Indicates if the request was evaluated as a guest.
+		Guest bool `json:"guest"`
+		// The algorithm used to combine the policy results.
+		Algorithm string `json:"algorithm,omitempty"`
+		// A human readable explanation of the denial.
+		Reason string `json:"reason"`
+		// The permission which denied the access, if any.
+		Rule *PermissionTrace `json:"rule,omitempty"`
+	}
+
+	AuditFilter struct {
+		// Only returns the entries of this resource.
+		Resource string
+		// Only returns the entries of this host name.
+		Hostname string
+		// Only returns the entries of this session owner.
+		OwnerToken string
+		// Only returns the entries recorded from this time.
+		Since *time.Time
+		// Only returns the entries recorded before this time.
+		Until *time.Time
+		// The maximum number of returned entries.
+		Limit int
+	}
+
+	// AuditStats reports the writes of the audit log, which are queued so the authorization requests never wait.
+	AuditStats struct {
+		// The number of entries waiting to be written.
+		Queued int `json:"queued"`
+		// The maximum number of queued entries, beyond which the new ones are dropped.
+		QueueSize int `json:"queueSize"`
+		// The number of entries dropped since the start because the queue was full.
+		Dropped uint64 `json:"dropped"`
+		// The number of entries lost since the start because their write failed.
+		Failed uint64 `json:"failed"`
+	}
+)
+
+// swagger:response AuditEntriesResponse
+type auditEntriesResponse struct {
+	// in: body
+	Body []AuditEntry
+}
+
+// swagger:response AuditStatsResponse
+type auditStatsResponse struct {
+	// in: body
+	Body AuditStats
+}
+
+// swagger:parameters AuditFind
+type auditFilterParams struct {
+	// Resource name
+	//
+	// in: query
+	Resource string `json:"resource"`
+	// Host name
+	//
+	// in: query
+	Hostname string `json:"hostname"`
+	// Session owner token
+	//
+	// in: query
+	OwnerToken string `json:"ownerToken"`
+	// Lower time bound (RFC 3339)
+	//
+	// in: query
+	Since string `json:"since"`
+	// Upper time bound, excluded (RFC 3339)
+	//
+	// in: query
+	Until string `json:"until"`
+	// Maximum number of entries (100 if not set, 1000 at most)
+	//
+	// in: query
+	Limit int `json:"limit"`
+}
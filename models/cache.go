@@ -0,0 +1,20 @@
+package models
+
+type CacheStats struct {
+	// The number of requests served from the cache.
+	Hits uint64 `json:"hits"`
+	// The number of requests evaluated because no valid entry was cached.
+	Misses uint64 `json:"misses"`
+	// The number of cached entries.
+	Entries int `json:"entries"`
+	// The maximum number of cached entries.
+	Size int `json:"size"`
+	// The lifetime of a cached entry.
+	TTL string `json:"ttl"`
+}
+
+// swagger:response CacheStatsResponse
+type cacheStatsResponse struct {
+	// in: body
+	Body CacheStats
+}
@@ -0,0 +1,92 @@
+package models
+
+import "github.com/solher/auth-nginx-proxy-companion/matchers"
+
+// The algorithms used to combine the results of the policies of a session.
+const (
+	// The first policy with a matching permission decides.
+	FirstApplicable = "first-applicable"
+	// The access is granted if any policy grants it.
+	PermitOverrides = "permit-overrides"
+	// The access is denied if any policy denies it.
+	DenyOverrides = "deny-overrides"
+	// The most specific matching permission across all the policies decides.
+	MostSpecificWins = "most-specific-wins"
+)
+
+type (
+	Decision struct {
+		// Indicates if the access is granted.
+		Granted bool `json:"granted"`
+		// A human readable explanation of the decision.
+		Reason string `json:"reason"`
+		// The requested host name.
+		Hostname string `json:"hostname"`
+		// The requested path.
+		Path string `json:"path"`
+		// The requested method.
+		Method string `json:"method,omitempty"`
+		// The IP of the client, if known.
+		ClientIP string `json:"clientIp,omitempty"`
+		// The resource resolved from the host name.
+		Resource *Resource `json:"resource,omitempty"`
+		// The session resolved from the token. Not set for a guest access.
+		Session *Session `json:"session,omitempty"`
+		// The algorithm used to combine the policy results.
+		Algorithm string `json:"algorithm,omitempty"`
+		// The evaluated policies, in order.
+		Policies []PolicyTrace `json:"policies,omitempty"`
+		// The permission which decided the access.
+		Rule *PermissionTrace `json:"rule,omitempty"`
+	}
+
+	PolicyTrace struct {
+		// The policy name.
+		Name string `json:"name"`
+		// Indicates if the policy is enabled.
+		Enabled bool `json:"enabled"`
+		// Indicates if the policy is within its validity window. Always true for a policy without window.
+		InWindow bool `json:"inWindow"`
+		// Indicates if the policy grants the access. A policy without rule is not applicable.
+		Granted bool `json:"granted"`
+		// The permissions concerning the requested resource.
+		Permissions []PermissionTrace `json:"permissions,omitempty"`
+		// The permission which decided the policy result.
+		Rule *PermissionTrace `json:"rule,omitempty"`
+	}
+
+	PermissionTrace struct {
+		// The name of the policy owning the permission.
+		Policy string `json:"policy"`
+		// The name of the extended policy the permission is inherited from, if any.
+		InheritedFrom string `json:"inheritedFrom,omitempty"`
+		// The position of the permission in the policy.
+		Index int `json:"index"`
+		// The path pattern.
+		Path string `json:"path"`
+		// The methods on which the permission apply.
+		Methods []string `json:"methods,omitempty"`
+		// The conditions on the session attributes.
+		Conditions []string `json:"conditions,omitempty"`
+		// The client IP ranges from which the permission applies.
+		AllowCIDRs []string `json:"allowCidrs,omitempty"`
+		// The client IP ranges from which the permission doesn't apply.
+		DenyCIDRs []string `json:"denyCidrs,omitempty"`
+		// Indicates if the permission denies the access.
+		Deny bool `json:"deny"`
+		// The specificity of the path pattern, used to rank the matching permissions.
+		Specificity *matchers.Specificity `json:"specificity,omitempty"`
+		// Indicates if the permission targets the request method explicitly.
+		MethodSpecific bool `json:"methodSpecific"`
+		// The evaluation result of the permission.
+		// One of: 'applied', 'overridden', 'no match', 'method mismatch', 'condition mismatch', 'outside window',
+		// 'client IP mismatch', 'disabled', 'invalid path', 'invalid condition', 'invalid CIDR'
+		Status string `json:"status"`
+	}
+)
+
+// swagger:response DecisionResponse
+type decisionResponse struct {
+	// in: body
+	Body Decision
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// GCStatus reports the runs of the garbage collector, which archives the expired sessions and refresh tokens.
+type GCStatus struct {
+	// Indicates if a run is in progress.
+	Running bool `json:"running"`
+	// Indicates if a run was requested and will start once the current one is done.
+	Pending bool `json:"pending"`
+	// The start of the last finished run.
+	LastRun *time.Time `json:"lastRun,omitempty"`
+	// The duration of the last finished run.
+	LastDuration string `json:"lastDuration,omitempty"`
+	// The number of sessions archived by the last run.
+	ArchivedSessions int `json:"archivedSessions"`
+	// The number of refresh tokens archived by the last run.
+	ArchivedRefreshTokens int `json:"archivedRefreshTokens"`
+	// The number of expired jwt revocations deleted by the last run.
+	DeletedRevocations int `json:"deletedRevocations"`
+	// The number of archived sessions pruned by the last run, once expired for longer than the retention.
+	PrunedSessions int `json:"prunedSessions"`
+	// The number of archived refresh tokens pruned by the last run.
+	PrunedRefreshTokens int `json:"prunedRefreshTokens"`
+	// The number of audit entries deleted by the last run, once older than their retention.
+	PrunedAuditEntries int `json:"prunedAuditEntries"`
+	// The number of finished runs since the start.
+	Runs int `json:"runs"`
+	// The number of failed runs since the start.
+	Errors int `json:"errors"`
+	// The error of the last failed run.
+	LastError string `json:"lastError,omitempty"`
+	// The time of the last failed run.
+	LastErrorTime *time.Time `json:"lastErrorTime,omitempty"`
+}
+
+// swagger:response GCStatusResponse
+type gcStatusResponse struct {
+	// in: body
+	Body GCStatus
+}
@@ -7,17 +7,45 @@ type (
 		Name *string `json:"name,omitempty" yaml:"name"`
 		// Can be used to disable a policy.
 		Enabled *bool `json:"enabled,omitempty" yaml:"enabled"`
+		// The names of the policies whose permissions are inherited.
+		Extends []string `json:"extends,omitempty" yaml:"extends"`
 		// An array of resource IDs and their associated right.
 		// required: true
 		Permissions []Permission `json:"permissions,omitempty" yaml:"permissions"`
+		// The optional validity window of the policy. Outside of it, the policy is skipped like a disabled one.
+		// The permissions inherited from the policy are restricted to its window too.
+		Window *Window `json:"window,omitempty" yaml:"window"`
+		// The token bucket rate limits of the granted requests of the sessions having the policy, on any resource.
+		// The buckets of a policy are distinct from the ones of the other policies and of the resources.
+		// Ex: by 'resource' limits the total rate of the sessions having the policy on each resource.
+		RateLimits []RateLimit `json:"rateLimits,omitempty" yaml:"rateLimits"`
 	}
 
 	Permission struct {
 		// The resource ID concerned by the permission.
 		// required: true
 		Resource *string `json:"resource,omitempty" yaml:"resource"`
-		// The optional paths on which the permission apply.
+		// The optional paths on which the permission apply. '*' if not set.
+		// Supports single segment wildcards ('/users/*/profile'), recursive wildcards ('/static/**'),
+		// named segments ('/users/{id}') and globs ('/static/*.js'). A trailing '*' matches the whole subtree.
+		// Whole segments can be substituted from the session at evaluation time:
+		// '${ownerToken}' and the scalar attributes ('${attributes.tenant}'). Ex: '/users/${ownerToken}/*'
 		Paths []string `json:"paths,omitempty" yaml:"paths"`
+		// The optional HTTP methods on which the permission apply. Ex: ['GET', 'HEAD']
+		// A permission without methods applies to every method.
+		Methods []string `json:"methods,omitempty" yaml:"methods"`
+		// The optional conditions on the session attributes, which must all hold for the permission to apply.
+		// Operators: '==', '!=' and 'in'. Ex: ['tenant == "acme"', '"admin" in roles']
+		// A missing attribute evaluates as null. A guest has no attributes.
+		Conditions []string `json:"conditions,omitempty" yaml:"conditions"`
+		// The optional validity window of the permission. Outside of it, the permission doesn't apply.
+		Window *Window `json:"window,omitempty" yaml:"window"`
+		// The optional client IP ranges from which the permission applies. Ex: ['10.8.0.0/16']
+		// A permission never applies if the client IP is unknown.
+		AllowCIDRs []string `json:"allowCidrs,omitempty" yaml:"allowCidrs"`
+		// The optional client IP ranges from which the permission doesn't apply.
+		// Ex: a denied permission with the office ranges denies the access from anywhere else.
+		DenyCIDRs []string `json:"denyCidrs,omitempty" yaml:"denyCidrs"`
 		// Can be used to disable a permission.
 		Enabled *bool `json:"enabled,omitempty" yaml:"enabled"`
 		// Indicates if the permission grants or denies the access on the resource.
@@ -0,0 +1,25 @@
+package models
+
+// The keys the requests can be counted by in a rate limit.
+const (
+	// One bucket per session token.
+	LimitByToken = "token"
+	// One bucket per owner token, shared by all the sessions of an owner.
+	LimitByOwnerToken = "ownerToken"
+	// One bucket per client IP.
+	LimitByClientIP = "clientIp"
+	// One bucket per resource.
+	LimitByResource = "resource"
+)
+
+type RateLimit struct {
+	// The key the requests are counted by.
+	// One of: 'token', 'ownerToken', 'clientIp', 'resource'
+	// required: true
+	By *string `json:"by,omitempty" yaml:"by"`
+	// The number of requests per second allowed in the long run.
+	// required: true
+	Rate *float64 `json:"rate,omitempty" yaml:"rate"`
+	// The number of requests which can be made at once. The rate rounded up if not set.
+	Burst *int `json:"burst,omitempty" yaml:"burst"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+type (
+	// RefreshToken is a long-lived token exchanged for a new session, stored keyed by its hash.
+	// Each exchange rotates it, and the successive tokens of a session form a family.
+	RefreshToken struct {
+		// The identifier shared by the successive refresh tokens of a session.
+		Family *string `json:"family,omitempty"`
+		// The token hash of the session issued with the refresh token.
+		SessionToken *string `json:"sessionToken,omitempty"`
+		// The creation timestamp.
+		Created *time.Time `json:"created,omitempty"`
+		// The validity time limit of the refresh token.
+		ValidTo *time.Time `json:"validTo,omitempty"`
+		// When the refresh token was exchanged. Exchanging it again revokes the family.
+		Rotated *time.Time `json:"rotated,omitempty"`
+		// When the refresh token was revoked.
+		Revoked *time.Time `json:"revoked,omitempty"`
+	}
+
+	Refresh struct {
+		// The refresh token to exchange.
+		// required: true
+		RefreshToken *string `json:"refreshToken,omitempty"`
+	}
+)
+
+// swagger:parameters SessionsRefresh
+type sessionsRefreshBodyParam struct {
+	// required: true
+	// in: body
+	Body Refresh
+}
@@ -1,16 +1,45 @@
 package models
 
+// The enforcement modes of a resource.
+const (
+	// The decisions are enforced.
+	ModeEnforce = "enforce"
+	// The access is always granted, the denials which would have occured are recorded in the audit log.
+	ModeReport = "report"
+)
+
 type Resource struct {
 	// The resource name. Must be unique.
 	// required: true
 	Name *string `json:"name,omitempty" yaml:"name"`
 	// The resource host name. Ex: 'resource.example.com'
+	// A leading '*' label matches any single label. Ex: '*.preview.example.com'
+	// An exact host name always takes precedence over a wildcard one. The port and the case are ignored.
 	// required: true
 	Hostname *string `json:"hostname,omitempty" yaml:"hostname"`
+	// The additional host names of the resource, following the same rules as the main one.
+	Aliases []string `json:"aliases,omitempty" yaml:"aliases"`
+	// Restricts the resource to the request paths under this prefix. Ex: '/grafana'
+	// Several resources can share a host name with different prefixes, the longest matching one is used.
+	// The permission paths are still matched against the whole request path.
+	PathPrefix *string `json:"pathPrefix,omitempty" yaml:"pathPrefix"`
 	// Disable the authentication for that resource.
 	Public *bool `json:"public,omitempty" yaml:"public"`
 	// The redirection URL when access is denied to the resource.
 	RedirectURL *string `json:"redirectUrl,omitempty" yaml:"redirectUrl"`
+	// The algorithm combining the session policies for that resource. Overrides the default one.
+	// One of: 'first-applicable', 'permit-overrides', 'deny-overrides', 'most-specific-wins'
+	CombiningAlgorithm *string `json:"combiningAlgorithm,omitempty" yaml:"combiningAlgorithm"`
+	// The enforcement mode. In report mode, the access is always granted and the would-be denials are audited.
+	// One of: 'enforce' (default), 'report'
+	Mode *string `json:"mode,omitempty" yaml:"mode"`
+	// The client IP ranges from which the resource can be accessed, whatever the session. Ex: ['10.8.0.0/16']
+	// All the client IPs are allowed if not set. Also applies to a public resource.
+	AllowCIDRs []string `json:"allowCidrs,omitempty" yaml:"allowCidrs"`
+	// The client IP ranges from which the resource can never be accessed. Takes precedence over the allowed ones.
+	DenyCIDRs []string `json:"denyCidrs,omitempty" yaml:"denyCidrs"`
+	// The token bucket rate limits of the granted requests on the resource. Every limit must be satisfied.
+	RateLimits []RateLimit `json:"rateLimits,omitempty" yaml:"rateLimits"`
 }
 
 // swagger:response ResourcesResponse
@@ -25,16 +54,16 @@ type resourceResponse struct {
 	Body Resource
 }
 
-// swagger:parameters ResourcesFindByHostname ResourcesDeleteByHostname ResourcesUpdateByHostname
-type resourcesHostnameParam struct {
-	// Resource hostname
+// swagger:parameters ResourcesFindByName ResourcesDeleteByName ResourcesUpdateByName
+type resourcesNameParam struct {
+	// Resource name
 	//
 	// required: true
 	// in: path
-	Hostname string
+	Name string
 }
 
-// swagger:parameters ResourcesCreate ResourcesUpdateByHostname
+// swagger:parameters ResourcesCreate ResourcesUpdateByName
 type resourcesBodyParam struct {
 	// required: true
 	// in: body
@@ -1,14 +1,46 @@
 package models
 
-import "time"
+import (
+	"math"
+	"time"
+)
+
+// The formats of the session tokens.
+const (
+	// TokenOpaque tokens are random strings, looked up in the database.
+	TokenOpaque = "opaque"
+	// TokenJWT tokens are HS256 signed JWTs carrying the session, verified without any lookup.
+	TokenJWT = "jwt"
+)
+
+// MaxValidTo is the latest validity of a session, the last one the expiry index can order (in 2262).
+var MaxValidTo = time.Unix(0, math.MaxInt64).UTC()
+
+// SigningKey is a key signing or verifying the JWT session tokens, identified by the "kid" header.
+type SigningKey struct {
+	ID     string
+	Secret []byte
+}
 
 type Session struct {
 	// The creation timestamp.
 	Created *time.Time `json:"created,omitempty"`
 	// The validity time limit of the session.
 	ValidTo *time.Time `json:"validTo,omitempty"`
+	// The absolute validity time limit of the session, up to which an active session is extended.
+	// Only set when the idle timeout is enabled.
+	MaxValidTo *time.Time `json:"maxValidTo,omitempty"`
+	// The time of the last granted authorization request, recorded with some delay.
+	LastActivity *time.Time `json:"lastActivity,omitempty"`
 	// The authentication token identifying the session.
+	// It is stored hashed, and therefore only returned at creation or to the callers providing it.
+	// Generated if not set, and always in jwt mode.
 	Token *string `json:"token,omitempty"`
+	// The identifier of a JWT token ("jti" claim), used to revoke it. Only set in jwt mode.
+	TokenID *string `json:"tokenId,omitempty"`
+	// The refresh token issued with the session, exchanged for a new session by POST /sessions/refresh.
+	// Only returned at creation, when the refresh tokens are enabled.
+	RefreshToken *string `json:"refreshToken,omitempty"`
 	// An optional token to find a user's sessions.
 	OwnerToken *string `json:"ownerToken,omitempty"`
 	// The end user agent.
@@ -19,6 +51,65 @@ type Session struct {
 	Policies []string `json:"policies,omitempty"`
 	// A client non checked custom payload.
 	Payload *string `json:"payload,omitempty"`
+	// The structured attributes of the session, on which the permission conditions are evaluated.
+	// Ex: {"tenant": "acme", "roles": ["admin"]}
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// SessionUpdate is a partial update of a session. The fields which are not set are left unchanged.
+type SessionUpdate struct {
+	// The new list of the policy names associated with the session.
+	Policies []string `json:"policies,omitempty"`
+	// The new client non checked custom payload.
+	Payload *string `json:"payload,omitempty"`
+	// The new validity time limit of the session. When the idle timeout is enabled, it is its absolute limit.
+	ValidTo *time.Time `json:"validTo,omitempty"`
+}
+
+type (
+	SessionFilter struct {
+		// Only returns the sessions of this owner, listed from the owner index.
+		OwnerToken string
+		// Only returns the sessions having this policy.
+		Policy string
+		// Only returns the sessions whose agent contains this string, whatever the case.
+		Agent string
+		// Only returns the sessions created from this time.
+		CreatedSince *time.Time
+		// Only returns the sessions created before this time.
+		CreatedUntil *time.Time
+		// Only returns the sessions expiring from this time.
+		ExpiresSince *time.Time
+		// Only returns the sessions expiring before this time.
+		ExpiresUntil *time.Time
+		// Only returns the sessions after this cursor, returned with the previous page.
+		Cursor string
+		// The maximum number of returned sessions.
+		Limit int
+	}
+
+	// SessionPage is a page of the sessions matching a filter.
+	SessionPage struct {
+		Sessions []Session
+		// The number of matching sessions, across all the pages. Only counted on the first page.
+		Total *int
+		// The cursor of the next page. Empty on the last page.
+		NextCursor string
+	}
+)
+
+// swagger:response SessionsPageResponse
+type sessionsPageResponse struct {
+	// The number of matching sessions, across all the pages, only set on the first page
+	//
+	// in: header
+	XTotalCount int `json:"X-Total-Count"`
+	// The cursor of the next page, only set if there is one
+	//
+	// in: header
+	XNextCursor string `json:"X-Next-Cursor"`
+	// in: body
+	Body []Session
 }
 
 // swagger:response SessionsResponse
@@ -33,7 +124,7 @@ type sessionResponse struct {
 	Body Session
 }
 
-// swagger:parameters SessionsFindByToken SessionsDeleteByToken
+// swagger:parameters SessionsFindByToken SessionsDeleteByToken SessionsUpdateByToken ArchiveFindSessionByToken
 type sessionsTokenParam struct {
 	// Session token
 	//
@@ -42,7 +133,7 @@ type sessionsTokenParam struct {
 	Token string
 }
 
-// swagger:parameters SessionsDeleteByOwnerToken
+// swagger:parameters SessionsDeleteByOwnerToken SessionsUpdateByOwnerToken
 type sessionsOwnerTokenParam struct {
 	// Owner tokens (a json array)
 	//
@@ -57,3 +148,50 @@ type sessionsBodyParam struct {
 	// in: body
 	Body Session
 }
+
+// swagger:parameters SessionsUpdateByToken SessionsUpdateByOwnerToken
+type sessionsUpdateBodyParam struct {
+	// required: true
+	// in: body
+	Body SessionUpdate
+}
+
+// swagger:parameters SessionsFind ArchiveFindSessions
+type sessionsFilterParams struct {
+	// Session owner token
+	//
+	// in: query
+	OwnerToken string `json:"ownerToken"`
+	// Policy name
+	//
+	// in: query
+	Policy string `json:"policy"`
+	// Part of the agent, whatever the case
+	//
+	// in: query
+	Agent string `json:"agent"`
+	// Lower creation time bound (RFC 3339)
+	//
+	// in: query
+	CreatedSince string `json:"createdSince"`
+	// Upper creation time bound, excluded (RFC 3339)
+	//
+	// in: query
+	CreatedUntil string `json:"createdUntil"`
+	// Lower expiry time bound (RFC 3339)
+	//
+	// in: query
+	ExpiresSince string `json:"expiresSince"`
+	// Upper expiry time bound, excluded (RFC 3339)
+	//
+	// in: query
+	ExpiresUntil string `json:"expiresUntil"`
+	// The "X-Next-Cursor" header of the previous page
+	//
+	// in: query
+	Cursor string `json:"cursor"`
+	// Maximum number of sessions (100 if not set, 1000 at most)
+	//
+	// in: query
+	Limit int `json:"limit"`
+}
@@ -0,0 +1,71 @@
+package models
+
+type (
+	Simulation struct {
+		// A proposed policy, replacing the policy of the same name or added to the current ones.
+		Policy *Policy `json:"policy,omitempty"`
+		// A proposed configuration, replacing all the current resources and policies.
+		// The proposed policy, if any, is applied on top of it.
+		Config *SimulationConfig `json:"config,omitempty"`
+		// The requests evaluated for each active session and for a guest.
+		// required: true
+		Probes []Probe `json:"probes"`
+	}
+
+	// SimulationConfig has the same shape as a configuration file.
+	SimulationConfig struct {
+		Resources []Resource `json:"resources"`
+		Policies  []Policy   `json:"policies"`
+	}
+
+	Probe struct {
+		// The requested host name.
+		// required: true
+		Hostname string `json:"hostname"`
+		// The requested path. '/' if not set.
+		Path string `json:"path"`
+		// The requested method.
+		Method string `json:"method,omitempty"`
+		// The IP of the client. Unknown if not set.
+		ClientIP string `json:"clientIp,omitempty"`
+	}
+
+	SimulationResult struct {
+		// The number of evaluated sessions, including the guest one.
+		Sessions int `json:"sessions"`
+		// The number of evaluated probes.
+		Probes int `json:"probes"`
+		// The decisions which would change with the proposal.
+		Flips []DecisionFlip `json:"flips"`
+	}
+
+	DecisionFlip struct {
+		// The flipped probe.
+		Probe Probe `json:"probe"`
+		// Indicates if the probe was evaluated as a guest.
+		Guest bool `json:"guest"`
+		// The session owner token. Not set for a guest access.
+		OwnerToken *string `json:"ownerToken,omitempty"`
+		// Indicates if the access is currently granted.
+		Granted bool `json:"granted"`
+		// Indicates if the access would be granted with the proposal.
+		ProposedGranted bool `json:"proposedGranted"`
+		// A human readable explanation of the current decision.
+		Reason string `json:"reason"`
+		// A human readable explanation of the proposed decision.
+		ProposedReason string `json:"proposedReason"`
+	}
+)
+
+// swagger:response SimulationResultResponse
+type simulationResultResponse struct {
+	// in: body
+	Body SimulationResult
+}
+
+// swagger:parameters AuthSimulate
+type simulationBodyParam struct {
+	// required: true
+	// in: body
+	Body Simulation
+}
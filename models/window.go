@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+type (
+	Window struct {
+		// The optional start of the validity, included. Ex: '2016-01-01T00:00:00Z'
+		From *time.Time `json:"from,omitempty" yaml:"from"`
+		// The optional end of the validity, excluded.
+		To *time.Time `json:"to,omitempty" yaml:"to"`
+		// The optional recurring time ranges during which the window is open. Any of them can match.
+		Schedules []Schedule `json:"schedules,omitempty" yaml:"schedules"`
+	}
+
+	Schedule struct {
+		// The weekdays on which the schedule starts ('mon' to 'sun'). Every day if not set.
+		Days []string `json:"days,omitempty" yaml:"days"`
+		// The start time of the day, included. '00:00' if not set.
+		From string `json:"from,omitempty" yaml:"from"`
+		// The end time of the day, excluded. '24:00' if not set.
+		// An end time before the start time spans midnight. Ex: '22:00' to '06:00'
+		To string `json:"to,omitempty" yaml:"to"`
+		// The IANA time zone of the times. 'UTC' if not set. Ex: 'Europe/Paris'
+		TimeZone string `json:"timeZone,omitempty" yaml:"timeZone"`
+	}
+)
@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/solher/auth-nginx-proxy-companion/errs"
+	"github.com/boltdb/bolt"
+	"github.com/solher/zest"
+)
+
+func init() {
+	zest.Injector.Register(NewArchiveRepository)
+}
+
+// ArchiveRepository runs the transactions on the archive database, where the garbage collector moves the
+// expired entries. Its methods are named apart from the Repository ones, so both can be injected.
+type ArchiveRepository struct {
+	db *bolt.DB
+}
+
+func NewArchiveRepository() *ArchiveRepository {
+	return &ArchiveRepository{}
+}
+
+// Open opens the archive database. It is created if it doesn't exist.
+func (r *ArchiveRepository) Open(location string, timeout time.Duration) error {
+	db, err := bolt.Open(location, 0600, &bolt.Options{Timeout: timeout})
+	if err != nil {
+		return err
+	}
+
+	r.db = db
+
+	return nil
+}
+
+func (r *ArchiveRepository) Close() error {
+	if r.db == nil {
+		return nil
+	}
+
+	return r.db.Close()
+}
+
+func (r *ArchiveRepository) UpdateArchive(t func(tx *bolt.Tx) error) error {
+	if r.db == nil {
+		return errs.Internal.Database
+	}
+
+	if err := r.db.Update(t); err != nil {
+		return errs.Internal.Database
+	}
+
+	return nil
+}
+
+func (r *ArchiveRepository) ViewArchive(t func(tx *bolt.Tx) error) error {
+	if r.db == nil {
+		return errs.Internal.Database
+	}
+
+	if err := r.db.View(t); err != nil {
+		return errs.Internal.Database
+	}
+
+	return nil
+}
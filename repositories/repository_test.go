@@ -2,8 +2,12 @@ package repositories
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/solher/auth-nginx-proxy-companion/errs"
 	"github.com/boltdb/bolt"
 	"github.com/stretchr/testify/require"
 )
@@ -48,3 +52,38 @@ func TestRepository(t *testing.T) {
 	err = repo.View(func(tx *bolt.Tx) error { return nil })
 	r.Error(err)
 }
+
+// TestArchiveRepository runs tests on the ArchiveRepository.
+func TestArchiveRepository(t *testing.T) {
+	r := require.New(t)
+	location := filepath.Join(os.TempDir(), "archive-repository-test.db")
+	repo := NewArchiveRepository()
+
+	defer os.Remove(location)
+
+	// Not opened yet
+	err := repo.ViewArchive(func(tx *bolt.Tx) error { return nil })
+	r.Error(err)
+
+	err = repo.UpdateArchive(func(tx *bolt.Tx) error { return nil })
+	r.Error(err)
+
+	err = repo.Open(location, time.Second)
+	r.NoError(err)
+
+	err = repo.UpdateArchive(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte("sessions"))
+		return err
+	})
+	r.NoError(err)
+
+	err = repo.ViewArchive(func(tx *bolt.Tx) error { return nil })
+	r.NoError(err)
+
+	// The errors are wrapped
+	err = repo.ViewArchive(func(tx *bolt.Tx) error { return errors.New("ERROR") })
+	r.Error(err)
+	r.Equal(errs.Internal.Database, err)
+
+	r.NoError(repo.Close())
+}
@@ -0,0 +1,99 @@
+// +build integration
+
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/solher/auth-nginx-proxy-companion/app"
+	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/solher/auth-nginx-proxy-companion/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestArchive runs integration tests on the Archive resource methods.
+func TestArchive(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	appli := app.NewTestApp()
+	url, err := appli.Launch()
+	r.NoError(err)
+	defer appli.Stop()
+
+	testURL := url + "/archive/sessions"
+
+	client := &http.Client{}
+	status := &models.GCStatus{}
+	sessionOut := &models.Session{}
+	sessionsOut := []models.Session{}
+
+	// Nothing archived yet
+	res, err := client.Do(utils.FakeRequest("GET", testURL+"/F00bAr2", nil))
+	r.NoError(err)
+	r.Equal(404, res.StatusCode)
+
+	// The expired session is archived by the garbage collector
+	res, err = client.Do(utils.FakeRequest("POST", url+"/gc", nil))
+	r.NoError(err)
+	r.Equal(202, res.StatusCode)
+
+	for i := 0; i < 50 && status.Runs == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+
+		res, err = client.Do(utils.FakeRequest("GET", url+"/gc", nil))
+		r.NoError(err)
+		err = json.NewDecoder(res.Body).Decode(status)
+		r.NoError(err)
+	}
+
+	r.Equal(1, status.ArchivedSessions)
+
+	// Find by token succeeds
+	res, err = client.Do(utils.FakeRequest("GET", testURL+"/F00bAr2", nil))
+	r.NoError(err)
+	r.Equal(200, res.StatusCode)
+	err = json.NewDecoder(res.Body).Decode(sessionOut)
+	r.NoError(err)
+	a.Equal("F00bAr2", *sessionOut.Token)
+	a.Equal("owner1", *sessionOut.OwnerToken)
+
+	// A live session is not archived
+	res, err = client.Do(utils.FakeRequest("GET", testURL+"/F00bAr", nil))
+	r.NoError(err)
+	r.Equal(404, res.StatusCode)
+
+	// Find by owner token succeeds
+	res, err = client.Do(utils.FakeRequest("GET", testURL+"?ownerToken=owner1", nil))
+	r.NoError(err)
+	r.Equal(200, res.StatusCode)
+	a.Equal("1", res.Header.Get("X-Total-Count"))
+	err = json.NewDecoder(res.Body).Decode(&sessionsOut)
+	r.NoError(err)
+	a.Len(sessionsOut, 1)
+
+	// Find by date range succeeds
+	since := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	res, err = client.Do(utils.FakeRequest("GET", testURL+"?expiresSince="+since, nil))
+	r.NoError(err)
+	r.Equal(200, res.StatusCode)
+	err = json.NewDecoder(res.Body).Decode(&sessionsOut)
+	r.NoError(err)
+	a.Len(sessionsOut, 1)
+
+	res, err = client.Do(utils.FakeRequest("GET", testURL+"?expiresUntil="+since, nil))
+	r.NoError(err)
+	r.Equal(200, res.StatusCode)
+	err = json.NewDecoder(res.Body).Decode(&sessionsOut)
+	r.NoError(err)
+	a.Len(sessionsOut, 0)
+
+	// Invalid date
+	res, err = client.Do(utils.FakeRequest("GET", testURL+"?expiresSince=lastmonth", nil))
+	r.NoError(err)
+	r.Equal(400, res.StatusCode)
+}
@@ -0,0 +1,92 @@
+// +build integration
+
+package tests
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/solher/auth-nginx-proxy-companion/app"
+	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/solher/auth-nginx-proxy-companion/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAuditFind runs integration tests on the Audit resource Find methods.
+func TestAuditFind(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	appli := app.NewTestApp()
+	url, err := appli.Launch()
+	r.NoError(err)
+	defer appli.Stop()
+
+	client := &http.Client{}
+	entries := []models.AuditEntry{}
+
+	authorize := func() int {
+		req := utils.FakeRequest("GET", url+"/auth", nil)
+		req.Header.Set("Request-URL", "http://foo.bar.com/bar")
+		req.Header.Add("Auth-Server-Token", "F00bAr")
+
+		res, err := client.Do(req)
+		r.NoError(err)
+
+		return res.StatusCode
+	}
+
+	// The access is denied and not audited in enforce mode
+	r.Equal(403, authorize())
+
+	resource := &models.Resource{
+		Hostname: utils.StrCpy("foo.bar.com"),
+		Mode:     utils.StrCpy(models.ModeReport),
+	}
+
+	res, err := client.Do(utils.FakeRequest("PUT", url+"/resources/Foobar", resource))
+	r.NoError(err)
+	r.Equal(200, res.StatusCode)
+
+	// The access is granted in report mode
+	r.Equal(204, authorize())
+
+	// The would-be denial is audited, once written in the background
+	var body []byte
+	for attempt := 0; attempt < 50 && len(entries) == 0; attempt++ {
+		time.Sleep(10 * time.Millisecond)
+
+		res, err = client.Do(utils.FakeRequest("GET", url+"/audit?resource=Foobar", nil))
+		r.NoError(err)
+		r.Equal(200, res.StatusCode)
+		body, err = ioutil.ReadAll(res.Body)
+		r.NoError(err)
+		err = json.Unmarshal(body, &entries)
+		r.NoError(err)
+	}
+	r.Len(entries, 1)
+	a.Equal("/bar", entries[0].Path)
+	a.Equal("owner1", *entries[0].OwnerToken)
+	a.NotContains(string(body), "F00bAr")
+
+	// The filters are applied
+	res, err = client.Do(utils.FakeRequest("GET", url+"/audit?resource=Foobar2", nil))
+	r.NoError(err)
+	r.Equal(200, res.StatusCode)
+	err = json.NewDecoder(res.Body).Decode(&entries)
+	r.NoError(err)
+	a.Len(entries, 0)
+
+	// The writes are reported
+	res, err = client.Do(utils.FakeRequest("GET", url+"/audit/stats", nil))
+	r.NoError(err)
+	r.Equal(200, res.StatusCode)
+	stats := &models.AuditStats{}
+	err = json.NewDecoder(res.Body).Decode(stats)
+	r.NoError(err)
+	a.Equal(uint64(0), stats.Dropped)
+}
@@ -3,10 +3,13 @@
 package tests
 
 import (
+	"encoding/json"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/solher/auth-nginx-proxy-companion/app"
+	"github.com/solher/auth-nginx-proxy-companion/models"
 	"github.com/solher/auth-nginx-proxy-companion/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -80,3 +83,398 @@ func TestAuthAuthorizeToken(t *testing.T) {
 	r.NoError(err)
 	r.Equal(403, res.StatusCode)
 }
+
+// TestAuthExplain runs integration tests on the Explain method.
+func TestAuthExplain(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	appli := app.NewTestApp()
+	url, err := appli.Launch()
+	r.NoError(err)
+	defer appli.Stop()
+
+	testURL := url + "/auth/explain"
+
+	client := &http.Client{}
+	decision := &models.Decision{}
+
+	req := utils.FakeRequest("GET", testURL, nil)
+	req.Header.Set("Request-URL", "http://foo.bar.com/foo/foo")
+	req.Header.Add("Auth-Server-Token", "F00bAr")
+
+	// Access denied: the denying permission is explained
+	res, err := client.Do(req)
+	r.NoError(err)
+	r.Equal(200, res.StatusCode)
+	err = json.NewDecoder(res.Body).Decode(decision)
+	r.NoError(err)
+	a.False(decision.Granted)
+	r.NotNil(decision.Rule)
+	a.Equal("Foo", decision.Rule.Policy)
+	a.Equal("/foo/*", decision.Rule.Path)
+}
+
+func TestAuthCacheStats(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	appli := app.NewTestApp()
+	url, err := appli.Launch()
+	r.NoError(err)
+	defer appli.Stop()
+
+	client := &http.Client{}
+	stats := &models.CacheStats{}
+
+	for i := 0; i < 2; i++ {
+		req := utils.FakeRequest("GET", url+"/auth", nil)
+		req.Header.Set("Request-URL", "http://foo.bar.com/foo/bar")
+		req.Header.Add("Auth-Server-Token", "F00bAr")
+
+		res, err := client.Do(req)
+		r.NoError(err)
+		r.Equal(204, res.StatusCode)
+	}
+
+	// The second request is served from the cache
+	res, err := client.Do(utils.FakeRequest("GET", url+"/auth/cache", nil))
+	r.NoError(err)
+	r.Equal(200, res.StatusCode)
+	err = json.NewDecoder(res.Body).Decode(stats)
+	r.NoError(err)
+	a.Equal(uint64(1), stats.Hits)
+	a.Equal(uint64(1), stats.Misses)
+}
+
+// TestAuthSimulate runs integration tests on the Auth Simulate method.
+func TestAuthSimulate(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	appli := app.NewTestApp()
+	url, err := appli.Launch()
+	r.NoError(err)
+	defer appli.Stop()
+
+	testURL := url + "/auth/simulate"
+
+	client := &http.Client{}
+	simulation := &models.Simulation{}
+	result := &models.SimulationResult{}
+
+	// Validation fails: no proposal
+	res, err := client.Do(utils.FakeRequest("POST", testURL, simulation))
+	r.NoError(err)
+	r.Equal(422, res.StatusCode)
+
+	simulation.Policy = &models.Policy{
+		Name: utils.StrCpy("Foo"),
+		Permissions: []models.Permission{
+			{
+				Resource: utils.StrCpy("Foobar"),
+				Paths:    []string{"/foo/bar"},
+				Deny:     utils.BoolCpy(true),
+			},
+		},
+	}
+	simulation.Probes = []models.Probe{{Hostname: "foo.bar.com", Path: "/foo/bar"}}
+
+	// Simulation succeeds: every active session loses the access, the guest keeps it
+	res, err = client.Do(utils.FakeRequest("POST", testURL, simulation))
+	r.NoError(err)
+	r.Equal(200, res.StatusCode)
+	err = json.NewDecoder(res.Body).Decode(result)
+	r.NoError(err)
+	a.Equal(5, result.Sessions)
+	r.Len(result.Flips, 4)
+	for _, flip := range result.Flips {
+		a.False(flip.Guest)
+		a.True(flip.Granted)
+		a.False(flip.ProposedGranted)
+	}
+
+	req := utils.FakeRequest("GET", url+"/auth", nil)
+	req.Header.Set("Request-URL", "http://foo.bar.com/foo/bar")
+	req.Header.Add("Auth-Server-Token", "F00bAr")
+
+	// Nothing is persisted
+	res, err = client.Do(req)
+	r.NoError(err)
+	r.Equal(204, res.StatusCode)
+}
+
+// TestAuthConditions runs integration tests on the permission conditions over the session attributes.
+func TestAuthConditions(t *testing.T) {
+	r := require.New(t)
+
+	appli := app.NewTestApp()
+	url, err := appli.Launch()
+	r.NoError(err)
+	defer appli.Stop()
+
+	client := &http.Client{}
+
+	policy := &models.Policy{
+		Name: utils.StrCpy("Tenant"),
+		Permissions: []models.Permission{
+			{
+				Resource:   utils.StrCpy("Foobar2"),
+				Paths:      []string{"/acme/*"},
+				Conditions: []string{`tenant == "acme"`, `"admin" in roles`},
+			},
+		},
+	}
+
+	res, err := client.Do(utils.FakeRequest("POST", url+"/policies", policy))
+	r.NoError(err)
+	r.Equal(201, res.StatusCode)
+
+	for token, tenant := range map[string]string{"acme": "acme", "other": "other"} {
+		session := &models.Session{
+			Token:      utils.StrCpy(token),
+			Policies:   []string{"Tenant"},
+			Attributes: map[string]interface{}{"tenant": tenant, "roles": []string{"admin"}},
+		}
+
+		res, err = client.Do(utils.FakeRequest("POST", url+"/sessions", session))
+		r.NoError(err)
+		r.Equal(201, res.StatusCode)
+	}
+
+	authorize := func(token string) int {
+		req := utils.FakeRequest("GET", url+"/auth", nil)
+		req.Header.Set("Request-URL", "http://foo.bar.2.com/acme/dashboard")
+		req.Header.Add("Auth-Server-Token", token)
+
+		res, err := client.Do(req)
+		r.NoError(err)
+
+		return res.StatusCode
+	}
+
+	// The session attributes satisfy the conditions
+	r.Equal(204, authorize("acme"))
+
+	// The session belongs to another tenant
+	r.Equal(403, authorize("other"))
+}
+
+// TestAuthPathVariables runs integration tests on the session variables in the permission paths.
+func TestAuthPathVariables(t *testing.T) {
+	r := require.New(t)
+
+	appli := app.NewTestApp()
+	url, err := appli.Launch()
+	r.NoError(err)
+	defer appli.Stop()
+
+	client := &http.Client{}
+
+	policy := &models.Policy{
+		Name: utils.StrCpy("User"),
+		Permissions: []models.Permission{
+			{
+				Resource: utils.StrCpy("Foobar2"),
+				Paths:    []string{"/users/${ownerToken}/*"},
+			},
+		},
+	}
+
+	res, err := client.Do(utils.FakeRequest("POST", url+"/policies", policy))
+	r.NoError(err)
+	r.Equal(201, res.StatusCode)
+
+	policy.Name = utils.StrCpy("Unknown")
+	policy.Permissions[0].Paths = []string{"/users/${token}/*"}
+
+	// Validation fails: unknown session variable
+	res, err = client.Do(utils.FakeRequest("POST", url+"/policies", policy))
+	r.NoError(err)
+	r.Equal(422, res.StatusCode)
+
+	session := &models.Session{
+		Token:      utils.StrCpy("us3r"),
+		OwnerToken: utils.StrCpy("owner3"),
+		Policies:   []string{"User"},
+	}
+
+	res, err = client.Do(utils.FakeRequest("POST", url+"/sessions", session))
+	r.NoError(err)
+	r.Equal(201, res.StatusCode)
+
+	authorize := func(path string) int {
+		req := utils.FakeRequest("GET", url+"/auth", nil)
+		req.Header.Set("Request-URL", "http://foo.bar.2.com"+path)
+		req.Header.Add("Auth-Server-Token", "us3r")
+
+		res, err := client.Do(req)
+		r.NoError(err)
+
+		return res.StatusCode
+	}
+
+	// The path belongs to the session owner
+	r.Equal(204, authorize("/users/owner3/profile"))
+
+	// The path belongs to another owner
+	r.Equal(403, authorize("/users/owner1/profile"))
+}
+
+// TestAuthWindows runs integration tests on the validity windows of the policies and permissions.
+func TestAuthWindows(t *testing.T) {
+	r := require.New(t)
+
+	appli := app.NewTestApp()
+	url, err := appli.Launch()
+	r.NoError(err)
+	defer appli.Stop()
+
+	client := &http.Client{}
+
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	policy := &models.Policy{
+		Name: utils.StrCpy("Contractor"),
+		Permissions: []models.Permission{
+			{
+				Resource: utils.StrCpy("Foobar2"),
+				Paths:    []string{"/current/*"},
+				Window:   &models.Window{From: &past, To: &future},
+			},
+			{
+				Resource: utils.StrCpy("Foobar2"),
+				Paths:    []string{"/upcoming/*"},
+				Window:   &models.Window{From: &future},
+			},
+		},
+	}
+
+	res, err := client.Do(utils.FakeRequest("POST", url+"/policies", policy))
+	r.NoError(err)
+	r.Equal(201, res.StatusCode)
+
+	session := &models.Session{Token: utils.StrCpy("C0ntr4ct0r"), Policies: []string{"Contractor"}}
+
+	res, err = client.Do(utils.FakeRequest("POST", url+"/sessions", session))
+	r.NoError(err)
+	r.Equal(201, res.StatusCode)
+
+	authorize := func(path string) int {
+		req := utils.FakeRequest("GET", url+"/auth", nil)
+		req.Header.Set("Request-URL", "http://foo.bar.2.com"+path)
+		req.Header.Add("Auth-Server-Token", "C0ntr4ct0r")
+
+		res, err := client.Do(req)
+		r.NoError(err)
+
+		return res.StatusCode
+	}
+
+	// The permission is within its window
+	r.Equal(204, authorize("/current/foo"))
+
+	// The permission is not valid yet
+	r.Equal(403, authorize("/upcoming/foo"))
+
+	policy.Window = &models.Window{From: &future, To: &past}
+
+	// The policy window ends before it starts
+	res, err = client.Do(utils.FakeRequest("PUT", url+"/policies/Contractor", policy))
+	r.NoError(err)
+	r.Equal(422, res.StatusCode)
+}
+
+// TestAuthClientIP runs integration tests on the client IP restrictions of the resources.
+func TestAuthClientIP(t *testing.T) {
+	r := require.New(t)
+
+	appli := app.NewTestApp()
+	url, err := appli.Launch()
+	r.NoError(err)
+	defer appli.Stop()
+
+	client := &http.Client{}
+
+	authorize := func() int {
+		req := utils.FakeRequest("GET", url+"/auth", nil)
+		req.Header.Set("Request-URL", "http://foo.bar.2.com/foo")
+		// The caller is not a trusted proxy
+		req.Header.Set("X-Forwarded-For", "10.8.0.1")
+
+		res, err := client.Do(req)
+		r.NoError(err)
+
+		return res.StatusCode
+	}
+
+	resource := &models.Resource{
+		Hostname:   utils.StrCpy("foo.bar.2.com"),
+		Public:     utils.BoolCpy(true),
+		AllowCIDRs: []string{"10.8.0.0/16"},
+	}
+
+	res, err := client.Do(utils.FakeRequest("PUT", url+"/resources/Foobar2", resource))
+	r.NoError(err)
+	r.Equal(200, res.StatusCode)
+
+	// The client IP is outside of the allowed ranges
+	r.Equal(403, authorize())
+
+	resource.AllowCIDRs = append(resource.AllowCIDRs, "127.0.0.1", "::1")
+
+	res, err = client.Do(utils.FakeRequest("PUT", url+"/resources/Foobar2", resource))
+	r.NoError(err)
+	r.Equal(200, res.StatusCode)
+
+	// The client IP is allowed
+	r.Equal(204, authorize())
+
+	resource.DenyCIDRs = []string{"127.0.0.0/33"}
+
+	// The denied range is malformed
+	res, err = client.Do(utils.FakeRequest("PUT", url+"/resources/Foobar2", resource))
+	r.NoError(err)
+	r.Equal(422, res.StatusCode)
+}
+
+// TestAuthRateLimits runs integration tests on the rate limits of the resources.
+func TestAuthRateLimits(t *testing.T) {
+	r := require.New(t)
+
+	appli := app.NewTestApp()
+	url, err := appli.Launch()
+	r.NoError(err)
+	defer appli.Stop()
+
+	client := &http.Client{}
+
+	resource := &models.Resource{
+		Hostname:   utils.StrCpy("foo.bar.2.com"),
+		Public:     utils.BoolCpy(true),
+		RateLimits: []models.RateLimit{{By: utils.StrCpy("token"), Rate: utils.Float64Cpy(0.01), Burst: utils.IntCpy(1)}},
+	}
+
+	res, err := client.Do(utils.FakeRequest("PUT", url+"/resources/Foobar2", resource))
+	r.NoError(err)
+	r.Equal(200, res.StatusCode)
+
+	authorize := func() *http.Response {
+		req := utils.FakeRequest("GET", url+"/auth", nil)
+		req.Header.Set("Request-URL", "http://foo.bar.2.com/foo")
+		req.Header.Add("Auth-Server-Token", "St0l3n")
+
+		res, err := client.Do(req)
+		r.NoError(err)
+
+		return res
+	}
+
+	// The burst allows one request
+	r.Equal(204, authorize().StatusCode)
+
+	// The token bucket is empty
+	res = authorize()
+	r.Equal(429, res.StatusCode)
+	r.Equal("100", res.Header.Get("Retry-After"))
+}
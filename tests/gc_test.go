@@ -0,0 +1,67 @@
+// +build integration
+
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/solher/auth-nginx-proxy-companion/app"
+	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/solher/auth-nginx-proxy-companion/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGC runs integration tests on the GC resource methods.
+func TestGC(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	appli := app.NewTestApp()
+	url, err := appli.Launch()
+	r.NoError(err)
+	defer appli.Stop()
+
+	testURL := url + "/gc"
+
+	client := &http.Client{}
+	status := &models.GCStatus{}
+
+	// No run yet
+	res, err := client.Do(utils.FakeRequest("GET", testURL, nil))
+	r.NoError(err)
+	r.Equal(200, res.StatusCode)
+	err = json.NewDecoder(res.Body).Decode(status)
+	r.NoError(err)
+	a.Equal(0, status.Runs)
+	a.Nil(status.LastRun)
+
+	// Trigger succeeds
+	res, err = client.Do(utils.FakeRequest("POST", testURL, nil))
+	r.NoError(err)
+	r.Equal(202, res.StatusCode)
+
+	// The run is reported once done
+	for i := 0; i < 50 && status.Runs == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+
+		res, err = client.Do(utils.FakeRequest("GET", testURL, nil))
+		r.NoError(err)
+		r.Equal(200, res.StatusCode)
+		err = json.NewDecoder(res.Body).Decode(status)
+		r.NoError(err)
+	}
+
+	r.Equal(1, status.Runs)
+	a.NotNil(status.LastRun)
+	a.Equal(0, status.Errors)
+	a.Equal(1, status.ArchivedSessions)
+
+	// The expired session is archived
+	res, err = client.Do(utils.FakeRequest("GET", url+"/sessions/F00bAr2", nil))
+	r.NoError(err)
+	r.Equal(404, res.StatusCode)
+}
@@ -38,16 +38,16 @@ func TestResourceFind(t *testing.T) {
 	r.NoError(err)
 	a.Len(resourcesOut, 2)
 
-	// FindbyHostname succeeds
-	res, err = client.Do(utils.FakeRequest("GET", testURL+"/foo.bar.com", nil))
+	// FindByName succeeds
+	res, err = client.Do(utils.FakeRequest("GET", testURL+"/Foobar", nil))
 	r.NoError(err)
 	r.Equal(200, res.StatusCode)
 	err = json.NewDecoder(res.Body).Decode(resourceOut)
 	r.NoError(err)
 	a.Equal("Foobar", *resourceOut.Name)
 
-	// FindbyHostname fails: invalid hostname
-	res, err = client.Do(utils.FakeRequest("GET", testURL+"/doesnt.exist", nil))
+	// FindByName fails: invalid name
+	res, err = client.Do(utils.FakeRequest("GET", testURL+"/DoesntExist", nil))
 	r.NoError(err)
 	r.Equal(404, res.StatusCode)
 }
@@ -82,12 +82,34 @@ func TestResourceCreate(t *testing.T) {
 
 	resourceIn.Hostname = utils.StrCpy("foo.bar.com")
 
+	// Validation fails: name must be unique
+	res, err = client.Do(utils.FakeRequest("POST", testURL, resourceIn))
+	r.NoError(err)
+	r.Equal(422, res.StatusCode)
+
+	resourceIn.Name = utils.StrCpy("Foobar3")
+
 	// Validation fails: hostname must be unique
 	res, err = client.Do(utils.FakeRequest("POST", testURL, resourceIn))
 	r.NoError(err)
 	r.Equal(422, res.StatusCode)
 
+	resourceIn.PathPrefix = utils.StrCpy("/foo")
+
+	// Creation succeeds: the hostname is shared under a path prefix
+	res, err = client.Do(utils.FakeRequest("POST", testURL, resourceIn))
+	r.NoError(err)
+	r.Equal(201, res.StatusCode)
+
+	resourceIn.Name = utils.StrCpy("Foobar4")
+
+	// Validation fails: the path prefix is already served
+	res, err = client.Do(utils.FakeRequest("POST", testURL, resourceIn))
+	r.NoError(err)
+	r.Equal(422, res.StatusCode)
+
 	resourceIn.Hostname = utils.StrCpy("is.unique.com")
+	resourceIn.PathPrefix = nil
 
 	// Creation succeeds
 	res, err = client.Do(utils.FakeRequest("POST", testURL, resourceIn))
@@ -98,7 +120,7 @@ func TestResourceCreate(t *testing.T) {
 	a.NotNil(resourceOut.Name)
 
 	// Creation can be confirmed
-	res, err = client.Do(utils.FakeRequest("GET", testURL+"/"+*resourceOut.Hostname, nil))
+	res, err = client.Do(utils.FakeRequest("GET", testURL+"/"+*resourceOut.Name, nil))
 	r.NoError(err)
 	r.Equal(200, res.StatusCode)
 	err = json.NewDecoder(res.Body).Decode(resourceOut)
@@ -122,7 +144,7 @@ func TestResourceDelete(t *testing.T) {
 	resourceOut := &models.Resource{}
 
 	// Deletion succeeds
-	res, err := client.Do(utils.FakeRequest("DELETE", testURL+"/foo.bar.com", nil))
+	res, err := client.Do(utils.FakeRequest("DELETE", testURL+"/Foobar", nil))
 	r.NoError(err)
 	r.Equal(200, res.StatusCode)
 	err = json.NewDecoder(res.Body).Decode(resourceOut)
@@ -130,7 +152,7 @@ func TestResourceDelete(t *testing.T) {
 	a.NotNil(resourceOut.Name)
 
 	// Deletion can be confirmed
-	res, err = client.Do(utils.FakeRequest("GET", testURL+"/foo.bar.com", nil))
+	res, err = client.Do(utils.FakeRequest("GET", testURL+"/Foobar", nil))
 	r.NoError(err)
 	r.Equal(404, res.StatusCode)
 
@@ -162,32 +184,41 @@ func TestResourceUpdate(t *testing.T) {
 	resourceOut := &models.Resource{}
 
 	// Validation fails: everything nil
-	res, err := client.Do(utils.FakeRequest("PUT", testURL+"/foo.bar.com", resourceIn))
+	res, err := client.Do(utils.FakeRequest("PUT", testURL+"/Foobar", resourceIn))
 	r.NoError(err)
 	r.Equal(422, res.StatusCode)
 
-	resourceIn.Name = utils.StrCpy("")
+	resourceIn.Hostname = utils.StrCpy("")
 
-	// Validation fails: blank name
-	res, err = client.Do(utils.FakeRequest("PUT", testURL+"/foo.bar.com", resourceIn))
+	// Validation fails: blank hostname
+	res, err = client.Do(utils.FakeRequest("PUT", testURL+"/Foobar", resourceIn))
+	r.NoError(err)
+	r.Equal(422, res.StatusCode)
+
+	resourceIn.Hostname = utils.StrCpy("foo.bar.2.com")
+
+	// Validation fails: hostname must be unique
+	res, err = client.Do(utils.FakeRequest("PUT", testURL+"/Foobar", resourceIn))
 	r.NoError(err)
 	r.Equal(422, res.StatusCode)
 
-	resourceIn.Name = utils.StrCpy("New")
+	resourceIn.Hostname = utils.StrCpy("new.bar.com")
 
 	// Update succeeds
-	res, err = client.Do(utils.FakeRequest("PUT", testURL+"/foo.bar.com", resourceIn))
+	res, err = client.Do(utils.FakeRequest("PUT", testURL+"/Foobar", resourceIn))
 	r.NoError(err)
 	r.Equal(200, res.StatusCode)
 	err = json.NewDecoder(res.Body).Decode(resourceOut)
 	r.NoError(err)
-	a.Equal("New", *resourceOut.Name)
+	a.Equal("Foobar", *resourceOut.Name)
+	a.Equal("new.bar.com", *resourceOut.Hostname)
 
 	// Update can be confirmed
-	res, err = client.Do(utils.FakeRequest("GET", testURL+"/foo.bar.com", nil))
+	res, err = client.Do(utils.FakeRequest("GET", testURL+"/Foobar", nil))
 	r.NoError(err)
 	r.Equal(200, res.StatusCode)
 	err = json.NewDecoder(res.Body).Decode(resourceOut)
 	r.NoError(err)
-	a.Equal("New", *resourceOut.Name)
+	a.Equal("Foobar", *resourceOut.Name)
+	a.Equal("new.bar.com", *resourceOut.Hostname)
 }
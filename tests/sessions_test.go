@@ -37,6 +37,45 @@ func TestSessionFind(t *testing.T) {
 	err = json.NewDecoder(res.Body).Decode(&sessionsOut)
 	r.NoError(err)
 	a.Len(sessionsOut, 4)
+	a.Equal("4", res.Header.Get("X-Total-Count"))
+	a.Empty(res.Header.Get("X-Next-Cursor"))
+
+	// Find succeeds: by owner token, from the index
+	res, err = client.Do(utils.FakeRequest("GET", testURL+"?ownerToken=owner1", nil))
+	r.NoError(err)
+	r.Equal(200, res.StatusCode)
+	err = json.NewDecoder(res.Body).Decode(&sessionsOut)
+	r.NoError(err)
+	a.Len(sessionsOut, 2)
+
+	// Find succeeds: paginated
+	res, err = client.Do(utils.FakeRequest("GET", testURL+"?policy=Foo&limit=3", nil))
+	r.NoError(err)
+	r.Equal(200, res.StatusCode)
+	err = json.NewDecoder(res.Body).Decode(&sessionsOut)
+	r.NoError(err)
+	a.Len(sessionsOut, 3)
+	a.Equal("4", res.Header.Get("X-Total-Count"))
+	r.NotEmpty(res.Header.Get("X-Next-Cursor"))
+
+	res, err = client.Do(utils.FakeRequest("GET", testURL+"?policy=Foo&limit=3&cursor="+res.Header.Get("X-Next-Cursor"), nil))
+	r.NoError(err)
+	r.Equal(200, res.StatusCode)
+	err = json.NewDecoder(res.Body).Decode(&sessionsOut)
+	r.NoError(err)
+	a.Len(sessionsOut, 1)
+	a.Empty(res.Header.Get("X-Total-Count"))
+	a.Empty(res.Header.Get("X-Next-Cursor"))
+
+	// Find fails: invalid cursor
+	res, err = client.Do(utils.FakeRequest("GET", testURL+"?cursor=F00bAr", nil))
+	r.NoError(err)
+	r.Equal(422, res.StatusCode)
+
+	// Find fails: invalid time bound
+	res, err = client.Do(utils.FakeRequest("GET", testURL+"?createdSince=yesterday", nil))
+	r.NoError(err)
+	r.Equal(400, res.StatusCode)
 
 	// FindbyToken succeeds
 	res, err = client.Do(utils.FakeRequest("GET", testURL+"/F00bAr", nil))
@@ -157,3 +196,128 @@ func TestSessionDelete(t *testing.T) {
 	r.NoError(err)
 	r.Equal(404, res.StatusCode)
 }
+
+// TestSessionRefresh runs integration tests on the Session session Refresh method.
+func TestSessionRefresh(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	appli := app.NewTestApp()
+	url, err := appli.Launch()
+	r.NoError(err)
+	defer appli.Stop()
+
+	testURL := url + "/sessions"
+
+	client := &http.Client{}
+	created := &models.Session{}
+	refreshed := &models.Session{}
+
+	res, err := client.Do(utils.FakeRequest("POST", testURL, &models.Session{Policies: []string{"Foo"}}))
+	r.NoError(err)
+	r.Equal(201, res.StatusCode)
+	err = json.NewDecoder(res.Body).Decode(created)
+	r.NoError(err)
+	r.NotNil(created.RefreshToken)
+
+	// Refresh fails: blank refresh token
+	res, err = client.Do(utils.FakeRequest("POST", testURL+"/refresh", &models.Refresh{}))
+	r.NoError(err)
+	r.Equal(422, res.StatusCode)
+
+	// Refresh fails: unknown refresh token
+	res, err = client.Do(utils.FakeRequest("POST", testURL+"/refresh", &models.Refresh{RefreshToken: utils.StrCpy("doesnt.exist")}))
+	r.NoError(err)
+	r.Equal(401, res.StatusCode)
+
+	// Refresh succeeds: both tokens are rotated
+	res, err = client.Do(utils.FakeRequest("POST", testURL+"/refresh", &models.Refresh{RefreshToken: created.RefreshToken}))
+	r.NoError(err)
+	r.Equal(201, res.StatusCode)
+	err = json.NewDecoder(res.Body).Decode(refreshed)
+	r.NoError(err)
+	a.NotEqual(*created.Token, *refreshed.Token)
+	a.NotEqual(*created.RefreshToken, *refreshed.RefreshToken)
+	a.Equal(created.Policies, refreshed.Policies)
+
+	// The previous session expired
+	res, err = client.Do(utils.FakeRequest("GET", testURL+"/"+*created.Token, nil))
+	r.NoError(err)
+	r.Equal(404, res.StatusCode)
+
+	// Refresh fails: the refresh token is reused, the whole family is revoked
+	res, err = client.Do(utils.FakeRequest("POST", testURL+"/refresh", &models.Refresh{RefreshToken: created.RefreshToken}))
+	r.NoError(err)
+	r.Equal(401, res.StatusCode)
+
+	res, err = client.Do(utils.FakeRequest("GET", testURL+"/"+*refreshed.Token, nil))
+	r.NoError(err)
+	r.Equal(404, res.StatusCode)
+
+	res, err = client.Do(utils.FakeRequest("POST", testURL+"/refresh", &models.Refresh{RefreshToken: refreshed.RefreshToken}))
+	r.NoError(err)
+	r.Equal(401, res.StatusCode)
+}
+
+// TestSessionUpdate runs integration tests on the Session session Update methods.
+func TestSessionUpdate(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	appli := app.NewTestApp()
+	url, err := appli.Launch()
+	r.NoError(err)
+	defer appli.Stop()
+
+	testURL := url + "/sessions"
+
+	client := &http.Client{}
+	sessionOut := &models.Session{}
+	sessionsOut := []models.Session{}
+
+	// Update by token succeeds
+	res, err := client.Do(utils.FakeRequest("PATCH", testURL+"/F00bAr", &models.SessionUpdate{Policies: []string{"Foo"}}))
+	r.NoError(err)
+	r.Equal(200, res.StatusCode)
+	err = json.NewDecoder(res.Body).Decode(sessionOut)
+	r.NoError(err)
+	a.Equal("F00bAr", *sessionOut.Token)
+	a.Equal([]string{"Foo"}, sessionOut.Policies)
+
+	// Update by token fails: unknown policy
+	res, err = client.Do(utils.FakeRequest("PATCH", testURL+"/F00bAr", &models.SessionUpdate{Policies: []string{"doesnt.exist"}}))
+	r.NoError(err)
+	r.Equal(422, res.StatusCode)
+
+	// Update by token fails: blank update
+	res, err = client.Do(utils.FakeRequest("PATCH", testURL+"/F00bAr", &models.SessionUpdate{}))
+	r.NoError(err)
+	r.Equal(422, res.StatusCode)
+
+	// Update by token fails: expired session
+	res, err = client.Do(utils.FakeRequest("PATCH", testURL+"/F00bAr2", &models.SessionUpdate{Payload: utils.StrCpy("foo")}))
+	r.NoError(err)
+	r.Equal(404, res.StatusCode)
+
+	// Update by ownerToken succeeds
+	res, err = client.Do(utils.FakeRequest("PATCH", testURL+`?ownerTokens=["owner1","owner6"]`, &models.SessionUpdate{Payload: utils.StrCpy("foo")}))
+	r.NoError(err)
+	r.Equal(200, res.StatusCode)
+	err = json.NewDecoder(res.Body).Decode(&sessionsOut)
+	r.NoError(err)
+	a.Len(sessionsOut, 2)
+
+	// Update by ownerToken fails: invalid token
+	res, err = client.Do(utils.FakeRequest("PATCH", testURL+`?ownerTokens=owner1`, &models.SessionUpdate{Payload: utils.StrCpy("foo")}))
+	r.NoError(err)
+	r.Equal(400, res.StatusCode)
+
+	// Updates can be confirmed
+	res, err = client.Do(utils.FakeRequest("GET", testURL+"/F00bAr", nil))
+	r.NoError(err)
+	r.Equal(200, res.StatusCode)
+	err = json.NewDecoder(res.Body).Decode(sessionOut)
+	r.NoError(err)
+	a.Equal([]string{"Foo"}, sessionOut.Policies)
+	a.Equal("foo", *sessionOut.Payload)
+}
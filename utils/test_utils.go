@@ -3,18 +3,29 @@ package utils
 import (
 	"bytes"
 	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"time"
 
+	"github.com/solher/auth-nginx-proxy-companion/models"
 	"github.com/solher/zest"
 )
 
 type FakeModelsGetter struct {
 	RedirectURL        string
 	GrantAll           bool
+	CombiningAlgorithm string
+	DecisionCacheTTL   time.Duration
+	DecisionCacheSize  int
+	TrustedProxies     []*net.IPNet
 	SessionValidity    time.Duration
 	SessionTokenLength int
+	SessionIdleTimeout time.Duration
+	SessionMaxLifetime time.Duration
+	RefreshValidity    time.Duration
+	TokenFormat        string
+	JWTKeys            []models.SigningKey
 }
 
 func NewFakeModelsGetter() *FakeModelsGetter {
@@ -29,6 +40,22 @@ func (g *FakeModelsGetter) GetRedirectURL() string {
 	return g.RedirectURL
 }
 
+func (g *FakeModelsGetter) GetTrustedProxies() []*net.IPNet {
+	return g.TrustedProxies
+}
+
+func (g *FakeModelsGetter) GetCombiningAlgorithm() string {
+	return g.CombiningAlgorithm
+}
+
+func (g *FakeModelsGetter) GetDecisionCacheTTL() time.Duration {
+	return g.DecisionCacheTTL
+}
+
+func (g *FakeModelsGetter) GetDecisionCacheSize() int {
+	return g.DecisionCacheSize
+}
+
 func (g *FakeModelsGetter) GetSessionValidity() time.Duration {
 	return g.SessionValidity
 }
@@ -37,6 +64,26 @@ func (g *FakeModelsGetter) GetSessionTokenLength() int {
 	return g.SessionTokenLength
 }
 
+func (g *FakeModelsGetter) GetSessionIdleTimeout() time.Duration {
+	return g.SessionIdleTimeout
+}
+
+func (g *FakeModelsGetter) GetSessionMaxLifetime() time.Duration {
+	return g.SessionMaxLifetime
+}
+
+func (g *FakeModelsGetter) GetRefreshTokenValidity() time.Duration {
+	return g.RefreshValidity
+}
+
+func (g *FakeModelsGetter) GetTokenFormat() string {
+	return g.TokenFormat
+}
+
+func (g *FakeModelsGetter) GetJWTKeys() []models.SigningKey {
+	return g.JWTKeys
+}
+
 type FakeRender struct {
 	Status   int
 	APIError *zest.APIError
@@ -23,6 +23,10 @@ func IntCpy(c int) *int {
 	return &c
 }
 
+func Float64Cpy(c float64) *float64 {
+	return &c
+}
+
 func TimeCpy(c time.Time) *time.Time {
 	return &c
 }
@@ -0,0 +1,125 @@
+package validators
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/solher/auth-nginx-proxy-companion/errs"
+	"github.com/solher/auth-nginx-proxy-companion/matchers"
+	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/solher/zest"
+)
+
+func init() {
+	zest.Injector.Register(NewAuthValid)
+}
+
+type (
+	AuthValidPoliciesValidator interface {
+		ValidatePermissions(policy *models.Policy) error
+		ValidateWindow(policy *models.Policy) error
+	}
+
+	AuthValidResourcesValidator interface {
+		ValidateCombiningAlgorithm(resource *models.Resource) error
+		ValidateMode(resource *models.Resource) error
+		ValidateNetworks(resource *models.Resource) error
+	}
+
+	AuthValid struct {
+		pv AuthValidPoliciesValidator
+		rv AuthValidResourcesValidator
+	}
+)
+
+func NewAuthValid(pv AuthValidPoliciesValidator, rv AuthValidResourcesValidator) *AuthValid {
+	return &AuthValid{pv: pv, rv: rv}
+}
+
+func (v *AuthValid) ValidateSimulation(simulation *models.Simulation) error {
+	if simulation.Policy == nil && simulation.Config == nil {
+		return errs.NewErrValidation("simulation policy or config cannot be blank")
+	}
+
+	if len(simulation.Probes) == 0 {
+		return errs.NewErrValidation("simulation probes cannot be blank")
+	}
+
+	for _, probe := range simulation.Probes {
+		if len(probe.Hostname) == 0 {
+			return errs.NewErrValidation("probe hostname cannot be blank")
+		}
+
+		if probe.ClientIP != "" && net.ParseIP(probe.ClientIP) == nil {
+			return errs.NewErrValidation(fmt.Sprintf("probe client IP is invalid: '%s'", probe.ClientIP))
+		}
+	}
+
+	if simulation.Config != nil {
+		for _, resource := range simulation.Config.Resources {
+			if err := v.ValidateResource(&resource); err != nil {
+				return err
+			}
+		}
+
+		for _, policy := range simulation.Config.Policies {
+			if err := v.ValidatePolicy(&policy); err != nil {
+				return err
+			}
+		}
+	}
+
+	if simulation.Policy != nil {
+		if err := v.ValidatePolicy(simulation.Policy); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateResource only checks what the compilation of a proposed resource relies on.
+func (v *AuthValid) ValidateResource(resource *models.Resource) error {
+	if resource.Name == nil || len(*resource.Name) == 0 {
+		return errs.NewErrValidation("resource name cannot be blank")
+	}
+
+	if resource.Hostname == nil || len(*resource.Hostname) == 0 {
+		return errs.NewErrValidation(fmt.Sprintf("resource '%s' hostname cannot be blank", *resource.Name))
+	}
+
+	if resource.PathPrefix != nil {
+		if err := matchers.CheckPathPrefix(*resource.PathPrefix); err != nil {
+			return errs.NewErrValidation(fmt.Sprintf("path prefix '%s' is invalid: %s", *resource.PathPrefix, err))
+		}
+	}
+
+	if err := v.rv.ValidateCombiningAlgorithm(resource); err != nil {
+		return err
+	}
+
+	if err := v.rv.ValidateMode(resource); err != nil {
+		return err
+	}
+
+	return v.rv.ValidateNetworks(resource)
+}
+
+// ValidatePolicy only checks what the compilation of a proposed policy relies on.
+func (v *AuthValid) ValidatePolicy(policy *models.Policy) error {
+	if policy.Name == nil || len(*policy.Name) == 0 {
+		return errs.NewErrValidation("policy name cannot be blank")
+	}
+
+	for _, permission := range policy.Permissions {
+		if permission.Resource == nil || len(*permission.Resource) == 0 {
+			return errs.NewErrValidation(fmt.Sprintf("policy '%s' permission resource cannot be blank", *policy.Name))
+		}
+	}
+
+	if err := v.pv.ValidatePermissions(policy); err != nil {
+		return err
+	}
+
+	return v.pv.ValidateWindow(policy)
+}
@@ -0,0 +1,91 @@
+package validators
+
+import (
+	"testing"
+
+	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/solher/auth-nginx-proxy-companion/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAuthValidValidateSimulation runs tests on the AuthValid ValidateSimulation method.
+func TestAuthValidValidateSimulation(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	valid := NewAuthValid(
+		NewPoliciesValid(&policiesValidPoliciesRepo{}),
+		NewResourcesValid(&resourcesValidResourcesRepo{}),
+	)
+	simulation := &models.Simulation{}
+
+	// Validation error: nil policy and config
+	err := valid.ValidateSimulation(simulation)
+	r.NotNil(err)
+
+	simulation.Policy = &models.Policy{}
+
+	// Validation error: nil probes
+	err = valid.ValidateSimulation(simulation)
+	r.NotNil(err)
+
+	simulation.Probes = []models.Probe{{Path: "/foo"}}
+
+	// Validation error: blank probe hostname
+	err = valid.ValidateSimulation(simulation)
+	r.NotNil(err)
+
+	simulation.Probes[0].Hostname = "foo.bar.com"
+	simulation.Probes[0].ClientIP = "10.0.0"
+
+	// Validation error: invalid probe client IP
+	err = valid.ValidateSimulation(simulation)
+	r.NotNil(err)
+
+	simulation.Probes[0].ClientIP = "10.0.0.1"
+
+	// Validation error: blank policy name
+	err = valid.ValidateSimulation(simulation)
+	r.NotNil(err)
+
+	simulation.Policy.Name = utils.StrCpy("Foo")
+	simulation.Policy.Permissions = []models.Permission{{Resource: utils.StrCpy("Foobar"), Paths: []string{"/foo/{"}}}
+
+	// Validation error: invalid permission path
+	err = valid.ValidateSimulation(simulation)
+	r.NotNil(err)
+
+	simulation.Policy.Permissions[0].Paths = []string{"/foo/*"}
+
+	// Success
+	err = valid.ValidateSimulation(simulation)
+	a.Nil(err)
+
+	simulation.Config = &models.SimulationConfig{
+		Resources: []models.Resource{{Name: utils.StrCpy("Foobar")}},
+	}
+
+	// Validation error: blank resource hostname
+	err = valid.ValidateSimulation(simulation)
+	r.NotNil(err)
+
+	simulation.Config.Resources[0].Hostname = utils.StrCpy("foo.bar.com")
+	simulation.Config.Resources[0].CombiningAlgorithm = utils.StrCpy("foobar")
+
+	// Validation error: invalid combining algorithm
+	err = valid.ValidateSimulation(simulation)
+	r.NotNil(err)
+
+	simulation.Config.Resources[0].CombiningAlgorithm = nil
+	simulation.Config.Policies = []models.Policy{{Name: utils.StrCpy("Bar"), Permissions: []models.Permission{{}}}}
+
+	// Validation error: blank permission resource
+	err = valid.ValidateSimulation(simulation)
+	r.NotNil(err)
+
+	simulation.Config.Policies[0].Permissions = nil
+
+	// Success
+	err = valid.ValidateSimulation(simulation)
+	a.Nil(err)
+}
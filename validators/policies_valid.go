@@ -2,9 +2,12 @@ package validators
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/solher/auth-nginx-proxy-companion/errs"
+	"github.com/solher/auth-nginx-proxy-companion/matchers"
 	"github.com/solher/auth-nginx-proxy-companion/models"
 	"github.com/boltdb/bolt"
 	"github.com/solher/zest"
@@ -24,6 +27,18 @@ type (
 	}
 )
 
+var httpMethods = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"POST":    true,
+	"PUT":     true,
+	"PATCH":   true,
+	"DELETE":  true,
+	"OPTIONS": true,
+	"CONNECT": true,
+	"TRACE":   true,
+}
+
 func NewPoliciesValid(r PoliciesValidPoliciesRepo) *PoliciesValid {
 	return &PoliciesValid{r: r}
 }
@@ -39,6 +54,22 @@ func (v *PoliciesValid) ValidateCreation(policy *models.Policy) error {
 		return errs.NewErrValidation("policy permissions cannot be blank")
 	}
 
+	if err := v.ValidatePermissions(policy); err != nil {
+		return err
+	}
+
+	if err := v.ValidateWindow(policy); err != nil {
+		return err
+	}
+
+	if err := v.ValidateRateLimits(policy); err != nil {
+		return err
+	}
+
+	if err := v.ValidateExtends(policy); err != nil {
+		return err
+	}
+
 	go func() {
 		if err := v.ValidateResourcesExistence(policy); err != nil {
 			c <- err
@@ -75,6 +106,22 @@ func (v *PoliciesValid) ValidateUpdate(policy *models.Policy) error {
 		return errs.NewErrValidation("policy permissions cannot be blank")
 	}
 
+	if err := v.ValidatePermissions(policy); err != nil {
+		return err
+	}
+
+	if err := v.ValidateWindow(policy); err != nil {
+		return err
+	}
+
+	if err := v.ValidateRateLimits(policy); err != nil {
+		return err
+	}
+
+	if err := v.ValidateExtends(policy); err != nil {
+		return err
+	}
+
 	if err := v.ValidateResourcesExistence(policy); err != nil {
 		return err
 	}
@@ -82,6 +129,164 @@ func (v *PoliciesValid) ValidateUpdate(policy *models.Policy) error {
 	return nil
 }
 
+func (v *PoliciesValid) ValidatePermissions(policy *models.Policy) error {
+	for _, permission := range policy.Permissions {
+		for _, path := range permission.Paths {
+			if _, err := matchers.CompilePath(path); err != nil {
+				return errs.NewErrValidation(fmt.Sprintf("permission path is invalid: '%s' (%s)", path, err))
+			}
+		}
+
+		for _, method := range permission.Methods {
+			if !httpMethods[strings.ToUpper(method)] {
+				return errs.NewErrValidation(fmt.Sprintf("permission method is invalid: '%s'", method))
+			}
+		}
+
+		for _, condition := range permission.Conditions {
+			if _, err := matchers.CompileCondition(condition); err != nil {
+				return errs.NewErrValidation(fmt.Sprintf("permission condition is invalid: '%s' (%s)", condition, err))
+			}
+		}
+
+		if err := validateWindow(permission.Window); err != nil {
+			return errs.NewErrValidation(fmt.Sprintf("permission window is invalid: %s", err))
+		}
+
+		for _, cidrs := range [][]string{permission.AllowCIDRs, permission.DenyCIDRs} {
+			if _, err := matchers.ParseNetworks(cidrs); err != nil {
+				return errs.NewErrValidation(fmt.Sprintf("permission client IP range is invalid: %s", err))
+			}
+		}
+	}
+
+	return nil
+}
+
+func (v *PoliciesValid) ValidateRateLimits(policy *models.Policy) error {
+	if err := validateRateLimits(policy.RateLimits); err != nil {
+		return errs.NewErrValidation(fmt.Sprintf("policy rate limit is invalid: %s", err))
+	}
+
+	return nil
+}
+
+func (v *PoliciesValid) ValidateWindow(policy *models.Policy) error {
+	if err := validateWindow(policy.Window); err != nil {
+		return errs.NewErrValidation(fmt.Sprintf("policy window is invalid: %s", err))
+	}
+
+	return nil
+}
+
+func validateWindow(window *models.Window) error {
+	if window == nil {
+		return nil
+	}
+
+	if window.From != nil && window.To != nil && !window.From.Before(*window.To) {
+		return errors.New("'from' must be before 'to'")
+	}
+
+	for _, schedule := range window.Schedules {
+		if _, err := matchers.CompileSchedule(schedule.Days, schedule.From, schedule.To, schedule.TimeZone); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *PoliciesValid) ValidateExtends(policy *models.Policy) error {
+	if len(policy.Extends) == 0 {
+		return nil
+	}
+
+	// "extends" is the inheritance graph of the stored policies
+	extends := map[string][]string{}
+
+	err := v.r.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("policies")).Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			policy := models.Policy{}
+			if err := json.Unmarshal(v, &policy); err != nil {
+				return err
+			}
+
+			extends[string(k)] = policy.Extends
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, name := range policy.Extends {
+		if policy.Name != nil && name == *policy.Name {
+			return errs.NewErrValidation("a policy cannot extend itself")
+		}
+
+		if _, ok := extends[name]; !ok {
+			return errs.NewErrValidation(fmt.Sprintf("extended policy doesn't exists or is invalid: '%s'", name))
+		}
+	}
+
+	if policy.Name == nil {
+		return nil
+	}
+
+	// We check that the new inheritance graph is acyclic
+	extends[*policy.Name] = policy.Extends
+
+	if cycle := findInheritanceCycle(*policy.Name, extends); cycle != nil {
+		return errs.NewErrValidation(fmt.Sprintf("policy inheritance cycle: '%s'", strings.Join(cycle, " -> ")))
+	}
+
+	return nil
+}
+
+// findInheritanceCycle returns the first inheritance cycle reachable from the given policy, or nil.
+func findInheritanceCycle(name string, extends map[string][]string) []string {
+	path := []string{}
+	onPath := map[string]bool{}
+	done := map[string]bool{}
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		if onPath[name] {
+			for i, n := range path {
+				if n == name {
+					return append(append([]string{}, path[i:]...), name)
+				}
+			}
+		}
+
+		if done[name] {
+			return nil
+		}
+
+		path = append(path, name)
+		onPath[name] = true
+
+		for _, parent := range extends[name] {
+			if cycle := visit(parent); cycle != nil {
+				return cycle
+			}
+		}
+
+		path = path[:len(path)-1]
+		onPath[name] = false
+		done[name] = true
+
+		return nil
+	}
+
+	return visit(name)
+}
+
 func (v *PoliciesValid) ValidateNameUniqueness(policy *models.Policy) error {
 	if policy.Name == nil {
 		return nil
@@ -2,6 +2,7 @@ package validators
 
 import (
 	"testing"
+	"time"
 
 	"github.com/solher/auth-nginx-proxy-companion/errs"
 	"github.com/solher/auth-nginx-proxy-companion/models"
@@ -53,7 +54,86 @@ func TestPoliciesValidValidateCreation(t *testing.T) {
 	err = valid.ValidateCreation(policy)
 	r.NotNil(err)
 
+	policy.Permissions = []models.Permission{{Resource: utils.StrCpy("*"), Methods: []string{"FOO"}}}
+
+	// Validation error: invalid method
+	err = valid.ValidateCreation(policy)
+	r.NotNil(err)
+
+	policy.Permissions = []models.Permission{{Resource: utils.StrCpy("*"), Conditions: []string{"tenant = 'acme'"}}}
+
+	// Validation error: malformed condition
+	err = valid.ValidateCreation(policy)
+	r.NotNil(err)
+
+	policy.Permissions = []models.Permission{{
+		Resource: utils.StrCpy("*"),
+		Window:   &models.Window{Schedules: []models.Schedule{{Days: []string{"mon"}, TimeZone: "Mars/Olympus"}}},
+	}}
+
+	// Validation error: invalid permission schedule
+	err = valid.ValidateCreation(policy)
+	r.NotNil(err)
+
+	from, to := time.Now(), time.Now().Add(-time.Hour)
 	policy.Permissions = []models.Permission{{Resource: utils.StrCpy("*")}}
+	policy.Window = &models.Window{From: &from, To: &to}
+
+	// Validation error: the policy window ends before it starts
+	err = valid.ValidateCreation(policy)
+	r.NotNil(err)
+
+	policy.Window = nil
+	policy.Permissions = []models.Permission{{Resource: utils.StrCpy("*"), DenyCIDRs: []string{"10.0.0.0/8", "foo"}}}
+
+	// Validation error: invalid client IP range
+	err = valid.ValidateCreation(policy)
+	r.NotNil(err)
+
+	policy.Permissions = []models.Permission{{Resource: utils.StrCpy("*")}}
+	policy.RateLimits = []models.RateLimit{{By: utils.StrCpy("token"), Rate: utils.Float64Cpy(1), Burst: utils.IntCpy(0)}}
+
+	// Validation error: the rate limit burst is not positive
+	err = valid.ValidateCreation(policy)
+	r.NotNil(err)
+
+	policy.RateLimits = nil
+	policy.Permissions = []models.Permission{{Resource: utils.StrCpy("*"), Paths: []string{"/users/${token}"}}}
+
+	// Validation error: unknown session variable
+	err = valid.ValidateCreation(policy)
+	r.NotNil(err)
+
+	policy.Permissions = []models.Permission{{Resource: utils.StrCpy("*"), Paths: []string{"/foo/a**"}}}
+
+	// Validation error: malformed path pattern
+	err = valid.ValidateCreation(policy)
+	r.NotNil(err)
+
+	policy.Permissions = []models.Permission{{Resource: utils.StrCpy("*"), Paths: []string{"/users/{id}/{id}"}}}
+
+	// Validation error: duplicated placeholder
+	err = valid.ValidateCreation(policy)
+	r.NotNil(err)
+
+	policy.Extends = []string{"Foobar"}
+
+	// Validation error: the policy extends itself
+	err = valid.ValidateCreation(policy)
+	r.NotNil(err)
+
+	policy.Extends = []string{"Bar"}
+
+	// Validation error: the extended policy does not exist
+	err = valid.ValidateCreation(policy)
+	r.NotNil(err)
+
+	policy.Extends = nil
+	policy.Permissions = []models.Permission{{
+		Resource: utils.StrCpy("*"),
+		Paths:    []string{"/users/{id}/profile", "/static/**/*.js"},
+		Methods:  []string{"get", "DELETE"},
+	}}
 
 	// Validation passes: resource wildcard
 	err = valid.ValidateCreation(policy)
@@ -123,3 +203,27 @@ func TestPoliciesValidValidateDeletion(t *testing.T) {
 	err = valid.ValidateDeletion(policy)
 	r.Nil(err)
 }
+
+// TestFindInheritanceCycle runs tests on the findInheritanceCycle function.
+func TestFindInheritanceCycle(t *testing.T) {
+	a := assert.New(t)
+
+	extends := map[string][]string{
+		"a": {"b", "c"},
+		"b": {"c"},
+		"c": {},
+		"d": {"e"},
+		"e": {"f"},
+		"f": {"d"},
+		"g": {"a", "e"},
+	}
+
+	// No cycle: diamond inheritance
+	a.Nil(findInheritanceCycle("a", extends))
+
+	// Cycle
+	a.Equal([]string{"d", "e", "f", "d"}, findInheritanceCycle("d", extends))
+
+	// Reachable cycle
+	a.Equal([]string{"e", "f", "d", "e"}, findInheritanceCycle("g", extends))
+}
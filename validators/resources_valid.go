@@ -1,7 +1,12 @@
 package validators
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
 	"github.com/solher/auth-nginx-proxy-companion/errs"
+	"github.com/solher/auth-nginx-proxy-companion/matchers"
 	"github.com/solher/auth-nginx-proxy-companion/models"
 	"github.com/boltdb/bolt"
 	"github.com/solher/zest"
@@ -36,8 +41,24 @@ func (v *ResourcesValid) ValidateCreation(resource *models.Resource) error {
 		return errs.NewErrValidation("resource hostname cannot be blank")
 	}
 
+	if err := v.ValidateCombiningAlgorithm(resource); err != nil {
+		return err
+	}
+
+	if err := v.ValidateMode(resource); err != nil {
+		return err
+	}
+
+	if err := v.ValidateNetworks(resource); err != nil {
+		return err
+	}
+
+	if err := v.ValidateRateLimits(resource); err != nil {
+		return err
+	}
+
 	go func() {
-		if err := v.ValidateHostnameUniqueness(resource); err != nil {
+		if err := v.ValidateHostnames(resource); err != nil {
 			c <- err
 		}
 		c <- nil
@@ -64,19 +85,145 @@ func (v *ResourcesValid) ValidateUpdate(resource *models.Resource) error {
 		return errs.NewErrValidation("resource name cannot be blank")
 	}
 
-	if err := v.ValidateNameUniqueness(resource); err != nil {
+	if resource.Hostname == nil || len(*resource.Hostname) == 0 {
+		return errs.NewErrValidation("resource hostname cannot be blank")
+	}
+
+	if err := v.ValidateCombiningAlgorithm(resource); err != nil {
+		return err
+	}
+
+	if err := v.ValidateMode(resource); err != nil {
+		return err
+	}
+
+	if err := v.ValidateNetworks(resource); err != nil {
+		return err
+	}
+
+	if err := v.ValidateRateLimits(resource); err != nil {
 		return err
 	}
 
+	if err := v.ValidateHostnames(resource); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (v *ResourcesValid) ValidateCombiningAlgorithm(resource *models.Resource) error {
+	if resource.CombiningAlgorithm == nil {
+		return nil
+	}
+
+	switch *resource.CombiningAlgorithm {
+	case models.FirstApplicable, models.PermitOverrides, models.DenyOverrides, models.MostSpecificWins:
+		return nil
+	}
+
+	return errs.NewErrValidation(fmt.Sprintf("combining algorithm is invalid: '%s'", *resource.CombiningAlgorithm))
+}
+
+func (v *ResourcesValid) ValidateMode(resource *models.Resource) error {
+	if resource.Mode == nil {
+		return nil
+	}
+
+	switch *resource.Mode {
+	case models.ModeEnforce, models.ModeReport:
+		return nil
+	}
+
+	return errs.NewErrValidation(fmt.Sprintf("mode is invalid: '%s'", *resource.Mode))
+}
+
+func (v *ResourcesValid) ValidateNetworks(resource *models.Resource) error {
+	for _, cidrs := range [][]string{resource.AllowCIDRs, resource.DenyCIDRs} {
+		if _, err := matchers.ParseNetworks(cidrs); err != nil {
+			return errs.NewErrValidation(fmt.Sprintf("resource client IP range is invalid: %s", err))
+		}
+	}
+
+	return nil
+}
+
+func (v *ResourcesValid) ValidateRateLimits(resource *models.Resource) error {
+	if err := validateRateLimits(resource.RateLimits); err != nil {
+		return errs.NewErrValidation(fmt.Sprintf("resource rate limit is invalid: %s", err))
+	}
+
+	return nil
+}
+
+func validateRateLimits(limits []models.RateLimit) error {
+	for _, limit := range limits {
+		if limit.By == nil {
+			return errors.New("'by' cannot be blank")
+		}
+
+		switch *limit.By {
+		case models.LimitByToken, models.LimitByOwnerToken, models.LimitByClientIP, models.LimitByResource:
+		default:
+			return fmt.Errorf("unknown key '%s'", *limit.By)
+		}
+
+		if limit.Rate == nil || *limit.Rate <= 0 {
+			return errors.New("the rate must be positive")
+		}
+
+		if limit.Burst != nil && *limit.Burst < 1 {
+			return errors.New("the burst must be at least 1")
+		}
+	}
+
 	return nil
 }
 
-func (v *ResourcesValid) ValidateHostnameUniqueness(resource *models.Resource) error {
+// ValidateHostnames checks the host names and the path prefix of a resource
+// and makes sure that no other resource already serves the same host name and prefix.
+func (v *ResourcesValid) ValidateHostnames(resource *models.Resource) error {
+	if resource.PathPrefix != nil {
+		if err := matchers.CheckPathPrefix(*resource.PathPrefix); err != nil {
+			return errs.NewErrValidation(fmt.Sprintf("path prefix '%s' is invalid: %s", *resource.PathPrefix, err))
+		}
+	}
+
+	routes := map[string]bool{}
+
+	for _, host := range append([]string{*resource.Hostname}, resource.Aliases...) {
+		if err := matchers.CheckHost(host); err != nil {
+			return errs.NewErrValidation(fmt.Sprintf("host name '%s' is invalid: %s", host, err))
+		}
+
+		route := v.route(host, resource.PathPrefix)
+
+		if routes[route] {
+			return errs.NewErrValidation(fmt.Sprintf("host name '%s' is declared twice", host))
+		}
+
+		routes[route] = true
+	}
+
 	err := v.r.View(func(tx *bolt.Tx) error {
-		raw := tx.Bucket([]byte("resources")).Get([]byte(*resource.Hostname))
+		c := tx.Bucket([]byte("resources")).Cursor()
 
-		if len(raw) != 0 {
-			return errs.NewErrValidation("hostname must be unique")
+		for k, raw := c.First(); k != nil; k, raw = c.Next() {
+			// The resource itself, when updated
+			if resource.Name != nil && string(k) == *resource.Name {
+				continue
+			}
+
+			other := models.Resource{}
+			if err := json.Unmarshal(raw, &other); err != nil {
+				return err
+			}
+
+			for _, host := range append([]string{*other.Hostname}, other.Aliases...) {
+				if route := v.route(host, other.PathPrefix); routes[route] {
+					return errs.NewErrValidation(fmt.Sprintf("'%s' is already served by the resource '%s'", route, *other.Name))
+				}
+			}
 		}
 
 		return nil
@@ -85,6 +232,19 @@ func (v *ResourcesValid) ValidateHostnameUniqueness(resource *models.Resource) e
 	return err
 }
 
+// route returns the normalized host name and path prefix identifying the requests served by a resource.
+func (v *ResourcesValid) route(host string, prefix *string) string {
+	route := matchers.NormalizeHost(host)
+
+	if prefix != nil {
+		for _, segment := range matchers.PrefixSegments(*prefix) {
+			route += "/" + segment
+		}
+	}
+
+	return route
+}
+
 func (v *ResourcesValid) ValidateNameUniqueness(resource *models.Resource) error {
 	if resource.Name == nil {
 		return nil
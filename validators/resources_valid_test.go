@@ -42,6 +42,40 @@ func TestResourcesValidValidateCreation(t *testing.T) {
 	r.NotNil(err)
 
 	resource.Hostname = utils.StrCpy("foo.bar.com")
+	resource.CombiningAlgorithm = utils.StrCpy("foo")
+
+	// Validation error: invalid combining algorithm
+	err = valid.ValidateCreation(resource)
+	r.NotNil(err)
+
+	resource.CombiningAlgorithm = utils.StrCpy("deny-overrides")
+	resource.Mode = utils.StrCpy("audit")
+
+	// Validation error: invalid mode
+	err = valid.ValidateCreation(resource)
+	r.NotNil(err)
+
+	resource.Mode = utils.StrCpy("report")
+	resource.AllowCIDRs = []string{"10.8.0.0/16", "10.9.0.0/33"}
+
+	// Validation error: invalid client IP range
+	err = valid.ValidateCreation(resource)
+	r.NotNil(err)
+
+	resource.AllowCIDRs = []string{"10.8.0.0/16", "10.9.0.1"}
+	resource.RateLimits = []models.RateLimit{{By: utils.StrCpy("session"), Rate: utils.Float64Cpy(10)}}
+
+	// Validation error: unknown rate limit key
+	err = valid.ValidateCreation(resource)
+	r.NotNil(err)
+
+	resource.RateLimits = []models.RateLimit{{By: utils.StrCpy("clientIp"), Rate: utils.Float64Cpy(0)}}
+
+	// Validation error: the rate limit rate is not positive
+	err = valid.ValidateCreation(resource)
+	r.NotNil(err)
+
+	resource.RateLimits = []models.RateLimit{{By: utils.StrCpy("clientIp"), Rate: utils.Float64Cpy(0.5), Burst: utils.IntCpy(5)}}
 	repo.err = true
 
 	// The repo returns a database error
@@ -51,6 +85,40 @@ func TestResourcesValidValidateCreation(t *testing.T) {
 
 	repo.err = false
 
+	resource.Hostname = utils.StrCpy("foo.bar.com:8080")
+
+	// Validation error: port in the host name
+	err = valid.ValidateCreation(resource)
+	r.NotNil(err)
+
+	resource.Hostname = utils.StrCpy("*.com")
+
+	// Validation error: too broad wildcard
+	err = valid.ValidateCreation(resource)
+	r.NotNil(err)
+
+	resource.Hostname = utils.StrCpy("*.foo.bar.com")
+	resource.Aliases = []string{"foo.bar.com", "Foo.Bar.com"}
+
+	// Validation error: alias declared twice
+	err = valid.ValidateCreation(resource)
+	r.NotNil(err)
+
+	resource.Aliases = []string{"foo..bar.com"}
+
+	// Validation error: invalid alias
+	err = valid.ValidateCreation(resource)
+	r.NotNil(err)
+
+	resource.Aliases = []string{"foo.bar.com"}
+	resource.PathPrefix = utils.StrCpy("grafana")
+
+	// Validation error: invalid path prefix
+	err = valid.ValidateCreation(resource)
+	r.NotNil(err)
+
+	resource.PathPrefix = utils.StrCpy("/grafana")
+
 	// Success
 	err = valid.ValidateCreation(resource)
 	r.Nil(err)
@@ -69,6 +137,33 @@ func TestResourcesValidValidateUpdate(t *testing.T) {
 
 	resource.Name = utils.StrCpy("Foobar")
 
+	// Validation error: blank hostname
+	err = valid.ValidateUpdate(resource)
+	r.NotNil(err)
+
+	resource.Hostname = utils.StrCpy("foo.bar.com")
+	resource.CombiningAlgorithm = utils.StrCpy("foo")
+
+	// Validation error: invalid combining algorithm
+	err = valid.ValidateUpdate(resource)
+	r.NotNil(err)
+
+	resource.CombiningAlgorithm = utils.StrCpy("most-specific-wins")
+	resource.Mode = utils.StrCpy("audit")
+
+	// Validation error: invalid mode
+	err = valid.ValidateUpdate(resource)
+	r.NotNil(err)
+
+	resource.Mode = utils.StrCpy("enforce")
+	resource.Aliases = []string{"FOO.bar.com"}
+
+	// Validation error: the alias is the host name
+	err = valid.ValidateUpdate(resource)
+	r.NotNil(err)
+
+	resource.Aliases = []string{"*.foo.bar.com"}
+
 	// Success
 	err = valid.ValidateUpdate(resource)
 	r.Nil(err)
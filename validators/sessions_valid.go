@@ -2,6 +2,7 @@ package validators
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/solher/auth-nginx-proxy-companion/errs"
 	"github.com/solher/auth-nginx-proxy-companion/models"
@@ -18,13 +19,18 @@ type (
 		View(func(tx *bolt.Tx) error) error
 	}
 
+	SessionsValidTokenHasher interface {
+		Hash(token string) string
+	}
+
 	SessionsValid struct {
 		r SessionsValidSessionsRepo
+		h SessionsValidTokenHasher
 	}
 )
 
-func NewSessionsValid(r SessionsValidSessionsRepo) *SessionsValid {
-	return &SessionsValid{r: r}
+func NewSessionsValid(r SessionsValidSessionsRepo, h SessionsValidTokenHasher) *SessionsValid {
+	return &SessionsValid{r: r, h: h}
 }
 
 func (v *SessionsValid) ValidateCreation(session *models.Session) error {
@@ -34,6 +40,10 @@ func (v *SessionsValid) ValidateCreation(session *models.Session) error {
 		return errs.NewErrValidation("session policies cannot be blank")
 	}
 
+	if session.ValidTo != nil && session.ValidTo.After(models.MaxValidTo) {
+		return errs.NewErrValidation("session validity is too far in the future")
+	}
+
 	go func() {
 		if err := v.ValidateTokenUniqueness(session); err != nil {
 			c <- err
@@ -57,13 +67,44 @@ func (v *SessionsValid) ValidateCreation(session *models.Session) error {
 	return nil
 }
 
+func (v *SessionsValid) ValidateUpdate(update *models.SessionUpdate) error {
+	if update.Policies == nil && update.Payload == nil && update.ValidTo == nil {
+		return errs.NewErrValidation("session update cannot be blank")
+	}
+
+	// Revoking a session is done by deleting it
+	if update.ValidTo != nil && !update.ValidTo.After(time.Now()) {
+		return errs.NewErrValidation("session validity must be in the future")
+	}
+
+	if update.ValidTo != nil && update.ValidTo.After(models.MaxValidTo) {
+		return errs.NewErrValidation("session validity is too far in the future")
+	}
+
+	if update.Policies != nil {
+		if err := v.ValidatePolicyExistence(&models.Session{Policies: update.Policies}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *SessionsValid) ValidateRefresh(refresh *models.Refresh) error {
+	if refresh.RefreshToken == nil || len(*refresh.RefreshToken) == 0 {
+		return errs.NewErrValidation("refresh token cannot be blank")
+	}
+
+	return nil
+}
+
 func (v *SessionsValid) ValidateTokenUniqueness(session *models.Session) error {
 	if session.Token == nil {
 		return nil
 	}
 
 	err := v.r.View(func(tx *bolt.Tx) error {
-		raw := tx.Bucket([]byte("sessions")).Get([]byte(*session.Token))
+		raw := tx.Bucket([]byte("sessions")).Get([]byte(v.h.Hash(*session.Token)))
 
 		if len(raw) != 0 {
 			return errs.NewErrValidation("token must be unique")
@@ -2,9 +2,11 @@ package validators
 
 import (
 	"testing"
+	"time"
 
 	"github.com/solher/auth-nginx-proxy-companion/errs"
 	"github.com/solher/auth-nginx-proxy-companion/models"
+	"github.com/solher/auth-nginx-proxy-companion/utils"
 	"github.com/boltdb/bolt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -22,12 +24,18 @@ func (r *sessionsValidSessionsRepo) View(t func(tx *bolt.Tx) error) error {
 	return nil
 }
 
+type sessionsValidTokenHasher struct{}
+
+func (h *sessionsValidTokenHasher) Hash(token string) string {
+	return token
+}
+
 // TestSessionsValidValidateCreation runs tests on the SessionsValid ValidateCreation method.
 func TestSessionsValidValidateCreation(t *testing.T) {
 	a := assert.New(t)
 	r := require.New(t)
 	repo := &sessionsValidSessionsRepo{}
-	valid := NewSessionsValid(repo)
+	valid := NewSessionsValid(repo, &sessionsValidTokenHasher{})
 	session := &models.Session{}
 
 	// Validation error: nil policies
@@ -35,6 +43,14 @@ func TestSessionsValidValidateCreation(t *testing.T) {
 	r.NotNil(err)
 
 	session.Policies = []string{"1", "2"}
+	session.ValidTo = utils.TimeCpy(time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	// Validation error: validity too far in the future
+	err = valid.ValidateCreation(session)
+	r.NotNil(err)
+	a.IsType(errs.ErrValidation{}, err)
+
+	session.ValidTo = nil
 	repo.err = true
 
 	// The repo returns a database error
@@ -48,3 +64,63 @@ func TestSessionsValidValidateCreation(t *testing.T) {
 	err = valid.ValidateCreation(session)
 	r.Nil(err)
 }
+
+// TestSessionsValidValidateRefresh runs tests on the SessionsValid ValidateRefresh method.
+func TestSessionsValidValidateRefresh(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	valid := NewSessionsValid(&sessionsValidSessionsRepo{}, &sessionsValidTokenHasher{})
+
+	// Validation error: nil refresh token
+	err := valid.ValidateRefresh(&models.Refresh{})
+	r.NotNil(err)
+	a.IsType(errs.ErrValidation{}, err)
+
+	// Validation error: blank refresh token
+	err = valid.ValidateRefresh(&models.Refresh{RefreshToken: utils.StrCpy("")})
+	r.NotNil(err)
+
+	// Success
+	err = valid.ValidateRefresh(&models.Refresh{RefreshToken: utils.StrCpy("R3fr3sh")})
+	r.Nil(err)
+}
+
+// TestSessionsValidValidateUpdate runs tests on the SessionsValid ValidateUpdate method.
+func TestSessionsValidValidateUpdate(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	repo := &sessionsValidSessionsRepo{}
+	valid := NewSessionsValid(repo, &sessionsValidTokenHasher{})
+
+	// Validation error: blank update
+	err := valid.ValidateUpdate(&models.SessionUpdate{})
+	r.NotNil(err)
+	a.IsType(errs.ErrValidation{}, err)
+
+	// Validation error: validity in the past
+	err = valid.ValidateUpdate(&models.SessionUpdate{ValidTo: utils.TimeCpy(time.Now().Add(-time.Hour))})
+	r.NotNil(err)
+	a.IsType(errs.ErrValidation{}, err)
+
+	// Validation error: validity too far in the future
+	err = valid.ValidateUpdate(&models.SessionUpdate{ValidTo: utils.TimeCpy(time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC))})
+	r.NotNil(err)
+	a.IsType(errs.ErrValidation{}, err)
+
+	repo.err = true
+
+	// The repo returns a database error
+	err = valid.ValidateUpdate(&models.SessionUpdate{Policies: []string{"1"}})
+	r.NotNil(err)
+	a.IsType(errs.Internal.Database, err)
+
+	// Success: the policies are not checked when not updated
+	err = valid.ValidateUpdate(&models.SessionUpdate{ValidTo: utils.TimeCpy(time.Now().Add(time.Hour))})
+	r.Nil(err)
+
+	repo.err = false
+
+	// Success
+	err = valid.ValidateUpdate(&models.SessionUpdate{Policies: []string{"1"}, Payload: utils.StrCpy("")})
+	r.Nil(err)
+}